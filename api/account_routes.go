@@ -0,0 +1,170 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/brunovale91/payment-api/services"
+	"github.com/brunovale91/payment-api/types"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+)
+
+const accountIdParam = "accountID"
+const currencyParam = "currency"
+
+var AccountNotFound = &types.HttpError{StatusText: "Account not found"}
+
+func addAccountRoutes(accountService services.AccountService, ledgerService services.LedgerService, validator PaymentValidator) *chi.Mux {
+	router := chi.NewRouter()
+	setCreateAccount(router, accountService, validator)
+	setGetAccounts(router, accountService)
+	setGetAccountById(router, accountService)
+	setUpdateAccount(router, accountService, validator)
+	setDeleteAccount(router, accountService)
+	setGetAccountBalance(router, accountService, ledgerService)
+	setGetAccountEntries(router, accountService, ledgerService)
+	return router
+}
+
+func setCreateAccount(router *chi.Mux, accountService services.AccountService, validator PaymentValidator) {
+	router.Post("/", func(w http.ResponseWriter, r *http.Request) {
+		var account types.Account
+		json.NewDecoder(r.Body).Decode(&account)
+
+		errors := validator.ValidateAccount(&account)
+		if errors != nil {
+			renderBadRequest(router, w, r, errors)
+			return
+		}
+
+		created, err := accountService.CreateAccount(&account)
+		if err != nil {
+			renderInternalError(router, w, r)
+		} else {
+			render.JSON(w, r, created)
+		}
+	})
+}
+
+// Accounts, of the organisation given as a query parameter if any
+func setGetAccounts(router *chi.Mux, accountService services.AccountService) {
+	router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		accounts, err := accountService.GetAccounts(r.URL.Query().Get(organisationIdParam))
+		if err != nil {
+			renderInternalError(router, w, r)
+		} else {
+			render.JSON(w, r, &types.Accounts{Data: accounts})
+		}
+	})
+}
+
+func setGetAccountById(router *chi.Mux, accountService services.AccountService) {
+	router.Get("/{"+accountIdParam+"}", func(w http.ResponseWriter, r *http.Request) {
+		account, err := accountService.GetAccount(chi.URLParam(r, accountIdParam))
+		renderAccount(router, w, r, account, err)
+	})
+}
+
+// Payments created before the update keep the details they were created with
+func setUpdateAccount(router *chi.Mux, accountService services.AccountService, validator PaymentValidator) {
+	router.Put("/{"+accountIdParam+"}", func(w http.ResponseWriter, r *http.Request) {
+		var account types.Account
+		json.NewDecoder(r.Body).Decode(&account)
+
+		errors := validator.ValidateAccount(&account)
+		if errors != nil {
+			renderBadRequest(router, w, r, errors)
+			return
+		}
+
+		updated, err := accountService.UpdateAccount(chi.URLParam(r, accountIdParam), &account)
+		renderAccount(router, w, r, updated, err)
+	})
+}
+
+func setDeleteAccount(router *chi.Mux, accountService services.AccountService) {
+	router.Delete("/{"+accountIdParam+"}", func(w http.ResponseWriter, r *http.Request) {
+		deleted, err := accountService.DeleteAccount(chi.URLParam(r, accountIdParam))
+		if err != nil {
+			renderInternalError(router, w, r)
+		} else if deleted {
+			render.JSON(w, r, &types.AccountDelete{
+				Deleted: deleted,
+			})
+		} else {
+			render.Status(r, 404)
+			render.JSON(w, r, AccountNotFound)
+		}
+	})
+}
+
+// Balance per currency of a saved account, or of a ledger account: an
+// organisation id, party bank id and account number joined by colons, or the
+// fees and fx accounts.
+// Saved accounts without entries have no balances rather than not being
+// found.
+func setGetAccountBalance(router *chi.Mux, accountService services.AccountService, ledgerService services.LedgerService) {
+	router.Get("/{"+accountIdParam+"}/balance", func(w http.ResponseWriter, r *http.Request) {
+		accountId := chi.URLParam(r, accountIdParam)
+		ledgerId, err := accountService.LedgerAccountId(accountId)
+		if err != nil {
+			renderInternalError(router, w, r)
+			return
+		}
+		if ledgerId == "" {
+			ledgerId = accountId
+		}
+		balance, err := ledgerService.GetBalance(ledgerId)
+		if err != nil {
+			renderInternalError(router, w, r)
+		} else if balance != nil {
+			balance.AccountId = accountId
+			render.JSON(w, r, balance)
+		} else if ledgerId != accountId {
+			render.JSON(w, r, &types.AccountBalance{AccountId: accountId, Balances: []*types.CurrencyBalance{}})
+		} else {
+			render.Status(r, 404)
+			render.JSON(w, r, AccountNotFound)
+		}
+	})
+}
+
+// Entries of a saved or ledger account, in the currency given as query
+// parameter if any, latest first
+func setGetAccountEntries(router *chi.Mux, accountService services.AccountService, ledgerService services.LedgerService) {
+	router.Get("/{"+accountIdParam+"}/entries", func(w http.ResponseWriter, r *http.Request) {
+		accountId := chi.URLParam(r, accountIdParam)
+		ledgerId, err := accountService.LedgerAccountId(accountId)
+		if err != nil {
+			renderInternalError(router, w, r)
+			return
+		}
+		if ledgerId == "" {
+			ledgerId = accountId
+		}
+		entries, err := ledgerService.GetEntries(ledgerId, r.URL.Query().Get(currencyParam))
+		if err != nil {
+			renderInternalError(router, w, r)
+		} else {
+			render.JSON(w, r, &types.LedgerEntries{Data: entries})
+		}
+	})
+}
+
+func renderAccount(router *chi.Mux, w http.ResponseWriter, r *http.Request, account *types.Account, err error) {
+	if stateErr, ok := err.(services.StateError); ok {
+		renderConflict(router, w, r, stateErr.Message, "")
+	} else if err != nil {
+		renderInternalError(router, w, r)
+	} else if account != nil {
+		render.JSON(w, r, account)
+	} else {
+		render.Status(r, 404)
+		render.JSON(w, r, AccountNotFound)
+	}
+}
+
+func renderAccountError(router *chi.Mux, w http.ResponseWriter, r *http.Request, prefix string, err services.AccountError) {
+	renderBadRequest(router, w, r, []*types.FieldError{{Field: prefix + err.Field, Message: err.Message}})
+}
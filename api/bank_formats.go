@@ -0,0 +1,71 @@
+package api
+
+import (
+	"regexp"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+var bicPattern = regexp.MustCompile(`^[A-Z]{6}[A-Z0-9]{2}([A-Z0-9]{3})?$`)
+var ibanPattern = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[A-Z0-9]{11,30}$`)
+var sortCodePattern = regexp.MustCompile(`^[0-9]{6}$`)
+var abaPattern = regexp.MustCompile(`^[0-9]{9}$`)
+
+// Bank id formats referenced by the party schema
+func init() {
+	gojsonschema.FormatCheckers.Add("iban", IBANFormatChecker{})
+	gojsonschema.FormatCheckers.Add("bic", BICFormatChecker{})
+	gojsonschema.FormatCheckers.Add("sort-code", SortCodeFormatChecker{})
+	gojsonschema.FormatCheckers.Add("aba-routing", ABAFormatChecker{})
+}
+
+// IBAN structure and ISO 7064 mod 97-10 check digits
+type IBANFormatChecker struct{}
+
+func (f IBANFormatChecker) IsFormat(input interface{}) bool {
+	iban, ok := input.(string)
+	if !ok || !ibanPattern.MatchString(iban) {
+		return false
+	}
+	remainder := 0
+	for _, c := range iban[4:] + iban[:4] {
+		if c >= 'A' && c <= 'Z' {
+			remainder = (remainder*100 + int(c-'A') + 10) % 97
+		} else {
+			remainder = (remainder*10 + int(c-'0')) % 97
+		}
+	}
+	return remainder == 1
+}
+
+// ISO 9362 business identifier code, 8 or 11 characters
+type BICFormatChecker struct{}
+
+func (f BICFormatChecker) IsFormat(input interface{}) bool {
+	bic, ok := input.(string)
+	return ok && bicPattern.MatchString(bic)
+}
+
+// UK sort code, 6 digits without separators
+type SortCodeFormatChecker struct{}
+
+func (f SortCodeFormatChecker) IsFormat(input interface{}) bool {
+	sortCode, ok := input.(string)
+	return ok && sortCodePattern.MatchString(sortCode)
+}
+
+// US ABA routing number, 9 digits with a weighted 3-7-1 checksum
+type ABAFormatChecker struct{}
+
+func (f ABAFormatChecker) IsFormat(input interface{}) bool {
+	aba, ok := input.(string)
+	if !ok || !abaPattern.MatchString(aba) {
+		return false
+	}
+	weights := [...]int{3, 7, 1}
+	sum := 0
+	for i, c := range aba {
+		sum += weights[i%3] * int(c-'0')
+	}
+	return sum%10 == 0
+}
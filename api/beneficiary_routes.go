@@ -0,0 +1,161 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/brunovale91/payment-api/services"
+	"github.com/brunovale91/payment-api/types"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+)
+
+const beneficiaryIdParam = "beneficiaryID"
+const nameParam = "name"
+
+var BeneficiaryNotFound = &types.HttpError{StatusText: "Beneficiary not found"}
+
+func addBeneficiaryRoutes(beneficiaryService services.BeneficiaryService, validator PaymentValidator) *chi.Mux {
+	router := chi.NewRouter()
+	setCreateBeneficiary(router, beneficiaryService, validator)
+	setGetBeneficiaries(router, beneficiaryService)
+	setGetBeneficiaryById(router, beneficiaryService)
+	setUpdateBeneficiary(router, beneficiaryService, validator)
+	setVerifyBeneficiary(router, beneficiaryService)
+	setDeleteBeneficiary(router, beneficiaryService)
+	return router
+}
+
+// Saved by the user of the request, who cannot verify the beneficiary
+func setCreateBeneficiary(router *chi.Mux, beneficiaryService services.BeneficiaryService, validator PaymentValidator) {
+	router.Post("/", func(w http.ResponseWriter, r *http.Request) {
+		userId, ok := requireUser(router, w, r)
+		if !ok {
+			return
+		}
+		var beneficiary types.Beneficiary
+		json.NewDecoder(r.Body).Decode(&beneficiary)
+
+		errors := validator.ValidateBeneficiary(&beneficiary)
+		if errors != nil {
+			renderBadRequest(router, w, r, errors)
+			return
+		}
+
+		created, err := beneficiaryService.CreateBeneficiary(&beneficiary, userId)
+		if err != nil {
+			renderInternalError(router, w, r)
+		} else {
+			render.JSON(w, r, created)
+		}
+	})
+}
+
+// Beneficiaries of the organisation given as a query parameter, with names
+// containing the name query parameter if any, most used first
+func setGetBeneficiaries(router *chi.Mux, beneficiaryService services.BeneficiaryService) {
+	router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		organisationId := query.Get(organisationIdParam)
+		if organisationId == "" {
+			renderBadRequest(router, w, r, []*types.FieldError{{
+				Field:   organisationIdParam,
+				Message: "organisation_id is required",
+			}})
+			return
+		}
+		beneficiaries, err := beneficiaryService.GetBeneficiaries(organisationId, query.Get(nameParam))
+		if err != nil {
+			renderInternalError(router, w, r)
+		} else {
+			render.JSON(w, r, &types.Beneficiaries{Data: beneficiaries})
+		}
+	})
+}
+
+func setGetBeneficiaryById(router *chi.Mux, beneficiaryService services.BeneficiaryService) {
+	router.Get("/{"+beneficiaryIdParam+"}", func(w http.ResponseWriter, r *http.Request) {
+		beneficiary, err := beneficiaryService.GetBeneficiary(chi.URLParam(r, beneficiaryIdParam))
+		renderBeneficiary(router, w, r, beneficiary, err)
+	})
+}
+
+// Edited beneficiaries cannot be paid until verified again, by another user
+// than the one of the request
+func setUpdateBeneficiary(router *chi.Mux, beneficiaryService services.BeneficiaryService, validator PaymentValidator) {
+	router.Put("/{"+beneficiaryIdParam+"}", func(w http.ResponseWriter, r *http.Request) {
+		userId, ok := requireUser(router, w, r)
+		if !ok {
+			return
+		}
+		var beneficiary types.Beneficiary
+		json.NewDecoder(r.Body).Decode(&beneficiary)
+
+		errors := validator.ValidateBeneficiary(&beneficiary)
+		if errors != nil {
+			renderBadRequest(router, w, r, errors)
+			return
+		}
+
+		updated, err := beneficiaryService.UpdateBeneficiary(chi.URLParam(r, beneficiaryIdParam), &beneficiary, userId)
+		renderBeneficiary(router, w, r, updated, err)
+	})
+}
+
+// Verification by the user of the request, who cannot be the user that saved
+// or last edited the beneficiary
+func setVerifyBeneficiary(router *chi.Mux, beneficiaryService services.BeneficiaryService) {
+	router.Post("/{"+beneficiaryIdParam+"}/verification", func(w http.ResponseWriter, r *http.Request) {
+		userId, ok := requireUser(router, w, r)
+		if !ok {
+			return
+		}
+		beneficiary, err := beneficiaryService.VerifyBeneficiary(chi.URLParam(r, beneficiaryIdParam), userId)
+		renderBeneficiary(router, w, r, beneficiary, err)
+	})
+}
+
+func setDeleteBeneficiary(router *chi.Mux, beneficiaryService services.BeneficiaryService) {
+	router.Delete("/{"+beneficiaryIdParam+"}", func(w http.ResponseWriter, r *http.Request) {
+		deleted, err := beneficiaryService.DeleteBeneficiary(chi.URLParam(r, beneficiaryIdParam))
+		if err != nil {
+			renderInternalError(router, w, r)
+		} else if deleted {
+			render.JSON(w, r, &types.BeneficiaryDelete{
+				Deleted: deleted,
+			})
+		} else {
+			render.Status(r, 404)
+			render.JSON(w, r, BeneficiaryNotFound)
+		}
+	})
+}
+
+// User of the request, false after rendering a bad request when there is none
+func requireUser(router *chi.Mux, w http.ResponseWriter, r *http.Request) (string, bool) {
+	userId := r.Header.Get(userIdHeader)
+	if userId == "" {
+		renderBadRequest(router, w, r, []*types.FieldError{{Message: userIdHeader + " header is required"}})
+		return "", false
+	}
+	return userId, true
+}
+
+func renderBeneficiary(router *chi.Mux, w http.ResponseWriter, r *http.Request, beneficiary *types.Beneficiary, err error) {
+	if forbiddenErr, ok := err.(services.ForbiddenError); ok {
+		renderForbidden(router, w, r, forbiddenErr.Message)
+	} else if stateErr, ok := err.(services.StateError); ok {
+		renderConflict(router, w, r, stateErr.Message, "")
+	} else if err != nil {
+		renderInternalError(router, w, r)
+	} else if beneficiary != nil {
+		render.JSON(w, r, beneficiary)
+	} else {
+		render.Status(r, 404)
+		render.JSON(w, r, BeneficiaryNotFound)
+	}
+}
+
+func renderBeneficiaryError(router *chi.Mux, w http.ResponseWriter, r *http.Request, prefix string, err services.BeneficiaryError) {
+	renderBadRequest(router, w, r, []*types.FieldError{{Field: prefix + err.Field, Message: err.Message}})
+}
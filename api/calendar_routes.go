@@ -0,0 +1,53 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/brunovale91/payment-api/services"
+	"github.com/brunovale91/payment-api/types"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+)
+
+const calendarIdParam = "calendarID"
+const dateParam = "date"
+const dateLayout = "2006-01-02"
+
+var CalendarNotFound = &types.HttpError{StatusText: "Calendar not found"}
+
+func addCalendarRoutes(calendarService services.CalendarService) *chi.Mux {
+	router := chi.NewRouter()
+	setGetNextBusinessDay(router, calendarService)
+	return router
+}
+
+// Next business day after the date query parameter, today by default
+func setGetNextBusinessDay(router *chi.Mux, calendarService services.CalendarService) {
+	router.Get("/{"+calendarIdParam+"}/next-business-day", func(w http.ResponseWriter, r *http.Request) {
+		calendarId := chi.URLParam(r, calendarIdParam)
+		date := time.Now().UTC()
+		if value := r.URL.Query().Get(dateParam); value != "" {
+			parsed, err := time.Parse(dateLayout, value)
+			if err != nil {
+				renderBadRequest(router, w, r, []*types.FieldError{{
+					Field:   dateParam,
+					Message: "date must be formatted as YYYY-MM-DD",
+				}})
+				return
+			}
+			date = parsed
+		}
+		calendar := calendarService.GetCalendar(calendarId)
+		if calendar == nil {
+			render.Status(r, 404)
+			render.JSON(w, r, CalendarNotFound)
+			return
+		}
+		render.JSON(w, r, &types.BusinessDay{
+			CalendarId:      calendarId,
+			Date:            date.Format(dateLayout),
+			NextBusinessDay: calendar.NextBusinessDay(date).Format(dateLayout),
+		})
+	})
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/brunovale91/payment-api/fx"
+	"github.com/brunovale91/payment-api/services"
+	"github.com/brunovale91/payment-api/types"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+)
+
+const quoteIdParam = "quoteID"
+const baseCurrencyParam = "base_currency"
+const quoteCurrencyParam = "quote_currency"
+
+var QuoteNotFound = &types.HttpError{StatusText: "Quote not found"}
+
+func addFxRateRoutes(fxService services.FxService) *chi.Mux {
+	router := chi.NewRouter()
+	setImportRates(router, fxService)
+	setGetRates(router, fxService)
+	setCreateRates(router, fxService)
+	return router
+}
+
+func addQuoteRoutes(fxService services.FxService) *chi.Mux {
+	router := chi.NewRouter()
+	setCreateQuote(router, fxService)
+	setGetQuoteById(router, fxService)
+	return router
+}
+
+// Rates, of the currency pair given as query parameters if any, latest
+// effective first
+func setGetRates(router *chi.Mux, fxService services.FxService) {
+	router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		rates, err := fxService.GetRates(query.Get(baseCurrencyParam), query.Get(quoteCurrencyParam))
+		if err != nil {
+			renderInternalError(router, w, r)
+		} else {
+			render.JSON(w, r, &types.FxRates{Data: rates})
+		}
+	})
+}
+
+func setCreateRates(router *chi.Mux, fxService services.FxService) {
+	router.Post("/", func(w http.ResponseWriter, r *http.Request) {
+		var rates types.FxRates
+		if err := json.NewDecoder(r.Body).Decode(&rates); err != nil || len(rates.Data) == 0 {
+			renderBadRequest(router, w, r, []*types.FieldError{{Field: "data", Message: "data must list at least one rate"}})
+			return
+		}
+		renderRates(router, w, r, fxService, rates.Data)
+	})
+}
+
+// Rates from a CSV file, one base currency, quote currency, rate and
+// optional effective time per line
+func setImportRates(router *chi.Mux, fxService services.FxService) {
+	router.Post("/import", func(w http.ResponseWriter, r *http.Request) {
+		rates, err := fx.ParseRates(r.Body)
+		if formatErr, ok := err.(fx.FormatError); ok {
+			renderBadRequest(router, w, r, []*types.FieldError{{Message: formatErr.Message}})
+			return
+		} else if err != nil {
+			renderInternalError(router, w, r)
+			return
+		}
+		if len(rates) == 0 {
+			renderBadRequest(router, w, r, []*types.FieldError{{Message: "File has no rates"}})
+			return
+		}
+		renderRates(router, w, r, fxService, rates)
+	})
+}
+
+func renderRates(router *chi.Mux, w http.ResponseWriter, r *http.Request, fxService services.FxService, rates []*types.FxRate) {
+	created, err := fxService.CreateRates(rates)
+	if fxErr, ok := err.(services.FxError); ok {
+		renderFxError(router, w, r, "", fxErr)
+	} else if err != nil {
+		renderInternalError(router, w, r)
+	} else {
+		render.JSON(w, r, &types.FxRates{Data: created})
+	}
+}
+
+// Quote of the current rate, payments referencing it by quote_id convert at
+// its rate until it expires
+func setCreateQuote(router *chi.Mux, fxService services.FxService) {
+	router.Post("/", func(w http.ResponseWriter, r *http.Request) {
+		var request types.QuoteRequest
+		json.NewDecoder(r.Body).Decode(&request)
+
+		quote, err := fxService.CreateQuote(&request)
+		if fxErr, ok := err.(services.FxError); ok {
+			renderFxError(router, w, r, "", fxErr)
+		} else if err != nil {
+			renderInternalError(router, w, r)
+		} else {
+			render.JSON(w, r, quote)
+		}
+	})
+}
+
+func setGetQuoteById(router *chi.Mux, fxService services.FxService) {
+	router.Get("/{"+quoteIdParam+"}", func(w http.ResponseWriter, r *http.Request) {
+		quote, err := fxService.GetQuote(chi.URLParam(r, quoteIdParam))
+		if err != nil {
+			renderInternalError(router, w, r)
+		} else if quote != nil {
+			render.JSON(w, r, quote)
+		} else {
+			render.Status(r, 404)
+			render.JSON(w, r, QuoteNotFound)
+		}
+	})
+}
+
+func renderFxError(router *chi.Mux, w http.ResponseWriter, r *http.Request, prefix string, err services.FxError) {
+	renderBadRequest(router, w, r, []*types.FieldError{{Field: prefix + err.Field, Message: err.Message}})
+}
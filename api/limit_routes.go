@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/brunovale91/payment-api/services"
+	"github.com/brunovale91/payment-api/types"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+)
+
+func addLimitRoutes(paymentService services.PaymentService) *chi.Mux {
+	router := chi.NewRouter()
+	setGetLimitUsage(router, paymentService)
+	return router
+}
+
+// Limits of the organisation given as a query parameter and their usage
+func setGetLimitUsage(router *chi.Mux, paymentService services.PaymentService) {
+	router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		organisationId := r.URL.Query().Get(organisationIdParam)
+		if organisationId == "" {
+			renderBadRequest(router, w, r, []*types.FieldError{{
+				Field:   organisationIdParam,
+				Message: "organisation_id is required",
+			}})
+			return
+		}
+		usage, err := paymentService.GetLimitUsage(organisationId)
+		if err != nil {
+			renderInternalError(router, w, r)
+		} else {
+			render.JSON(w, r, usage)
+		}
+	})
+}
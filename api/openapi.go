@@ -0,0 +1,869 @@
+package api
+
+//go:generate go run swaggerui/generate.go
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/brunovale91/payment-api/iso20022"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+)
+
+const swaggerUIFileParam = "file"
+
+// Interactive docs page, the spec and the Swagger UI files are served by this
+// api
+const docsPage = `<!DOCTYPE html>
+<html>
+<head>
+  <title>Payment API</title>
+  <meta charset="utf-8"/>
+  <link rel="stylesheet" href="swagger-ui/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="swagger-ui/swagger-ui-bundle.js"></script>
+  <script>
+    SwaggerUIBundle({url: "openapi.json", dom_id: "#swagger-ui"});
+  </script>
+</body>
+</html>`
+
+func setOpenApi(router chi.Router, version string) {
+	spec := getOpenApiSpec(version)
+	router.Get("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, spec)
+	})
+	router.Get("/docs", func(w http.ResponseWriter, r *http.Request) {
+		render.HTML(w, r, docsPage)
+	})
+	router.Get("/swagger-ui/{"+swaggerUIFileParam+"}", func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, swaggerUIFileParam)
+		content, ok := swaggerUIFiles[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(name)))
+		w.Write([]byte(content))
+	})
+}
+
+// OpenAPI 3 document built from the validation schemas, so the published
+// contract and the enforced one cannot drift
+func getOpenApiSpec(version string) map[string]interface{} {
+	paymentsPath := "/" + version + "/api/payments"
+	reconciliationsPath := "/" + version + "/api/reconciliations"
+	limitsPath := "/" + version + "/api/limits"
+	standingOrdersPath := "/" + version + "/api/standing-orders"
+	calendarsPath := "/" + version + "/api/calendars"
+	fxRatesPath := "/" + version + "/api/fx-rates"
+	quotesPath := "/" + version + "/api/quotes"
+	accountsPath := "/" + version + "/api/accounts"
+	beneficiariesPath := "/" + version + "/api/beneficiaries"
+	schemas := map[string]interface{}{
+		"PaymentUpdate": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"attributes": schemaRef("PaymentAttributes"),
+			},
+			"required": []string{"attributes"},
+		},
+		"Payments": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"data": map[string]interface{}{
+					"type":  "array",
+					"items": schemaRef("Payment"),
+				},
+				"links": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"self": map[string]interface{}{"type": "string"},
+					},
+				},
+			},
+		},
+		"PaymentDelete": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"deleted": map[string]interface{}{"type": "boolean"},
+			},
+		},
+		"PaymentEvent": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id":              map[string]interface{}{"type": "string"},
+				"type":            map[string]interface{}{"type": "string"},
+				"payment_id":      map[string]interface{}{"type": "string"},
+				"organisation_id": map[string]interface{}{"type": "string"},
+				"payment":         schemaRef("Payment"),
+				"created_at":      map[string]interface{}{"type": "string", "format": "date-time"},
+				"sequence":        map[string]interface{}{"type": "integer", "format": "int64"},
+			},
+		},
+		"StatementEntry": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"statement_id":         map[string]interface{}{"type": "string"},
+				"account":              map[string]interface{}{"type": "string"},
+				"account_bank_id":      map[string]interface{}{"type": "string"},
+				"entry_reference":      map[string]interface{}{"type": "string"},
+				"end_to_end_reference": map[string]interface{}{"type": "string"},
+				"amount":               map[string]interface{}{"type": "number"},
+				"currency":             map[string]interface{}{"type": "string"},
+				"credit_debit":         map[string]interface{}{"type": "string", "enum": []string{"CRDT", "DBIT"}},
+				"booking_date":         map[string]interface{}{"type": "string", "format": "date"},
+				"counterparty_name":    map[string]interface{}{"type": "string"},
+				"counterparty_account": map[string]interface{}{"type": "string"},
+			},
+		},
+		"ReconciliationReport": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id":              map[string]interface{}{"type": "string"},
+				"organisation_id": map[string]interface{}{"type": "string"},
+				"statement_id":    map[string]interface{}{"type": "string"},
+				"created_at":      map[string]interface{}{"type": "string", "format": "date-time"},
+				"matched": map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"entry":      schemaRef("StatementEntry"),
+							"payment_id": map[string]interface{}{"type": "string"},
+						},
+					},
+				},
+				"unmatched": map[string]interface{}{
+					"type":  "array",
+					"items": schemaRef("StatementEntry"),
+				},
+				"ambiguous": map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"entry": schemaRef("StatementEntry"),
+							"payment_ids": map[string]interface{}{
+								"type":  "array",
+								"items": map[string]interface{}{"type": "string"},
+							},
+						},
+					},
+				},
+			},
+		},
+		"ReconciliationReports": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"data": map[string]interface{}{
+					"type":  "array",
+					"items": schemaRef("ReconciliationReport"),
+				},
+			},
+		},
+		"BusinessDay": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"calendar_id":       map[string]interface{}{"type": "string"},
+				"date":              map[string]interface{}{"type": "string", "format": "date"},
+				"next_business_day": map[string]interface{}{"type": "string", "format": "date"},
+			},
+		},
+		"FxRate": map[string]interface{}{
+			"type":     "object",
+			"required": []string{"base_currency", "quote_currency", "rate"},
+			"properties": map[string]interface{}{
+				"id":             map[string]interface{}{"type": "string", "readOnly": true},
+				"base_currency":  map[string]interface{}{"type": "string", "pattern": "^[A-Z]{3}$"},
+				"quote_currency": map[string]interface{}{"type": "string", "pattern": "^[A-Z]{3}$"},
+				"rate":           map[string]interface{}{"type": "number"},
+				"effective_at":   map[string]interface{}{"type": "string", "format": "date-time"},
+				"created_at":     map[string]interface{}{"type": "string", "format": "date-time", "readOnly": true},
+			},
+		},
+		"FxRates": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"data": map[string]interface{}{
+					"type":  "array",
+					"items": schemaRef("FxRate"),
+				},
+			},
+		},
+		"QuoteRequest": map[string]interface{}{
+			"type":     "object",
+			"required": []string{"instructed_currency", "instructed_amount", "settlement_currency"},
+			"properties": map[string]interface{}{
+				"instructed_currency": map[string]interface{}{"type": "string", "pattern": "^[A-Z]{3}$"},
+				"instructed_amount":   map[string]interface{}{"type": "number"},
+				"settlement_currency": map[string]interface{}{"type": "string", "pattern": "^[A-Z]{3}$"},
+			},
+		},
+		"FxQuote": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id":                  map[string]interface{}{"type": "string"},
+				"instructed_currency": map[string]interface{}{"type": "string"},
+				"instructed_amount":   map[string]interface{}{"type": "number"},
+				"settlement_currency": map[string]interface{}{"type": "string"},
+				"settlement_amount":   map[string]interface{}{"type": "number"},
+				"rate":                map[string]interface{}{"type": "number"},
+				"rate_id":             map[string]interface{}{"type": "string"},
+				"created_at":          map[string]interface{}{"type": "string", "format": "date-time"},
+				"expires_at":          map[string]interface{}{"type": "string", "format": "date-time"},
+				"payment_id":          map[string]interface{}{"type": "string"},
+			},
+		},
+		"Accounts": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"data": map[string]interface{}{
+					"type":  "array",
+					"items": schemaRef("Account"),
+				},
+			},
+		},
+		"AccountDelete": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"deleted": map[string]interface{}{"type": "boolean"},
+			},
+		},
+		"Beneficiaries": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"data": map[string]interface{}{
+					"type":  "array",
+					"items": schemaRef("Beneficiary"),
+				},
+			},
+		},
+		"BeneficiaryDelete": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"deleted": map[string]interface{}{"type": "boolean"},
+			},
+		},
+		"LedgerEntry": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id":             map[string]interface{}{"type": "string"},
+				"transaction_id": map[string]interface{}{"type": "string"},
+				"payment_id":     map[string]interface{}{"type": "string"},
+				"event_type":     map[string]interface{}{"type": "string"},
+				"account_id":     map[string]interface{}{"type": "string"},
+				"currency":       map[string]interface{}{"type": "string"},
+				"direction":      map[string]interface{}{"type": "string", "enum": []string{"debit", "credit"}},
+				"amount":         map[string]interface{}{"type": "number"},
+				"created_at":     map[string]interface{}{"type": "string", "format": "date-time"},
+			},
+		},
+		"LedgerEntries": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"data": map[string]interface{}{
+					"type":  "array",
+					"items": schemaRef("LedgerEntry"),
+				},
+			},
+		},
+		"AccountBalance": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"account_id": map[string]interface{}{"type": "string"},
+				"balances": map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"currency": map[string]interface{}{"type": "string"},
+							"debits":   map[string]interface{}{"type": "number"},
+							"credits":  map[string]interface{}{"type": "number"},
+							"balance":  map[string]interface{}{"type": "number"},
+						},
+					},
+				},
+			},
+		},
+		"StandingOrders": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"data": map[string]interface{}{
+					"type":  "array",
+					"items": schemaRef("StandingOrder"),
+				},
+			},
+		},
+		"LimitUsage": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"organisation_id": map[string]interface{}{"type": "string"},
+				"limits": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"max_amount":     map[string]interface{}{"type": "number"},
+						"daily_amount":   map[string]interface{}{"type": "number"},
+						"monthly_amount": map[string]interface{}{"type": "number"},
+						"hourly_count":   map[string]interface{}{"type": "integer"},
+					},
+				},
+				"currencies": map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"currency":       map[string]interface{}{"type": "string"},
+							"daily_amount":   map[string]interface{}{"type": "number"},
+							"monthly_amount": map[string]interface{}{"type": "number"},
+						},
+					},
+				},
+				"hourly_count": map[string]interface{}{"type": "integer"},
+			},
+		},
+		"CancellationRequest": map[string]interface{}{
+			"type":     "object",
+			"required": []string{"reason_code"},
+			"properties": map[string]interface{}{
+				"reason_code": map[string]interface{}{"type": "string", "enum": reasonCodes(iso20022.CancellationReasons)},
+			},
+		},
+		"RefundRequest": map[string]interface{}{
+			"type":     "object",
+			"required": []string{"reason_code"},
+			"properties": map[string]interface{}{
+				"amount":               map[string]interface{}{"type": "number"},
+				"reason_code":          map[string]interface{}{"type": "string", "enum": reasonCodes(iso20022.ReturnReasons)},
+				"end_to_end_reference": map[string]interface{}{"type": "string"},
+			},
+		},
+		"ChargesInformation": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"bearer_code": map[string]interface{}{"type": "string", "enum": []string{"DEBT", "CRED", "SHAR", "SLEV"}},
+				"sender_charges": map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"amount":   map[string]interface{}{"type": "number"},
+							"currency": map[string]interface{}{"type": "string"},
+						},
+					},
+				},
+				"receiver_charges_amount":   map[string]interface{}{"type": "number"},
+				"receiver_charges_currency": map[string]interface{}{"type": "string"},
+			},
+		},
+		"PaymentSchedule": map[string]interface{}{
+			"type":     "object",
+			"required": []string{"execution_date"},
+			"properties": map[string]interface{}{
+				"execution_date": map[string]interface{}{"type": "string", "format": "date-time"},
+			},
+		},
+		"HttpError": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"status": map[string]interface{}{"type": "string"},
+				"messages": map[string]interface{}{
+					"type":  "array",
+					"items": map[string]interface{}{"type": "string"},
+				},
+				"existing_id": map[string]interface{}{"type": "string"},
+				"limit":       map[string]interface{}{"type": "string"},
+				"errors": map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"field":   map[string]interface{}{"type": "string"},
+							"message": map[string]interface{}{"type": "string"},
+						},
+					},
+				},
+			},
+		},
+	}
+	for file, document := range getSchemaDocuments(version) {
+		schemas[componentName(file)] = toOpenApiSchema(document)
+		if definitions, ok := document["definitions"].(map[string]interface{}); ok {
+			for name, definition := range definitions {
+				schemas[componentName(name)] = toOpenApiSchema(definition.(map[string]interface{}))
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.2",
+		"info": map[string]interface{}{
+			"title":   "Payment API",
+			"version": strings.TrimPrefix(version, "v") + ".0.0",
+		},
+		"paths": map[string]interface{}{
+			paymentsPath: map[string]interface{}{
+				"get": withParameters(operation("List payments", nil, "Payments"), getFilterParameters()),
+				"post": withParameters(operation("Create payment",
+					requestBody("Payment"), "Payment", badRequestResponse(), conflictResponse(), limitExceededResponse()),
+					[]interface{}{headerParameter(userIdHeader)}),
+			},
+			paymentsPath + "/{" + paymentIdParam + "}": map[string]interface{}{
+				"parameters": []interface{}{pathParameter(paymentIdParam)},
+				"get":        operation("Get payment", nil, "Payment", notFoundResponse()),
+				"put": withParameters(operation("Update payment attributes",
+					requestBody("PaymentUpdate"), "Payment", badRequestResponse(), notFoundResponse(), conflictResponse(),
+					limitExceededResponse()), []interface{}{headerParameter(userIdHeader)}),
+				"delete": operation("Delete payment unless settled", nil, "PaymentDelete", notFoundResponse(), conflictResponse()),
+			},
+			paymentsPath + "/{" + paymentIdParam + "}/approvals": map[string]interface{}{
+				"parameters": []interface{}{pathParameter(paymentIdParam)},
+				"post": withParameters(operation("Approve payment pending approval", nil, "Payment",
+					badRequestResponse(), forbiddenResponse(), notFoundResponse(), conflictResponse()),
+					[]interface{}{requiredHeaderParameter(userIdHeader)}),
+			},
+			paymentsPath + "/{" + paymentIdParam + "}/cancellation": map[string]interface{}{
+				"parameters": []interface{}{pathParameter(paymentIdParam)},
+				"post": withParameters(operation("Cancel payment, keeping its record",
+					requestBody("CancellationRequest"), "Payment", badRequestResponse(), notFoundResponse(), conflictResponse()),
+					[]interface{}{headerParameter(userIdHeader)}),
+			},
+			paymentsPath + "/{" + paymentIdParam + "}/refunds": map[string]interface{}{
+				"parameters": []interface{}{pathParameter(paymentIdParam)},
+				"post": withParameters(operation("Refund payment to its debtor",
+					requestBody("RefundRequest"), "Payment", badRequestResponse(), notFoundResponse(), conflictResponse(),
+					limitExceededResponse()), []interface{}{headerParameter(userIdHeader)}),
+			},
+			paymentsPath + "/{" + paymentIdParam + "}/schedule": map[string]interface{}{
+				"parameters": []interface{}{pathParameter(paymentIdParam)},
+				"put": operation("Reschedule payment not released yet",
+					requestBody("PaymentSchedule"), "Payment", badRequestResponse(), notFoundResponse(), conflictResponse()),
+				"delete": withParameters(operation("Cancel scheduled payment, keeping its record",
+					requestBody("CancellationRequest"), "Payment", badRequestResponse(), notFoundResponse(), conflictResponse()),
+					[]interface{}{headerParameter(userIdHeader)}),
+			},
+			paymentsPath + "/references/{" + referenceParam + "}": map[string]interface{}{
+				"get": withParameters(operation("Get payment by end to end reference", nil, "Payment",
+					badRequestResponse(), notFoundResponse()),
+					[]interface{}{pathParameter(referenceParam), requiredQueryParameter(organisationIdParam)}),
+			},
+			paymentsPath + "/export/pain.001": map[string]interface{}{
+				"get": exportOperation("Export payments as an ISO 20022 pain.001.001.09 document",
+					"CustomerCreditTransferInitiation document", "application/xml"),
+			},
+			paymentsPath + "/export/mt103": map[string]interface{}{
+				"get": exportOperation("Export payments as SWIFT MT103 messages",
+					"Consecutive MT103 messages", "text/plain"),
+			},
+			paymentsPath + "/export/bacs": map[string]interface{}{
+				"get": exportOperation("Export payments as a Bacs Standard 18 file",
+					"Standard 18 file of credits with a contra record per originating account", "text/plain"),
+			},
+			paymentsPath + "/export/nacha": map[string]interface{}{
+				"get": exportOperation("Export payments as a NACHA ACH file",
+					"ACH file with a single batch of credits", "text/plain"),
+			},
+			paymentsPath + "/import/mt103": map[string]interface{}{
+				"post": withParameters(operation("Create payments from SWIFT MT103 messages",
+					map[string]interface{}{
+						"required": true,
+						"content":  textContent(),
+					}, "Payments", badRequestResponse(), conflictResponse(), limitExceededResponse()),
+					[]interface{}{queryParameter(organisationIdParam), headerParameter(userIdHeader)}),
+			},
+			paymentsPath + "/fee-estimate": map[string]interface{}{
+				"post": operation("Estimate the charges of a draft payment without creating it",
+					requestBody("Payment"), "ChargesInformation", badRequestResponse()),
+			},
+			paymentsPath + "/stream": map[string]interface{}{
+				"get": getStreamOperation(),
+			},
+			limitsPath: map[string]interface{}{
+				"get": withParameters(operation("Get organisation limits and their usage", nil, "LimitUsage",
+					badRequestResponse()), []interface{}{requiredQueryParameter(organisationIdParam)}),
+			},
+			reconciliationsPath: map[string]interface{}{
+				"get": operation("List reconciliation reports", nil, "ReconciliationReports"),
+				"post": withParameters(operation("Reconcile payments of an organisation against an ISO 20022 camt.053 statement",
+					map[string]interface{}{
+						"required": true,
+						"content": map[string]interface{}{
+							"application/xml": map[string]interface{}{
+								"schema": map[string]interface{}{"type": "string"},
+							},
+						},
+					}, "ReconciliationReport", badRequestResponse(), conflictResponse()),
+					[]interface{}{requiredQueryParameter(organisationIdParam)}),
+			},
+			calendarsPath + "/{" + calendarIdParam + "}/next-business-day": map[string]interface{}{
+				"get": withParameters(operation("Get the next business day after a date, today by default", nil,
+					"BusinessDay", badRequestResponse(), map[string]interface{}{"404": errorResponse(CalendarNotFound.StatusText)}),
+					[]interface{}{pathParameter(calendarIdParam), queryParameter(dateParam)}),
+			},
+			fxRatesPath: map[string]interface{}{
+				"get": withParameters(operation("List exchange rates, latest effective first", nil, "FxRates"),
+					[]interface{}{queryParameter(baseCurrencyParam), queryParameter(quoteCurrencyParam)}),
+				"post": operation("Upload exchange rates, effective now unless given an effective time",
+					requestBody("FxRates"), "FxRates", badRequestResponse()),
+			},
+			fxRatesPath + "/import": map[string]interface{}{
+				"post": operation("Upload exchange rates from a CSV file of base_currency,quote_currency,rate,effective_at lines",
+					map[string]interface{}{
+						"required": true,
+						"content": map[string]interface{}{
+							"text/csv": map[string]interface{}{
+								"schema": map[string]interface{}{"type": "string"},
+							},
+						},
+					}, "FxRates", badRequestResponse()),
+			},
+			quotesPath: map[string]interface{}{
+				"post": operation("Quote the current exchange rate, guaranteed until the quote expires",
+					requestBody("QuoteRequest"), "FxQuote", badRequestResponse()),
+			},
+			quotesPath + "/{" + quoteIdParam + "}": map[string]interface{}{
+				"get": withParameters(operation("Get quote", nil, "FxQuote",
+					map[string]interface{}{"404": errorResponse(QuoteNotFound.StatusText)}),
+					[]interface{}{pathParameter(quoteIdParam)}),
+			},
+			accountsPath: map[string]interface{}{
+				"get": withParameters(operation("List saved accounts", nil, "Accounts"),
+					[]interface{}{queryParameter(organisationIdParam)}),
+				"post": operation("Save account for payments to reference by debtor_account_id or beneficiary_account_id",
+					requestBody("Account"), "Account", badRequestResponse()),
+			},
+			accountsPath + "/{" + accountIdParam + "}": map[string]interface{}{
+				"parameters": []interface{}{pathParameter(accountIdParam)},
+				"get":        operation("Get saved account", nil, "Account", accountNotFoundResponse()),
+				"put": operation("Update saved account, payments keep the details they were created with",
+					requestBody("Account"), "Account", badRequestResponse(), accountNotFoundResponse(), conflictResponse()),
+				"delete": operation("Delete saved account", nil, "AccountDelete", accountNotFoundResponse()),
+			},
+			accountsPath + "/{" + accountIdParam + "}/balance": map[string]interface{}{
+				"get": withParameters(operation("Get balance per currency of a saved or ledger account, credits less debits",
+					nil, "AccountBalance", accountNotFoundResponse()),
+					[]interface{}{pathParameter(accountIdParam)}),
+			},
+			accountsPath + "/{" + accountIdParam + "}/entries": map[string]interface{}{
+				"get": withParameters(operation("List entries of a saved or ledger account, latest first", nil, "LedgerEntries"),
+					[]interface{}{pathParameter(accountIdParam), queryParameter(currencyParam)}),
+			},
+			beneficiariesPath: map[string]interface{}{
+				"get": withParameters(operation("List beneficiaries of an organisation, most used first", nil, "Beneficiaries",
+					badRequestResponse()), []interface{}{requiredQueryParameter(organisationIdParam), queryParameter(nameParam)}),
+				"post": withParameters(operation("Save unverified beneficiary for payments to reference by beneficiary_id",
+					requestBody("Beneficiary"), "Beneficiary", badRequestResponse()),
+					[]interface{}{requiredHeaderParameter(userIdHeader)}),
+			},
+			beneficiariesPath + "/{" + beneficiaryIdParam + "}": map[string]interface{}{
+				"parameters": []interface{}{pathParameter(beneficiaryIdParam)},
+				"get":        operation("Get beneficiary", nil, "Beneficiary", beneficiaryNotFoundResponse()),
+				"put": withParameters(operation("Update beneficiary, unverified until verified again",
+					requestBody("Beneficiary"), "Beneficiary", badRequestResponse(), beneficiaryNotFoundResponse(), conflictResponse()),
+					[]interface{}{requiredHeaderParameter(userIdHeader)}),
+				"delete": operation("Delete beneficiary", nil, "BeneficiaryDelete", beneficiaryNotFoundResponse()),
+			},
+			beneficiariesPath + "/{" + beneficiaryIdParam + "}/verification": map[string]interface{}{
+				"parameters": []interface{}{pathParameter(beneficiaryIdParam)},
+				"post": withParameters(operation("Verify beneficiary, by another user than the one that saved or last edited it",
+					nil, "Beneficiary", badRequestResponse(), forbiddenResponse(), beneficiaryNotFoundResponse(), conflictResponse()),
+					[]interface{}{requiredHeaderParameter(userIdHeader)}),
+			},
+			standingOrdersPath: map[string]interface{}{
+				"get": withParameters(operation("List standing orders", nil, "StandingOrders"),
+					[]interface{}{queryParameter(organisationIdParam)}),
+				"post": withParameters(operation("Create standing order",
+					requestBody("StandingOrder"), "StandingOrder", badRequestResponse()),
+					[]interface{}{headerParameter(userIdHeader)}),
+			},
+			standingOrdersPath + "/{" + standingOrderIdParam + "}": map[string]interface{}{
+				"parameters": []interface{}{pathParameter(standingOrderIdParam)},
+				"get":        operation("Get standing order", nil, "StandingOrder", standingOrderNotFoundResponse()),
+			},
+			standingOrdersPath + "/{" + standingOrderIdParam + "}/pause": map[string]interface{}{
+				"parameters": []interface{}{pathParameter(standingOrderIdParam)},
+				"post": operation("Pause standing order", nil, "StandingOrder",
+					standingOrderNotFoundResponse(), conflictResponse()),
+			},
+			standingOrdersPath + "/{" + standingOrderIdParam + "}/resume": map[string]interface{}{
+				"parameters": []interface{}{pathParameter(standingOrderIdParam)},
+				"post": operation("Resume standing order, skipping occurrences due while paused", nil, "StandingOrder",
+					standingOrderNotFoundResponse(), conflictResponse()),
+			},
+			reconciliationsPath + "/{" + reconciliationIdParam + "}": map[string]interface{}{
+				"parameters": []interface{}{pathParameter(reconciliationIdParam)},
+				"get": operation("Get reconciliation report", nil, "ReconciliationReport",
+					map[string]interface{}{"404": errorResponse(ReportNotFound.StatusText)}),
+			},
+		},
+		"components": map[string]interface{}{
+			"schemas": schemas,
+		},
+	}
+}
+
+// Filtered payments rendered as a file, 400 when a payment cannot be
+// expressed in its format
+func exportOperation(summary string, description string, contentType string) map[string]interface{} {
+	return withParameters(map[string]interface{}{
+		"summary": summary,
+		"responses": map[string]interface{}{
+			"200": map[string]interface{}{
+				"description": description,
+				"content": map[string]interface{}{
+					contentType: map[string]interface{}{
+						"schema": map[string]interface{}{"type": "string"},
+					},
+				},
+			},
+			"400": errorResponse(BadRequest.StatusText),
+			"500": errorResponse(InternalError.StatusText),
+		},
+	}, getFilterParameters())
+}
+
+func getStreamOperation() map[string]interface{} {
+	return map[string]interface{}{
+		"summary": "Stream payment events of an organisation",
+		"parameters": []interface{}{
+			requiredQueryParameter(organisationIdParam),
+			map[string]interface{}{
+				"name":   "Last-Event-ID",
+				"in":     "header",
+				"schema": map[string]interface{}{"type": "string"},
+			},
+		},
+		"responses": map[string]interface{}{
+			"200": map[string]interface{}{
+				"description": "Server-Sent Events, each data line is a PaymentEvent",
+				"content": map[string]interface{}{
+					"text/event-stream": map[string]interface{}{
+						"schema": map[string]interface{}{"type": "string"},
+					},
+				},
+			},
+			"400": errorResponse(BadRequest.StatusText),
+			"500": errorResponse(InternalError.StatusText),
+		},
+	}
+}
+
+// Query parameters of the payment listing filter
+func getFilterParameters() []interface{} {
+	parameters := []interface{}{
+		map[string]interface{}{
+			"name": "id",
+			"in":   "query",
+			"schema": map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{"type": "string"},
+			},
+		},
+		queryParameter(organisationIdParam),
+	}
+	for _, party := range []string{"debtor_party", "beneficiary_party"} {
+		for _, field := range []string{"bank_id", "account_number", "account_name", "country"} {
+			parameters = append(parameters, queryParameter(party+"."+field))
+		}
+	}
+	return parameters
+}
+
+func withParameters(op map[string]interface{}, parameters []interface{}) map[string]interface{} {
+	op["parameters"] = parameters
+	return op
+}
+
+func queryParameter(name string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":   name,
+		"in":     "query",
+		"schema": map[string]interface{}{"type": "string"},
+	}
+}
+
+func requiredQueryParameter(name string) map[string]interface{} {
+	parameter := queryParameter(name)
+	parameter["required"] = true
+	return parameter
+}
+
+func headerParameter(name string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":   name,
+		"in":     "header",
+		"schema": map[string]interface{}{"type": "string"},
+	}
+}
+
+func requiredHeaderParameter(name string) map[string]interface{} {
+	parameter := headerParameter(name)
+	parameter["required"] = true
+	return parameter
+}
+
+func operation(summary string, body map[string]interface{}, schema string, responses ...map[string]interface{}) map[string]interface{} {
+	allResponses := map[string]interface{}{
+		"200": map[string]interface{}{
+			"description": "OK",
+			"content":     jsonContent(schemaRef(schema)),
+		},
+		"500": errorResponse(InternalError.StatusText),
+	}
+	for _, response := range responses {
+		for status, value := range response {
+			allResponses[status] = value
+		}
+	}
+	op := map[string]interface{}{
+		"summary":   summary,
+		"responses": allResponses,
+	}
+	if body != nil {
+		op["requestBody"] = body
+	}
+	return op
+}
+
+func requestBody(schema string) map[string]interface{} {
+	return map[string]interface{}{
+		"required": true,
+		"content":  jsonContent(schemaRef(schema)),
+	}
+}
+
+func pathParameter(name string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":     name,
+		"in":       "path",
+		"required": true,
+		"schema":   map[string]interface{}{"type": "string"},
+	}
+}
+
+func badRequestResponse() map[string]interface{} {
+	return map[string]interface{}{"400": errorResponse(BadRequest.StatusText)}
+}
+
+func notFoundResponse() map[string]interface{} {
+	return map[string]interface{}{"404": errorResponse(NotFound.StatusText)}
+}
+
+func standingOrderNotFoundResponse() map[string]interface{} {
+	return map[string]interface{}{"404": errorResponse(StandingOrderNotFound.StatusText)}
+}
+
+func accountNotFoundResponse() map[string]interface{} {
+	return map[string]interface{}{"404": errorResponse(AccountNotFound.StatusText)}
+}
+
+func beneficiaryNotFoundResponse() map[string]interface{} {
+	return map[string]interface{}{"404": errorResponse(BeneficiaryNotFound.StatusText)}
+}
+
+func forbiddenResponse() map[string]interface{} {
+	return map[string]interface{}{"403": errorResponse(Forbidden.StatusText)}
+}
+
+func conflictResponse() map[string]interface{} {
+	return map[string]interface{}{"409": errorResponse(Conflict.StatusText)}
+}
+
+func limitExceededResponse() map[string]interface{} {
+	return map[string]interface{}{"422": errorResponse(LimitExceeded.StatusText)}
+}
+
+func errorResponse(description string) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content":     jsonContent(schemaRef("HttpError")),
+	}
+}
+
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{
+			"schema": schema,
+		},
+	}
+}
+
+func textContent() map[string]interface{} {
+	return map[string]interface{}{
+		"text/plain": map[string]interface{}{
+			"schema": map[string]interface{}{"type": "string"},
+		},
+	}
+}
+
+func schemaRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// Component name of a schema file, payment_party.json is PaymentParty
+func reasonCodes(reasons map[string]string) []string {
+	codes := make([]string, 0, len(reasons))
+	for code := range reasons {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+func componentName(file string) string {
+	words := strings.Split(strings.TrimSuffix(path.Base(file), ".json"), "_")
+	for i, word := range words {
+		words[i] = strings.Title(word)
+	}
+	return strings.Join(words, "")
+}
+
+// Copy a JSON schema converting keywords that differ in OpenAPI 3.0: file
+// and definition references become component references, $id and $schema
+// are dropped, exclusiveMinimum only has a boolean form and if/then
+// conditionals and dependencies, which OpenAPI cannot express, are left out
+func toOpenApiSchema(schema map[string]interface{}) map[string]interface{} {
+	converted := make(map[string]interface{}, len(schema))
+	for key, value := range schema {
+		switch typed := value.(type) {
+		case map[string]interface{}:
+			converted[key] = toOpenApiSchema(typed)
+		case []interface{}:
+			items := make([]interface{}, 0, len(typed))
+			for _, item := range typed {
+				if itemSchema, ok := item.(map[string]interface{}); !ok {
+					items = append(items, item)
+				} else if _, conditional := itemSchema["if"]; !conditional {
+					items = append(items, toOpenApiSchema(itemSchema))
+				}
+			}
+			if len(items) > 0 {
+				converted[key] = items
+			}
+		default:
+			converted[key] = value
+		}
+	}
+	delete(converted, "$id")
+	delete(converted, "$schema")
+	delete(converted, "definitions")
+	delete(converted, "dependencies")
+	if value, ok := converted["const"]; ok {
+		converted["enum"] = []interface{}{value}
+		delete(converted, "const")
+	}
+	if ref, ok := converted["$ref"].(string); ok {
+		converted["$ref"] = "#/components/schemas/" + componentName(ref)
+	}
+	if minimum, ok := converted["exclusiveMinimum"].(float64); ok {
+		converted["minimum"] = minimum
+		converted["exclusiveMinimum"] = true
+	}
+	return converted
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+
+	"github.com/brunovale91/payment-api/iso20022"
+	"github.com/brunovale91/payment-api/services"
+	"github.com/brunovale91/payment-api/types"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+)
+
+const reconciliationIdParam = "reconciliationID"
+
+func addReconciliationRoutes(reconciliationService services.ReconciliationService) *chi.Mux {
+	router := chi.NewRouter()
+	setReconcileStatement(router, reconciliationService)
+	setGetReports(router, reconciliationService)
+	setGetReportById(router, reconciliationService)
+	return router
+}
+
+// Reconcile payments of the organisation given as a query parameter against a
+// camt.053 statement sent as the request body
+func setReconcileStatement(router *chi.Mux, reconciliationService services.ReconciliationService) {
+	router.Post("/", func(w http.ResponseWriter, r *http.Request) {
+		organisationId := r.URL.Query().Get(organisationIdParam)
+		if organisationId == "" {
+			renderBadRequest(router, w, r, []*types.FieldError{{
+				Field:   organisationIdParam,
+				Message: "organisation_id is required",
+			}})
+			return
+		}
+		statement, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			renderBadRequest(router, w, r, []*types.FieldError{{Message: "Failed to read statement"}})
+			return
+		}
+		report, err := reconciliationService.ReconcileStatement(organisationId, statement)
+		if formatErr, ok := err.(iso20022.FormatError); ok {
+			renderBadRequest(router, w, r, []*types.FieldError{{Message: formatErr.Message}})
+		} else if stateErr, ok := err.(services.StateError); ok {
+			renderConflict(router, w, r, stateErr.Message, "")
+		} else if err != nil {
+			renderInternalError(router, w, r)
+		} else {
+			render.JSON(w, r, report)
+		}
+	})
+}
+
+func setGetReports(router *chi.Mux, reconciliationService services.ReconciliationService) {
+	router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		reports, err := reconciliationService.GetReports()
+		if err != nil {
+			renderInternalError(router, w, r)
+		} else {
+			render.JSON(w, r, &types.ReconciliationReports{Data: reports})
+		}
+	})
+}
+
+func setGetReportById(router *chi.Mux, reconciliationService services.ReconciliationService) {
+	router.Get("/{"+reconciliationIdParam+"}", func(w http.ResponseWriter, r *http.Request) {
+		report, err := reconciliationService.GetReport(chi.URLParam(r, reconciliationIdParam))
+		if err != nil {
+			renderInternalError(router, w, r)
+		} else if report != nil {
+			render.JSON(w, r, report)
+		} else {
+			render.Status(r, 404)
+			render.JSON(w, r, ReportNotFound)
+		}
+	})
+}
@@ -2,11 +2,18 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
+	"github.com/brunovale91/payment-api/batchfile"
+	"github.com/brunovale91/payment-api/iso20022"
 	"github.com/brunovale91/payment-api/services"
+	"github.com/brunovale91/payment-api/swift"
 	"github.com/brunovale91/payment-api/types"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
@@ -14,13 +21,39 @@ import (
 )
 
 const paymentIdParam = "paymentID"
+const referenceParam = "reference"
+
+// Identity of the user making a request, recorded as payment creator, editor
+// and approver
+const userIdHeader = "X-User-Id"
+const organisationIdParam = "organisation_id"
+const streamHeartbeat = 15 * time.Second
 
 var InternalError = &types.HttpError{StatusText: "Internal Error"}
 var BadRequest = &types.HttpError{StatusText: "Bad request"}
 var NotFound = &types.HttpError{StatusText: "Payment not found"}
+var Conflict = &types.HttpError{StatusText: "Conflict"}
+var LimitExceeded = &types.HttpError{StatusText: "Limit exceeded"}
+var Forbidden = &types.HttpError{StatusText: "Forbidden"}
+var ReportNotFound = &types.HttpError{StatusText: "Reconciliation report not found"}
 var paymentsSelf = "http://localhost:8080/v1/api/payments"
 
-func NewApiRouter(paymentService services.PaymentService) *chi.Mux {
+// Services the api routes delegate to
+type Services struct {
+	Payments        services.PaymentService
+	Events          services.EventService
+	Export          services.ExportService
+	Reconciliations services.ReconciliationService
+	StandingOrders  services.StandingOrderService
+	Calendars       services.CalendarService
+	Fx              services.FxService
+	Ledger          services.LedgerService
+	Accounts        services.AccountService
+	Beneficiaries   services.BeneficiaryService
+}
+
+// Mount the api once per validator, under its schema version
+func NewApiRouter(apiServices *Services, validators ...PaymentValidator) *chi.Mux {
 	router := chi.NewRouter()
 	router.Use(
 		render.SetContentType(render.ContentTypeJSON),
@@ -29,12 +62,28 @@ func NewApiRouter(paymentService services.PaymentService) *chi.Mux {
 		middleware.DefaultCompress,
 		middleware.RedirectSlashes,
 		middleware.Logger,
-		middleware.Recoverer,
-		middleware.Timeout(60*time.Second))
+		middleware.Recoverer)
 
-	router.Route("/v1", func(r chi.Router) {
-		r.Mount("/api/payments", addRoutes(paymentService))
-	})
+	for _, validator := range validators {
+		version := validator.Version()
+		router.Route("/"+version, func(r chi.Router) {
+			// Event streams are long lived, they are not cut by the timeout
+			r.Mount("/api/payments/stream", addStreamRoutes(apiServices.Events))
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.Timeout(60 * time.Second))
+				setOpenApi(r, version)
+				r.Mount("/api/payments", addRoutes(apiServices.Payments, apiServices.Export, validator))
+				r.Mount("/api/reconciliations", addReconciliationRoutes(apiServices.Reconciliations))
+				r.Mount("/api/limits", addLimitRoutes(apiServices.Payments))
+				r.Mount("/api/standing-orders", addStandingOrderRoutes(apiServices.StandingOrders, validator))
+				r.Mount("/api/calendars", addCalendarRoutes(apiServices.Calendars))
+				r.Mount("/api/fx-rates", addFxRateRoutes(apiServices.Fx))
+				r.Mount("/api/quotes", addQuoteRoutes(apiServices.Fx))
+				r.Mount("/api/accounts", addAccountRoutes(apiServices.Accounts, apiServices.Ledger, validator))
+				r.Mount("/api/beneficiaries", addBeneficiaryRoutes(apiServices.Beneficiaries, validator))
+			})
+		})
+	}
 
 	walkFunc := func(method string, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
 		log.Printf("%s %s\n", method, route)
@@ -47,12 +96,27 @@ func NewApiRouter(paymentService services.PaymentService) *chi.Mux {
 	return router
 }
 
-func addRoutes(paymentService services.PaymentService) *chi.Mux {
+func addStreamRoutes(eventService services.EventService) *chi.Mux {
 	router := chi.NewRouter()
+	setStreamPayments(router, eventService)
+	return router
+}
+
+func addRoutes(paymentService services.PaymentService, exportService services.ExportService, validator PaymentValidator) *chi.Mux {
+	router := chi.NewRouter()
+	setExportFiles(router, exportService)
+	setImportMT103(router, paymentService, validator)
+	setEstimateCharges(router, paymentService)
+	setGetPaymentByReference(router, paymentService)
 	setGetPaymentById(router, paymentService)
+	setApprovePayment(router, paymentService)
+	setReschedulePayment(router, paymentService)
+	setCancelScheduledPayment(router, paymentService)
+	setRefundPayment(router, paymentService)
+	setCancelPayment(router, paymentService)
 	setDeletePayment(router, paymentService)
-	setUpdatePayment(router, paymentService)
-	setCreatePayment(router, paymentService)
+	setUpdatePayment(router, paymentService, validator)
+	setCreatePayment(router, paymentService, validator)
 	setGetPayments(router, paymentService)
 	return router
 }
@@ -71,11 +135,177 @@ func setGetPaymentById(router *chi.Mux, paymentService services.PaymentService)
 	})
 }
 
+// End to end references are unique within an organisation, which must be
+// given as a query parameter
+func setGetPaymentByReference(router *chi.Mux, paymentService services.PaymentService) {
+	router.Get("/references/{"+referenceParam+"}", func(w http.ResponseWriter, r *http.Request) {
+		organisationId := r.URL.Query().Get(organisationIdParam)
+		if organisationId == "" {
+			renderBadRequest(router, w, r, []*types.FieldError{{
+				Field:   organisationIdParam,
+				Message: "organisation_id is required",
+			}})
+			return
+		}
+		payment, err := paymentService.GetPaymentByReference(organisationId, chi.URLParam(r, referenceParam))
+		if err != nil {
+			renderInternalError(router, w, r)
+		} else if payment != nil {
+			render.JSON(w, r, payment)
+		} else {
+			renderNotFound(router, w, r)
+		}
+	})
+}
+
+// Approval by the user of the request, who cannot be the payment creator
+func setApprovePayment(router *chi.Mux, paymentService services.PaymentService) {
+	router.Post("/{"+paymentIdParam+"}/approvals", func(w http.ResponseWriter, r *http.Request) {
+		userId := r.Header.Get(userIdHeader)
+		if userId == "" {
+			renderBadRequest(router, w, r, []*types.FieldError{{Message: userIdHeader + " header is required"}})
+			return
+		}
+		payment, err := paymentService.ApprovePayment(chi.URLParam(r, paymentIdParam), userId)
+		if forbiddenErr, ok := err.(services.ForbiddenError); ok {
+			renderForbidden(router, w, r, forbiddenErr.Message)
+		} else if stateErr, ok := err.(services.StateError); ok {
+			renderConflict(router, w, r, stateErr.Message, "")
+		} else if err != nil {
+			renderInternalError(router, w, r)
+		} else if payment != nil {
+			render.JSON(w, r, payment)
+		} else {
+			renderNotFound(router, w, r)
+		}
+	})
+}
+
+// Cancel for an ISO cancellation reason, unlike delete the payment is kept
+func setCancelPayment(router *chi.Mux, paymentService services.PaymentService) {
+	router.Post("/{"+paymentIdParam+"}/cancellation", func(w http.ResponseWriter, r *http.Request) {
+		request, ok := decodeCancellation(router, w, r)
+		if !ok {
+			return
+		}
+
+		payment, err := paymentService.CancelPayment(chi.URLParam(r, paymentIdParam), request.ReasonCode, r.Header.Get(userIdHeader))
+		if stateErr, ok := err.(services.StateError); ok {
+			renderConflict(router, w, r, stateErr.Message, "")
+		} else if err != nil {
+			renderInternalError(router, w, r)
+		} else if payment != nil {
+			render.JSON(w, r, payment)
+		} else {
+			renderNotFound(router, w, r)
+		}
+	})
+}
+
+// Cancellation request with an ISO cancellation reason code, false after
+// rendering a bad request otherwise
+func decodeCancellation(router *chi.Mux, w http.ResponseWriter, r *http.Request) (*types.CancellationRequest, bool) {
+	var request types.CancellationRequest
+	json.NewDecoder(r.Body).Decode(&request)
+	if _, ok := iso20022.CancellationReasons[request.ReasonCode]; !ok {
+		renderBadRequest(router, w, r, []*types.FieldError{{
+			Field:   "reason_code",
+			Message: "reason_code must be an ISO 20022 cancellation reason code",
+		}})
+		return nil, false
+	}
+	return &request, true
+}
+
+// Refund as a new payment back to the debtor, for an ISO return reason
+func setRefundPayment(router *chi.Mux, paymentService services.PaymentService) {
+	router.Post("/{"+paymentIdParam+"}/refunds", func(w http.ResponseWriter, r *http.Request) {
+		var request types.RefundRequest
+		json.NewDecoder(r.Body).Decode(&request)
+
+		errors := make([]*types.FieldError, 0)
+		if _, ok := iso20022.ReturnReasons[request.ReasonCode]; !ok {
+			errors = append(errors, &types.FieldError{
+				Field:   "reason_code",
+				Message: "reason_code must be an ISO 20022 return reason code",
+			})
+		}
+		if request.Amount < 0 {
+			errors = append(errors, &types.FieldError{Field: "amount", Message: "amount must be positive"})
+		}
+		if len(errors) > 0 {
+			renderBadRequest(router, w, r, errors)
+			return
+		}
+
+		refund, err := paymentService.RefundPayment(chi.URLParam(r, paymentIdParam), &request, r.Header.Get(userIdHeader))
+		if amountErr, ok := err.(services.RefundAmountError); ok {
+			renderBadRequest(router, w, r, []*types.FieldError{{Field: "amount", Message: amountErr.Error()}})
+		} else if duplicateErr, ok := err.(services.DuplicatePaymentError); ok {
+			renderConflict(router, w, r, duplicateErr.Error(), duplicateErr.ExistingId)
+		} else if limitErr, ok := err.(services.LimitExceededError); ok {
+			renderLimitExceeded(router, w, r, limitErr)
+		} else if _, ok := err.(services.UserRequiredError); ok {
+			renderUserRequired(router, w, r)
+		} else if stateErr, ok := err.(services.StateError); ok {
+			renderConflict(router, w, r, stateErr.Message, "")
+		} else if err != nil {
+			renderInternalError(router, w, r)
+		} else if refund != nil {
+			render.JSON(w, r, refund)
+		} else {
+			renderNotFound(router, w, r)
+		}
+	})
+}
+
+// Move the execution date of a payment that has not been released
+func setReschedulePayment(router *chi.Mux, paymentService services.PaymentService) {
+	router.Put("/{"+paymentIdParam+"}/schedule", func(w http.ResponseWriter, r *http.Request) {
+		var schedule types.PaymentSchedule
+		if err := json.NewDecoder(r.Body).Decode(&schedule); err != nil || schedule.ExecutionDate == nil {
+			renderBadRequest(router, w, r, []*types.FieldError{{
+				Field:   "execution_date",
+				Message: "execution_date is required as a date-time",
+			}})
+			return
+		}
+		payment, err := paymentService.ReschedulePayment(chi.URLParam(r, paymentIdParam), *schedule.ExecutionDate)
+		renderScheduleResult(router, w, r, payment, err)
+	})
+}
+
+func setCancelScheduledPayment(router *chi.Mux, paymentService services.PaymentService) {
+	router.Delete("/{"+paymentIdParam+"}/schedule", func(w http.ResponseWriter, r *http.Request) {
+		request, ok := decodeCancellation(router, w, r)
+		if !ok {
+			return
+		}
+
+		payment, err := paymentService.CancelScheduledPayment(chi.URLParam(r, paymentIdParam), request.ReasonCode, r.Header.Get(userIdHeader))
+		renderScheduleResult(router, w, r, payment, err)
+	})
+}
+
+func renderScheduleResult(router *chi.Mux, w http.ResponseWriter, r *http.Request, payment *types.Payment, err error) {
+	if stateErr, ok := err.(services.StateError); ok {
+		renderConflict(router, w, r, stateErr.Message, "")
+	} else if err != nil {
+		renderInternalError(router, w, r)
+	} else if payment != nil {
+		render.JSON(w, r, payment)
+	} else {
+		renderNotFound(router, w, r)
+	}
+}
+
 func setDeletePayment(router *chi.Mux, paymentService services.PaymentService) {
 	router.Delete("/{"+paymentIdParam+"}", func(w http.ResponseWriter, r *http.Request) {
 		paymentID := chi.URLParam(r, paymentIdParam)
 		deleted, err := paymentService.DeletePayment(paymentID)
-		if err != nil {
+		if stateErr, ok := err.(services.StateError); ok {
+			renderConflict(router, w, r, stateErr.Message, "")
+		} else if err != nil {
 			renderInternalError(router, w, r)
 		} else if deleted {
 			render.JSON(w, r, &types.PaymentDelete{
@@ -87,21 +317,58 @@ func setDeletePayment(router *chi.Mux, paymentService services.PaymentService) {
 	})
 }
 
-func setUpdatePayment(router *chi.Mux, paymentService services.PaymentService) {
+func setUpdatePayment(router *chi.Mux, paymentService services.PaymentService, validator PaymentValidator) {
 	router.Put("/{"+paymentIdParam+"}", func(w http.ResponseWriter, r *http.Request) {
 		paymentID := chi.URLParam(r, paymentIdParam)
 		var payment types.Payment
 		json.NewDecoder(r.Body).Decode(&payment)
 
-		errors := isValidAtrributes(payment.Attributes)
+		errors := validator.ValidateAttributes(payment.Attributes)
 		if errors != nil {
 			renderBadRequest(router, w, r, errors)
 			return
 		}
 
-		updatedPayment, err := paymentService.UpdatePayment(paymentID, payment.Attributes)
+		// Organisation constraints apply to the payment as it would be updated,
+		// with the parties of the accounts and beneficiary it references
+		existingPayment, err := paymentService.GetPayment(paymentID)
 		if err != nil {
 			renderInternalError(router, w, r)
+			return
+		} else if existingPayment == nil {
+			renderNotFound(router, w, r)
+			return
+		}
+		if err := paymentService.ResolveReferences(existingPayment.OrganisationId, payment.Attributes, existingPayment.Attributes); err != nil {
+			renderCreateError(router, w, r, "", err)
+			return
+		}
+		existingPayment.Attributes = payment.Attributes
+		errors = validator.ValidatePayment(existingPayment)
+		if errors != nil {
+			renderBadRequest(router, w, r, errors)
+			return
+		}
+
+		updatedPayment, err := paymentService.UpdatePayment(paymentID, payment.Attributes, r.Header.Get(userIdHeader))
+		if dateErr, ok := err.(services.ProcessingDateError); ok {
+			renderProcessingDateError(router, w, r, "attributes.processing_date", dateErr)
+		} else if fxErr, ok := err.(services.FxError); ok {
+			renderFxError(router, w, r, "attributes.", fxErr)
+		} else if accountErr, ok := err.(services.AccountError); ok {
+			renderAccountError(router, w, r, "attributes.", accountErr)
+		} else if beneficiaryErr, ok := err.(services.BeneficiaryError); ok {
+			renderBeneficiaryError(router, w, r, "attributes.", beneficiaryErr)
+		} else if duplicateErr, ok := err.(services.DuplicatePaymentError); ok {
+			renderConflict(router, w, r, duplicateErr.Error(), duplicateErr.ExistingId)
+		} else if limitErr, ok := err.(services.LimitExceededError); ok {
+			renderLimitExceeded(router, w, r, limitErr)
+		} else if _, ok := err.(services.UserRequiredError); ok {
+			renderUserRequired(router, w, r)
+		} else if stateErr, ok := err.(services.StateError); ok {
+			renderConflict(router, w, r, stateErr.Message, "")
+		} else if err != nil {
+			renderInternalError(router, w, r)
 		} else if updatedPayment != nil {
 			render.JSON(w, r, updatedPayment)
 		} else {
@@ -110,13 +377,26 @@ func setUpdatePayment(router *chi.Mux, paymentService services.PaymentService) {
 	})
 }
 
-func setCreatePayment(router *chi.Mux, paymentService services.PaymentService) {
+func setCreatePayment(router *chi.Mux, paymentService services.PaymentService, validator PaymentValidator) {
 	router.Post("/", func(w http.ResponseWriter, r *http.Request) {
 		var payment types.Payment
 		json.NewDecoder(r.Body).Decode(&payment)
 
 		payment.Version = 0
-		errors := isValidPayment(&payment)
+		payment.CreatedBy = r.Header.Get(userIdHeader)
+		errors := validator.ValidatePayment(&payment)
+		if errors != nil {
+			renderBadRequest(router, w, r, errors)
+			return
+		}
+
+		// Scheme and organisation constraints apply to the parties of the
+		// referenced accounts and beneficiary too
+		if err := paymentService.ResolveReferences(payment.OrganisationId, payment.Attributes, nil); err != nil {
+			renderCreateError(router, w, r, "", err)
+			return
+		}
+		errors = validator.ValidatePayment(&payment)
 		if errors != nil {
 			renderBadRequest(router, w, r, errors)
 			return
@@ -124,16 +404,38 @@ func setCreatePayment(router *chi.Mux, paymentService services.PaymentService) {
 
 		createdPayment, err := paymentService.CreatePayment(&payment)
 		if err != nil {
-			renderInternalError(router, w, r)
+			renderCreateError(router, w, r, "", err)
 		} else {
 			render.JSON(w, r, createdPayment)
 		}
 	})
 }
 
+// Render an error of creating a payment, prefix locates the payment in the
+// request body
+func renderCreateError(router *chi.Mux, w http.ResponseWriter, r *http.Request, prefix string, err error) {
+	if dateErr, ok := err.(services.ProcessingDateError); ok {
+		renderProcessingDateError(router, w, r, prefix+"attributes.processing_date", dateErr)
+	} else if fxErr, ok := err.(services.FxError); ok {
+		renderFxError(router, w, r, prefix+"attributes.", fxErr)
+	} else if accountErr, ok := err.(services.AccountError); ok {
+		renderAccountError(router, w, r, prefix+"attributes.", accountErr)
+	} else if beneficiaryErr, ok := err.(services.BeneficiaryError); ok {
+		renderBeneficiaryError(router, w, r, prefix+"attributes.", beneficiaryErr)
+	} else if duplicateErr, ok := err.(services.DuplicatePaymentError); ok {
+		renderConflict(router, w, r, duplicateErr.Error(), duplicateErr.ExistingId)
+	} else if limitErr, ok := err.(services.LimitExceededError); ok {
+		renderLimitExceeded(router, w, r, limitErr)
+	} else if _, ok := err.(services.UserRequiredError); ok {
+		renderUserRequired(router, w, r)
+	} else {
+		renderInternalError(router, w, r)
+	}
+}
+
 func setGetPayments(router *chi.Mux, paymentService services.PaymentService) {
 	router.Get("/", func(w http.ResponseWriter, r *http.Request) {
-		payments, err := paymentService.GetPayments()
+		payments, err := paymentService.GetPayments(getPaymentFilter(r))
 		if err != nil {
 			renderInternalError(router, w, r)
 		} else {
@@ -147,16 +449,270 @@ func setGetPayments(router *chi.Mux, paymentService services.PaymentService) {
 	})
 }
 
+// Server-Sent Events feed of the payment events of the organisation given as
+// a query parameter, resumed from the Last-Event-ID header. Streams end when
+// the client disconnects.
+func setStreamPayments(router *chi.Mux, eventService services.EventService) {
+	router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			renderInternalError(router, w, r)
+			return
+		}
+		organisationId := r.URL.Query().Get(organisationIdParam)
+		if organisationId == "" {
+			renderBadRequest(router, w, r, []*types.FieldError{{
+				Field:   organisationIdParam,
+				Message: "organisation_id is required",
+			}})
+			return
+		}
+		events, err := eventService.SubscribeEvents(r.Context(), organisationId, r.Header.Get("Last-Event-ID"))
+		if cursorErr, ok := err.(services.CursorError); ok {
+			renderBadRequest(router, w, r, []*types.FieldError{{
+				Field:   "Last-Event-ID",
+				Message: cursorErr.Message,
+			}})
+			return
+		}
+		if err != nil {
+			renderInternalError(router, w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				data, err := json.Marshal(event)
+				if err != nil {
+					log.Printf("Error encoding event %s: %s", event.Id, err.Error())
+					continue
+				}
+				fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.Cursor, event.Type, data)
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+			}
+			flusher.Flush()
+		}
+	})
+}
+
+// Listing filter from query parameters, e.g. ?debtor_party.country=GB, id
+// can be repeated to select several payments
+func getPaymentFilter(r *http.Request) *types.PaymentFilter {
+	query := r.URL.Query()
+	return &types.PaymentFilter{
+		Ids:              query["id"],
+		OrganisationId:   query.Get(organisationIdParam),
+		DebtorParty:      getPartyFilter(query, "debtor_party."),
+		BeneficiaryParty: getPartyFilter(query, "beneficiary_party."),
+	}
+}
+
+func getPartyFilter(query url.Values, prefix string) *types.PartyFilter {
+	return &types.PartyFilter{
+		BankId:        query.Get(prefix + "bank_id"),
+		AccountNumber: query.Get(prefix + "account_number"),
+		AccountName:   query.Get(prefix + "account_name"),
+		Country:       query.Get(prefix + "country"),
+	}
+}
+
+// Payment files of the filtered payments: ISO 20022 pain.001, SWIFT MT103,
+// Bacs Standard 18 and NACHA
+func setExportFiles(router *chi.Mux, exportService services.ExportService) {
+	setExport(router, "/export/pain.001", "application/xml", func(filter *types.PaymentFilter) ([]byte, error) {
+		return exportService.ExportPain001(filter)
+	})
+	setExport(router, "/export/mt103", "text/plain", func(filter *types.PaymentFilter) ([]byte, error) {
+		return exportService.ExportMT103(filter)
+	})
+	setExport(router, "/export/bacs", "text/plain", func(filter *types.PaymentFilter) ([]byte, error) {
+		return exportService.ExportStandard18(filter)
+	})
+	setExport(router, "/export/nacha", "text/plain", func(filter *types.PaymentFilter) ([]byte, error) {
+		return exportService.ExportNacha(filter)
+	})
+}
+
+func setExport(router *chi.Mux, path string, contentType string, export func(*types.PaymentFilter) ([]byte, error)) {
+	router.Get(path, func(w http.ResponseWriter, r *http.Request) {
+		document, err := export(getPaymentFilter(r))
+		if message, ok := formatErrorMessage(err); ok {
+			renderBadRequest(router, w, r, []*types.FieldError{{Message: message}})
+		} else if err != nil {
+			renderInternalError(router, w, r)
+		} else {
+			w.Header().Set("Content-Type", contentType)
+			w.Write(document)
+		}
+	})
+}
+
+// Message of errors caused by payments a file format cannot express
+func formatErrorMessage(err error) (string, bool) {
+	switch formatErr := err.(type) {
+	case iso20022.FormatError:
+		return formatErr.Message, true
+	case swift.FormatError:
+		return formatErr.Message, true
+	case batchfile.FormatError:
+		return formatErr.Message, true
+	}
+	return "", false
+}
+
+// Charges of a draft payment by the pricing of its organisation and scheme,
+// nothing is created
+func setEstimateCharges(router *chi.Mux, paymentService services.PaymentService) {
+	router.Post("/fee-estimate", func(w http.ResponseWriter, r *http.Request) {
+		var payment types.Payment
+		json.NewDecoder(r.Body).Decode(&payment)
+
+		errors := make([]*types.FieldError, 0)
+		if payment.Attributes == nil || payment.Attributes.Amount <= 0 {
+			errors = append(errors, &types.FieldError{Field: "attributes.amount", Message: "amount must be positive"})
+		} else if charges := payment.Attributes.ChargesInformation; charges != nil && charges.BearerCode != "" &&
+			!bearerCodes[charges.BearerCode] {
+			errors = append(errors, &types.FieldError{
+				Field:   "attributes.charges_information.bearer_code",
+				Message: "bearer_code must be one of DEBT, CRED, SHAR, SLEV",
+			})
+		}
+		if len(errors) > 0 {
+			renderBadRequest(router, w, r, errors)
+			return
+		}
+		render.JSON(w, r, paymentService.EstimateCharges(&payment))
+	})
+}
+
+var bearerCodes = map[string]bool{
+	types.BearerDebtor:    true,
+	types.BearerCreditor:  true,
+	types.BearerShared:    true,
+	types.BearerFollowing: true,
+}
+
+// Create a payment of the organisation for each MT103 message in the body.
+// Nothing is created unless every message is a valid payment that can be
+// created, the payments are created in one transaction.
+func setImportMT103(router *chi.Mux, paymentService services.PaymentService, validator PaymentValidator) {
+	router.Post("/import/mt103", func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			renderBadRequest(router, w, r, []*types.FieldError{{Message: "Failed to read messages"}})
+			return
+		}
+		messages, err := swift.ParseMT103(body)
+		if formatErr, ok := err.(swift.FormatError); ok {
+			renderBadRequest(router, w, r, []*types.FieldError{{Message: formatErr.Message}})
+			return
+		} else if err != nil {
+			renderInternalError(router, w, r)
+			return
+		}
+
+		organisationId := r.URL.Query().Get(organisationIdParam)
+		payments := make([]*types.Payment, 0, len(messages))
+		errors := make([]*types.FieldError, 0)
+		for i, message := range messages {
+			payment := message.ToPayment(organisationId)
+			payment.CreatedBy = r.Header.Get(userIdHeader)
+			if err := paymentService.ResolveReferences(organisationId, payment.Attributes, nil); err != nil {
+				renderCreateError(router, w, r, fmt.Sprintf("messages[%d].", i), err)
+				return
+			}
+			for _, fieldErr := range validator.ValidatePayment(payment) {
+				fieldErr.Field = strings.TrimSuffix(fmt.Sprintf("messages[%d].%s", i, fieldErr.Field), ".")
+				errors = append(errors, fieldErr)
+			}
+			payments = append(payments, payment)
+		}
+		if len(errors) > 0 {
+			renderBadRequest(router, w, r, errors)
+			return
+		}
+
+		created, err := paymentService.ImportPayments(payments)
+		if batchErr, ok := err.(services.BatchError); ok {
+			renderCreateError(router, w, r, fmt.Sprintf("messages[%d].", batchErr.Index), batchErr.Err)
+			return
+		} else if err != nil {
+			renderInternalError(router, w, r)
+			return
+		}
+		render.JSON(w, r, &types.Payments{
+			Data: created,
+			Links: &types.Links{
+				Self: paymentsSelf,
+			},
+		})
+	})
+}
+
 func renderNotFound(router *chi.Mux, w http.ResponseWriter, r *http.Request) {
 	render.Status(r, 404)
 	render.JSON(w, r, NotFound)
 }
 
-func renderBadRequest(router *chi.Mux, w http.ResponseWriter, r *http.Request, messages []string) {
+func renderBadRequest(router *chi.Mux, w http.ResponseWriter, r *http.Request, errors []*types.FieldError) {
+	messages := make([]string, 0, len(errors))
+	for _, err := range errors {
+		messages = append(messages, err.Message)
+	}
 	render.Status(r, 400)
 	render.JSON(w, r, &types.HttpError{
 		StatusText: BadRequest.StatusText,
 		Messages:   messages,
+		Errors:     errors,
+	})
+}
+
+func renderConflict(router *chi.Mux, w http.ResponseWriter, r *http.Request, message string, existingId string) {
+	render.Status(r, 409)
+	render.JSON(w, r, &types.HttpError{
+		StatusText: Conflict.StatusText,
+		Messages:   []string{message},
+		ExistingId: existingId,
+	})
+}
+
+func renderLimitExceeded(router *chi.Mux, w http.ResponseWriter, r *http.Request, err services.LimitExceededError) {
+	render.Status(r, 422)
+	render.JSON(w, r, &types.HttpError{
+		StatusText: LimitExceeded.StatusText,
+		Messages:   []string{err.Error()},
+		Limit:      err.Limit,
+	})
+}
+
+// Payments that need approving are written as a user, so approvals can be
+// kept from their creator or editor
+func renderUserRequired(router *chi.Mux, w http.ResponseWriter, r *http.Request) {
+	renderBadRequest(router, w, r, []*types.FieldError{{Message: userIdHeader + " header is required for payments that need approving"}})
+}
+
+func renderProcessingDateError(router *chi.Mux, w http.ResponseWriter, r *http.Request, field string, err services.ProcessingDateError) {
+	renderBadRequest(router, w, r, []*types.FieldError{{Field: field, Message: err.Message}})
+}
+
+func renderForbidden(router *chi.Mux, w http.ResponseWriter, r *http.Request, message string) {
+	render.Status(r, 403)
+	render.JSON(w, r, &types.HttpError{
+		StatusText: Forbidden.StatusText,
+		Messages:   []string{message},
 	})
 }
 
@@ -0,0 +1,60 @@
+//go:build ignore
+// +build ignore
+
+// Generates schemas_gen.go, embedding every versioned JSON schema in this
+// directory so the api binary does not depend on files at runtime.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+)
+
+func main() {
+	files, err := filepath.Glob("schemas/*/*.json")
+	if err != nil {
+		log.Fatal(err)
+	}
+	sort.Strings(files)
+
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "// Code generated by schemas/generate.go. DO NOT EDIT.")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "package api")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "// Schema file contents by version and file name")
+	fmt.Fprintln(&buf, "var schemaFiles = map[string]map[string]string{")
+	version := ""
+	for _, file := range files {
+		fileVersion := filepath.Base(filepath.Dir(file))
+		if fileVersion != version {
+			if version != "" {
+				fmt.Fprintln(&buf, "},")
+			}
+			fmt.Fprintf(&buf, "%q: {\n", fileVersion)
+			version = fileVersion
+		}
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(&buf, "%q: %q,\n", filepath.Base(file), content)
+	}
+	if version != "" {
+		fmt.Fprintln(&buf, "},")
+	}
+	fmt.Fprintln(&buf, "}")
+
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile("schemas_gen.go", source, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
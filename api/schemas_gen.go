@@ -0,0 +1,20 @@
+// Code generated by schemas/generate.go. DO NOT EDIT.
+
+package api
+
+// Schema file contents by version and file name
+var schemaFiles = map[string]map[string]string{
+	"v1": {
+		"account.json":            "{\n  \"$schema\": \"http://json-schema.org/draft-07/schema#\",\n  \"$id\": \"https://payment-api/schemas/v1/account.json\",\n  \"type\": \"object\",\n  \"properties\": {\n    \"type\": {\n      \"type\": \"string\",\n      \"enum\": [\"Account\"]\n    },\n    \"id\": {\n      \"type\": \"string\"\n    },\n    \"version\": {\n      \"type\": \"integer\",\n      \"minimum\": 0\n    },\n    \"organisation_id\": {\n      \"type\": \"string\"\n    },\n    \"bank_id\": {\n      \"type\": \"string\"\n    },\n    \"bank_id_code\": {\n      \"type\": \"string\"\n    },\n    \"account_number\": {\n      \"type\": \"string\"\n    },\n    \"account_number_code\": {\n      \"type\": \"string\"\n    },\n    \"name\": {\n      \"type\": \"string\"\n    },\n    \"created_at\": {\n      \"type\": \"string\",\n      \"format\": \"date-time\",\n      \"readOnly\": true\n    }\n  },\n  \"required\": [\"organisation_id\", \"account_number\", \"account_number_code\"],\n  \"allOf\": [\n    {\"$ref\": \"payment_party.json\"}\n  ]\n}\n",
+		"beneficiary.json":        "{\n  \"$schema\": \"http://json-schema.org/draft-07/schema#\",\n  \"$id\": \"https://payment-api/schemas/v1/beneficiary.json\",\n  \"type\": \"object\",\n  \"properties\": {\n    \"type\": {\n      \"type\": \"string\",\n      \"enum\": [\"Beneficiary\"]\n    },\n    \"id\": {\n      \"type\": \"string\"\n    },\n    \"version\": {\n      \"type\": \"integer\",\n      \"minimum\": 0\n    },\n    \"organisation_id\": {\n      \"type\": \"string\"\n    },\n    \"name\": {\n      \"type\": \"string\",\n      \"minLength\": 1,\n      \"maxLength\": 140\n    },\n    \"party\": {\n      \"$ref\": \"payment_party.json\"\n    },\n    \"status\": {\n      \"type\": \"string\",\n      \"enum\": [\"unverified\", \"verified\"],\n      \"readOnly\": true\n    },\n    \"created_by\": {\n      \"type\": \"string\",\n      \"readOnly\": true\n    },\n    \"created_at\": {\n      \"type\": \"string\",\n      \"format\": \"date-time\",\n      \"readOnly\": true\n    },\n    \"updated_by\": {\n      \"type\": \"string\",\n      \"readOnly\": true\n    },\n    \"verified_by\": {\n      \"type\": \"string\",\n      \"readOnly\": true\n    },\n    \"verified_at\": {\n      \"type\": \"string\",\n      \"format\": \"date-time\",\n      \"readOnly\": true\n    },\n    \"usage_count\": {\n      \"type\": \"integer\",\n      \"readOnly\": true\n    },\n    \"last_used_at\": {\n      \"type\": \"string\",\n      \"format\": \"date-time\",\n      \"readOnly\": true\n    }\n  },\n  \"required\": [\"organisation_id\", \"name\", \"party\"]\n}\n",
+		"payment.json":            "{\n  \"$schema\": \"http://json-schema.org/draft-07/schema#\",\n  \"$id\": \"https://payment-api/schemas/v1/payment.json\",\n  \"type\": \"object\",\n  \"properties\": {\n    \"type\": {\n      \"type\": \"string\",\n      \"enum\": [\"Payment\"]\n    },\n    \"id\": {\n      \"type\": \"string\"\n    },\n    \"organisation_id\": {\n      \"type\": \"string\"\n    },\n    \"attributes\": {\n      \"$ref\": \"payment_attributes.json\"\n    },\n    \"created_at\": {\n      \"type\": \"string\",\n      \"format\": \"date-time\",\n      \"readOnly\": true\n    },\n    \"possible_duplicate\": {\n      \"type\": \"boolean\",\n      \"readOnly\": true\n    },\n    \"execution_date\": {\n      \"type\": \"string\",\n      \"format\": \"date-time\"\n    },\n    \"status\": {\n      \"type\": \"string\",\n      \"enum\": [\"pending_approval\", \"scheduled\", \"accepted\", \"cancelled\"],\n      \"readOnly\": true\n    },\n    \"created_by\": {\n      \"type\": \"string\",\n      \"readOnly\": true\n    },\n    \"updated_by\": {\n      \"type\": \"string\",\n      \"readOnly\": true\n    },\n    \"related_payment_id\": {\n      \"type\": \"string\",\n      \"readOnly\": true\n    },\n    \"standing_order_id\": {\n      \"type\": \"string\",\n      \"readOnly\": true\n    },\n    \"return_reason\": {\n      \"type\": \"string\",\n      \"readOnly\": true\n    },\n    \"refunds\": {\n      \"type\": \"array\",\n      \"items\": {\n        \"type\": \"object\",\n        \"properties\": {\n          \"payment_id\": {\n            \"type\": \"string\"\n          },\n          \"amount\": {\n            \"type\": \"number\"\n          },\n          \"return_reason\": {\n            \"type\": \"string\"\n          },\n          \"created_at\": {\n            \"type\": \"string\",\n            \"format\": \"date-time\"\n          }\n        }\n      },\n      \"readOnly\": true\n    },\n    \"cancellation\": {\n      \"type\": \"object\",\n      \"properties\": {\n        \"reason_code\": {\n          \"type\": \"string\"\n        },\n        \"cancelled_by\": {\n          \"type\": \"string\"\n        },\n        \"cancelled_at\": {\n          \"type\": \"string\",\n          \"format\": \"date-time\"\n        }\n      },\n      \"readOnly\": true\n    },\n    \"reconciliation\": {\n      \"type\": \"object\",\n      \"properties\": {\n        \"statement_reference\": {\n          \"type\": \"string\"\n        },\n        \"entry_reference\": {\n          \"type\": \"string\"\n        },\n        \"reconciled_at\": {\n          \"type\": \"string\",\n          \"format\": \"date-time\"\n        }\n      },\n      \"readOnly\": true\n    },\n    \"fx\": {\n      \"type\": \"object\",\n      \"properties\": {\n        \"rate_id\": {\n          \"type\": \"string\"\n        },\n        \"rate\": {\n          \"type\": \"number\"\n        },\n        \"quote_id\": {\n          \"type\": \"string\"\n        },\n        \"applied_at\": {\n          \"type\": \"string\",\n          \"format\": \"date-time\"\n        }\n      },\n      \"readOnly\": true\n    },\n    \"approvals\": {\n      \"type\": \"array\",\n      \"items\": {\n        \"type\": \"object\",\n        \"properties\": {\n          \"approved_by\": {\n            \"type\": \"string\"\n          },\n          \"approved_at\": {\n            \"type\": \"string\",\n            \"format\": \"date-time\"\n          }\n        }\n      },\n      \"readOnly\": true\n    }\n  },\n  \"required\": [\"type\", \"organisation_id\"]\n}\n",
+		"payment_attributes.json": "{\n  \"$schema\": \"http://json-schema.org/draft-07/schema#\",\n  \"$id\": \"https://payment-api/schemas/v1/payment_attributes.json\",\n  \"type\": \"object\",\n  \"properties\": {\n    \"amount\": {\n      \"type\": \"number\",\n      \"exclusiveMinimum\": 0\n    },\n    \"beneficiary_party\": {\n      \"$ref\": \"payment_party.json\"\n    },\n    \"debtor_party\": {\n      \"$ref\": \"payment_party.json\"\n    },\n    \"debtor_account_id\": {\n      \"type\": \"string\"\n    },\n    \"beneficiary_account_id\": {\n      \"type\": \"string\"\n    },\n    \"beneficiary_id\": {\n      \"type\": \"string\"\n    },\n    \"end_to_end_reference\": {\n      \"type\": \"string\"\n    },\n    \"currency\": {\n      \"type\": \"string\",\n      \"pattern\": \"^[A-Z]{3}$\"\n    },\n    \"payment_scheme\": {\n      \"type\": \"string\",\n      \"enum\": [\"FPS\", \"BACS\", \"SEPA\", \"CHAPS\", \"SWIFT\"]\n    },\n    \"scheme_payment_type\": {\n      \"type\": \"string\"\n    },\n    \"processing_date\": {\n      \"type\": \"string\",\n      \"format\": \"date\"\n    },\n    \"payment_purpose\": {\n      \"type\": \"string\",\n      \"maxLength\": 140\n    },\n    \"reference\": {\n      \"type\": \"string\",\n      \"maxLength\": 140\n    },\n    \"numeric_reference\": {\n      \"type\": \"string\",\n      \"pattern\": \"^[0-9]{1,18}$\"\n    },\n    \"instructed_amount\": {\n      \"type\": \"number\",\n      \"exclusiveMinimum\": 0\n    },\n    \"instructed_currency\": {\n      \"type\": \"string\",\n      \"pattern\": \"^[A-Z]{3}$\"\n    },\n    \"settlement_amount\": {\n      \"type\": \"number\",\n      \"readOnly\": true\n    },\n    \"settlement_currency\": {\n      \"type\": \"string\",\n      \"pattern\": \"^[A-Z]{3}$\"\n    },\n    \"quote_id\": {\n      \"type\": \"string\"\n    },\n    \"charges_information\": {\n      \"type\": \"object\",\n      \"properties\": {\n        \"bearer_code\": {\n          \"type\": \"string\",\n          \"enum\": [\"DEBT\", \"CRED\", \"SHAR\", \"SLEV\"]\n        },\n        \"sender_charges\": {\n          \"type\": \"array\",\n          \"items\": {\n            \"type\": \"object\",\n            \"properties\": {\n              \"amount\": {\n                \"type\": \"number\"\n              },\n              \"currency\": {\n                \"type\": \"string\"\n              }\n            }\n          },\n          \"readOnly\": true\n        },\n        \"receiver_charges_amount\": {\n          \"type\": \"number\",\n          \"readOnly\": true\n        },\n        \"receiver_charges_currency\": {\n          \"type\": \"string\",\n          \"readOnly\": true\n        }\n      }\n    }\n  },\n  \"required\": [\"amount\", \"end_to_end_reference\"],\n  \"allOf\": [\n    {\n      \"if\": {\"not\": {\"anyOf\": [{\"required\": [\"beneficiary_account_id\"]}, {\"required\": [\"beneficiary_id\"]}]}},\n      \"then\": {\"required\": [\"beneficiary_party\"]}\n    },\n    {\n      \"if\": {\"not\": {\"required\": [\"debtor_account_id\"]}},\n      \"then\": {\"required\": [\"debtor_party\"]}\n    },\n    {\n      \"if\": {\"properties\": {\"payment_scheme\": {\"const\": \"FPS\"}}, \"required\": [\"payment_scheme\"]},\n      \"then\": {\"$ref\": \"scheme_fps.json\"}\n    },\n    {\n      \"if\": {\"properties\": {\"payment_scheme\": {\"const\": \"BACS\"}}, \"required\": [\"payment_scheme\"]},\n      \"then\": {\"$ref\": \"scheme_bacs.json\"}\n    },\n    {\n      \"if\": {\"properties\": {\"payment_scheme\": {\"const\": \"SEPA\"}}, \"required\": [\"payment_scheme\"]},\n      \"then\": {\"$ref\": \"scheme_sepa.json\"}\n    },\n    {\n      \"if\": {\"properties\": {\"payment_scheme\": {\"const\": \"CHAPS\"}}, \"required\": [\"payment_scheme\"]},\n      \"then\": {\"$ref\": \"scheme_chaps.json\"}\n    },\n    {\n      \"if\": {\"properties\": {\"payment_scheme\": {\"const\": \"SWIFT\"}}, \"required\": [\"payment_scheme\"]},\n      \"then\": {\"$ref\": \"scheme_swift.json\"}\n    }\n  ]\n}\n",
+		"payment_party.json":      "{\n  \"$schema\": \"http://json-schema.org/draft-07/schema#\",\n  \"$id\": \"https://payment-api/schemas/v1/payment_party.json\",\n  \"type\": \"object\",\n  \"properties\": {\n    \"bank_id\": {\n      \"type\": \"string\"\n    },\n    \"bank_id_code\": {\n      \"type\": \"string\",\n      \"enum\": [\"GBDSC\", \"BIC\", \"SWBIC\", \"USABA\", \"IBAN\"]\n    },\n    \"name\": {\n      \"type\": \"string\"\n    },\n    \"account_number\": {\n      \"type\": \"string\",\n      \"minLength\": 1,\n      \"maxLength\": 34\n    },\n    \"account_number_code\": {\n      \"type\": \"string\",\n      \"enum\": [\"BBAN\", \"IBAN\"]\n    },\n    \"account_name\": {\n      \"type\": \"string\",\n      \"maxLength\": 140\n    },\n    \"account_type\": {\n      \"type\": \"integer\",\n      \"minimum\": 0\n    },\n    \"address\": {\n      \"$ref\": \"#/definitions/address\"\n    },\n    \"country\": {\n      \"type\": \"string\",\n      \"pattern\": \"^[A-Z]{2}$\"\n    },\n    \"bank_address\": {\n      \"$ref\": \"#/definitions/address\"\n    }\n  },\n  \"required\": [\"bank_id\", \"bank_id_code\", \"name\"],\n  \"dependencies\": {\n    \"account_number\": [\"account_number_code\"],\n    \"account_number_code\": [\"account_number\"]\n  },\n  \"definitions\": {\n    \"address\": {\n      \"type\": \"array\",\n      \"items\": {\n        \"type\": \"string\",\n        \"maxLength\": 35\n      },\n      \"minItems\": 1,\n      \"maxItems\": 4\n    }\n  },\n  \"allOf\": [\n    {\n      \"if\": {\"properties\": {\"bank_id_code\": {\"const\": \"GBDSC\"}}},\n      \"then\": {\"properties\": {\"bank_id\": {\"format\": \"sort-code\"}}}\n    },\n    {\n      \"if\": {\"properties\": {\"bank_id_code\": {\"enum\": [\"BIC\", \"SWBIC\"]}}},\n      \"then\": {\"properties\": {\"bank_id\": {\"format\": \"bic\"}}}\n    },\n    {\n      \"if\": {\"properties\": {\"bank_id_code\": {\"const\": \"USABA\"}}},\n      \"then\": {\"properties\": {\"bank_id\": {\"format\": \"aba-routing\"}}}\n    },\n    {\n      \"if\": {\"properties\": {\"bank_id_code\": {\"const\": \"IBAN\"}}},\n      \"then\": {\"properties\": {\"bank_id\": {\"format\": \"iban\"}}}\n    },\n    {\n      \"if\": {\"properties\": {\"account_number_code\": {\"const\": \"IBAN\"}}, \"required\": [\"account_number_code\"]},\n      \"then\": {\"properties\": {\"account_number\": {\"format\": \"iban\"}}}\n    }\n  ]\n}\n",
+		"scheme_bacs.json":        "{\n  \"$schema\": \"http://json-schema.org/draft-07/schema#\",\n  \"$id\": \"https://payment-api/schemas/v1/scheme_bacs.json\",\n  \"description\": \"Bacs Direct Credit: GBP only, references limited to 18 characters\",\n  \"type\": \"object\",\n  \"properties\": {\n    \"amount\": {\n      \"maximum\": 20000000\n    },\n    \"currency\": {\n      \"const\": \"GBP\"\n    },\n    \"scheme_payment_type\": {\n      \"enum\": [\"DirectCredit\", \"DirectDebit\"]\n    },\n    \"reference\": {\n      \"maxLength\": 18\n    }\n  },\n  \"required\": [\"currency\", \"processing_date\"]\n}\n",
+		"scheme_chaps.json":       "{\n  \"$schema\": \"http://json-schema.org/draft-07/schema#\",\n  \"$id\": \"https://payment-api/schemas/v1/scheme_chaps.json\",\n  \"description\": \"CHAPS: same day high value GBP payments\",\n  \"type\": \"object\",\n  \"properties\": {\n    \"currency\": {\n      \"const\": \"GBP\"\n    },\n    \"reference\": {\n      \"maxLength\": 35\n    }\n  },\n  \"required\": [\"currency\"]\n}\n",
+		"scheme_fps.json":         "{\n  \"$schema\": \"http://json-schema.org/draft-07/schema#\",\n  \"$id\": \"https://payment-api/schemas/v1/scheme_fps.json\",\n  \"description\": \"Faster Payments: GBP only, capped at 1,000,000 per payment\",\n  \"type\": \"object\",\n  \"properties\": {\n    \"amount\": {\n      \"maximum\": 1000000\n    },\n    \"currency\": {\n      \"const\": \"GBP\"\n    },\n    \"scheme_payment_type\": {\n      \"enum\": [\"ImmediatePayment\", \"ForwardDatedPayment\", \"StandingOrder\"]\n    },\n    \"reference\": {\n      \"maxLength\": 18\n    }\n  },\n  \"required\": [\"currency\"]\n}\n",
+		"scheme_sepa.json":        "{\n  \"$schema\": \"http://json-schema.org/draft-07/schema#\",\n  \"$id\": \"https://payment-api/schemas/v1/scheme_sepa.json\",\n  \"description\": \"SEPA Credit Transfer: EUR only, parties identified by IBAN\",\n  \"type\": \"object\",\n  \"properties\": {\n    \"amount\": {\n      \"maximum\": 999999999.99\n    },\n    \"currency\": {\n      \"const\": \"EUR\"\n    },\n    \"scheme_payment_type\": {\n      \"enum\": [\"CreditTransfer\", \"InstantCreditTransfer\"]\n    },\n    \"reference\": {\n      \"maxLength\": 140\n    },\n    \"beneficiary_party\": {\n      \"$ref\": \"#/definitions/iban_party\"\n    },\n    \"debtor_party\": {\n      \"$ref\": \"#/definitions/iban_party\"\n    }\n  },\n  \"required\": [\"currency\"],\n  \"definitions\": {\n    \"iban_party\": {\n      \"properties\": {\n        \"account_number_code\": {\n          \"const\": \"IBAN\"\n        }\n      },\n      \"required\": [\"account_number\", \"account_number_code\"]\n    }\n  }\n}\n",
+		"scheme_swift.json":       "{\n  \"$schema\": \"http://json-schema.org/draft-07/schema#\",\n  \"$id\": \"https://payment-api/schemas/v1/scheme_swift.json\",\n  \"description\": \"SWIFT: cross border payments in any currency\",\n  \"type\": \"object\",\n  \"properties\": {\n    \"reference\": {\n      \"maxLength\": 35\n    }\n  },\n  \"required\": [\"currency\"]\n}\n",
+		"standing_order.json":     "{\n  \"$schema\": \"http://json-schema.org/draft-07/schema#\",\n  \"$id\": \"https://payment-api/schemas/v1/standing_order.json\",\n  \"type\": \"object\",\n  \"properties\": {\n    \"type\": {\n      \"type\": \"string\",\n      \"enum\": [\"StandingOrder\"]\n    },\n    \"id\": {\n      \"type\": \"string\"\n    },\n    \"organisation_id\": {\n      \"type\": \"string\"\n    },\n    \"template\": {\n      \"$ref\": \"payment_attributes.json\"\n    },\n    \"recurrence\": {\n      \"type\": \"object\",\n      \"properties\": {\n        \"frequency\": {\n          \"type\": \"string\",\n          \"enum\": [\"daily\", \"weekly\", \"monthly\"]\n        },\n        \"interval\": {\n          \"type\": \"integer\",\n          \"minimum\": 1\n        },\n        \"start_date\": {\n          \"type\": \"string\",\n          \"format\": \"date-time\"\n        },\n        \"end_date\": {\n          \"type\": \"string\",\n          \"format\": \"date-time\"\n        },\n        \"count\": {\n          \"type\": \"integer\",\n          \"minimum\": 1\n        }\n      },\n      \"required\": [\"frequency\", \"start_date\"]\n    },\n    \"status\": {\n      \"type\": \"string\",\n      \"enum\": [\"active\", \"paused\", \"completed\"],\n      \"readOnly\": true\n    },\n    \"created_by\": {\n      \"type\": \"string\",\n      \"readOnly\": true\n    },\n    \"created_at\": {\n      \"type\": \"string\",\n      \"format\": \"date-time\",\n      \"readOnly\": true\n    },\n    \"occurrences\": {\n      \"type\": \"integer\",\n      \"readOnly\": true\n    },\n    \"next_execution_date\": {\n      \"type\": \"string\",\n      \"format\": \"date-time\",\n      \"readOnly\": true\n    },\n    \"generated\": {\n      \"type\": \"array\",\n      \"items\": {\n        \"type\": \"object\",\n        \"properties\": {\n          \"occurrence\": {\n            \"type\": \"integer\"\n          },\n          \"execution_date\": {\n            \"type\": \"string\",\n            \"format\": \"date-time\"\n          },\n          \"payment_id\": {\n            \"type\": \"string\"\n          },\n          \"error\": {\n            \"type\": \"string\"\n          },\n          \"generated_at\": {\n            \"type\": \"string\",\n            \"format\": \"date-time\"\n          }\n        }\n      },\n      \"readOnly\": true\n    }\n  },\n  \"required\": [\"type\", \"organisation_id\", \"template\", \"recurrence\"]\n}\n",
+	},
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/brunovale91/payment-api/services"
+	"github.com/brunovale91/payment-api/types"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+)
+
+const standingOrderIdParam = "standingOrderID"
+
+var StandingOrderNotFound = &types.HttpError{StatusText: "Standing order not found"}
+
+func addStandingOrderRoutes(standingOrderService services.StandingOrderService, validator PaymentValidator) *chi.Mux {
+	router := chi.NewRouter()
+	setCreateStandingOrder(router, standingOrderService, validator)
+	setGetStandingOrders(router, standingOrderService)
+	setGetStandingOrderById(router, standingOrderService)
+	setPauseStandingOrder(router, standingOrderService)
+	setResumeStandingOrder(router, standingOrderService)
+	return router
+}
+
+func setCreateStandingOrder(router *chi.Mux, standingOrderService services.StandingOrderService, validator PaymentValidator) {
+	router.Post("/", func(w http.ResponseWriter, r *http.Request) {
+		var order types.StandingOrder
+		json.NewDecoder(r.Body).Decode(&order)
+
+		order.CreatedBy = r.Header.Get(userIdHeader)
+		errors := validator.ValidateStandingOrder(&order)
+		if errors != nil {
+			renderBadRequest(router, w, r, errors)
+			return
+		}
+
+		created, err := standingOrderService.CreateStandingOrder(&order)
+		if err != nil {
+			renderInternalError(router, w, r)
+		} else {
+			render.JSON(w, r, created)
+		}
+	})
+}
+
+// Standing orders, of the organisation given as a query parameter if any
+func setGetStandingOrders(router *chi.Mux, standingOrderService services.StandingOrderService) {
+	router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		orders, err := standingOrderService.GetStandingOrders(r.URL.Query().Get(organisationIdParam))
+		if err != nil {
+			renderInternalError(router, w, r)
+		} else {
+			render.JSON(w, r, &types.StandingOrders{Data: orders})
+		}
+	})
+}
+
+func setGetStandingOrderById(router *chi.Mux, standingOrderService services.StandingOrderService) {
+	router.Get("/{"+standingOrderIdParam+"}", func(w http.ResponseWriter, r *http.Request) {
+		order, err := standingOrderService.GetStandingOrder(chi.URLParam(r, standingOrderIdParam))
+		renderStandingOrder(router, w, r, order, err)
+	})
+}
+
+func setPauseStandingOrder(router *chi.Mux, standingOrderService services.StandingOrderService) {
+	router.Post("/{"+standingOrderIdParam+"}/pause", func(w http.ResponseWriter, r *http.Request) {
+		order, err := standingOrderService.PauseStandingOrder(chi.URLParam(r, standingOrderIdParam))
+		renderStandingOrder(router, w, r, order, err)
+	})
+}
+
+func setResumeStandingOrder(router *chi.Mux, standingOrderService services.StandingOrderService) {
+	router.Post("/{"+standingOrderIdParam+"}/resume", func(w http.ResponseWriter, r *http.Request) {
+		order, err := standingOrderService.ResumeStandingOrder(chi.URLParam(r, standingOrderIdParam))
+		renderStandingOrder(router, w, r, order, err)
+	})
+}
+
+func renderStandingOrder(router *chi.Mux, w http.ResponseWriter, r *http.Request, order *types.StandingOrder, err error) {
+	if stateErr, ok := err.(services.StateError); ok {
+		renderConflict(router, w, r, stateErr.Message, "")
+	} else if err != nil {
+		renderInternalError(router, w, r)
+	} else if order != nil {
+		render.JSON(w, r, order)
+	} else {
+		render.Status(r, 404)
+		render.JSON(w, r, StandingOrderNotFound)
+	}
+}
@@ -0,0 +1,52 @@
+//go:build ignore
+// +build ignore
+
+// Generates swaggerui_gen.go, embedding the Swagger UI files in this
+// directory so the docs page does not depend on a CDN.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+)
+
+func main() {
+	var files []string
+	for _, pattern := range []string{"swaggerui/*.css", "swaggerui/*.js"} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			log.Fatal(err)
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "// Code generated by swaggerui/generate.go. DO NOT EDIT.")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "package api")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "// Swagger UI file contents by file name")
+	fmt.Fprintln(&buf, "var swaggerUIFiles = map[string]string{")
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(&buf, "%q: %q,\n", filepath.Base(file), content)
+	}
+	fmt.Fprintln(&buf, "}")
+
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile("swaggerui_gen.go", source, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
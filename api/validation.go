@@ -1,95 +1,302 @@
 package api
 
+//go:generate go run schemas/generate.go
+
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
 	"github.com/brunovale91/payment-api/types"
 	"github.com/xeipuuv/gojsonschema"
 )
 
-// Validate payment against payment json schema
-func isValidPayment(payment *types.Payment) []string {
-	return isValid(getPaymentSchema(), payment)
+const schemaBaseId = "https://payment-api/schemas/"
+const paymentSchemaFile = "payment.json"
+const attributesSchemaFile = "payment_attributes.json"
+const standingOrderSchemaFile = "standing_order.json"
+const accountSchemaFile = "account.json"
+const beneficiarySchemaFile = "beneficiary.json"
+const schemaFileExt = ".json"
+
+// Versions of the generated schema files, which loaded versions are added to
+var embeddedVersions = getVersions(schemaFiles)
+
+type PaymentValidator interface {
+
+	// Validate payment against the payment schema and the constraints of its organisation
+	ValidatePayment(*types.Payment) []*types.FieldError
+
+	// Validate attributes against the attributes schema
+	ValidateAttributes(*types.PaymentAttributes) []*types.FieldError
+
+	// Validate standing order against the standing order schema and its
+	// template against the constraints of its organisation
+	ValidateStandingOrder(*types.StandingOrder) []*types.FieldError
+
+	// Validate account against the account schema
+	ValidateAccount(*types.Account) []*types.FieldError
+
+	// Validate beneficiary against the beneficiary schema
+	ValidateBeneficiary(*types.Beneficiary) []*types.FieldError
+
+	// Schema version validated, e.g. v1
+	Version() string
 }
 
-// Validate attributes against attributes json schema
-func isValidAtrributes(attributes *types.PaymentAttributes) []string {
-	return isValid(getPaymentAttributesSchema(), attributes)
+// Schemas are compiled once when the validator is created
+type PaymentValidatorImpl struct {
+	version             string
+	payment             *gojsonschema.Schema
+	attributes          *gojsonschema.Schema
+	standingOrder       *gojsonschema.Schema
+	account             *gojsonschema.Schema
+	beneficiary         *gojsonschema.Schema
+	organisationSchemas map[string]*gojsonschema.Schema
 }
 
-func isValid(schema map[string]interface{}, value interface{}) []string {
-	schemaLoader := gojsonschema.NewGoLoader(schema)
-	valueLoader := gojsonschema.NewGoLoader(value)
-	result, err := gojsonschema.Validate(schemaLoader, valueLoader)
-	messages := make([]string, 0)
+// Create validator for a schema version. organisationSchemas maps
+// organisation ids to JSON schemas with extra payment constraints, which can
+// reference the version schemas by their $id.
+func NewPaymentValidator(version string, organisationSchemas map[string]string) (PaymentValidator, error) {
+	if _, ok := schemaFiles[version]; !ok {
+		return nil, fmt.Errorf("unknown schema version %s", version)
+	}
+	payment, err := compileSchema(version, gojsonschema.NewReferenceLoader(schemaId(version, paymentSchemaFile)))
 	if err != nil {
-		messages = append(messages, "Failed to validate")
+		return nil, err
+	}
+	attributes, err := compileSchema(version, gojsonschema.NewReferenceLoader(schemaId(version, attributesSchemaFile)))
+	if err != nil {
+		return nil, err
+	}
+	standingOrder, err := compileSchema(version, gojsonschema.NewReferenceLoader(schemaId(version, standingOrderSchemaFile)))
+	if err != nil {
+		return nil, err
+	}
+	account, err := compileSchema(version, gojsonschema.NewReferenceLoader(schemaId(version, accountSchemaFile)))
+	if err != nil {
+		return nil, err
+	}
+	beneficiary, err := compileSchema(version, gojsonschema.NewReferenceLoader(schemaId(version, beneficiarySchemaFile)))
+	if err != nil {
+		return nil, err
+	}
+	compiled := make(map[string]*gojsonschema.Schema, len(organisationSchemas))
+	for organisationId, content := range organisationSchemas {
+		schema, err := compileSchema(version, gojsonschema.NewStringLoader(content))
+		if err != nil {
+			log.Printf("Error compiling %s schema for organisation %s: %s", version, organisationId, err.Error())
+			return nil, err
+		}
+		compiled[organisationId] = schema
+	}
+	return PaymentValidatorImpl{
+		version:             version,
+		payment:             payment,
+		attributes:          attributes,
+		standingOrder:       standingOrder,
+		account:             account,
+		beneficiary:         beneficiary,
+		organisationSchemas: compiled,
+	}, nil
+}
+
+// Load organisation schemas from the JSON files of a directory, named by
+// organisation id, e.g. org1.json. A missing directory has no schemas.
+func LoadOrganisationSchemas(dir string) (map[string]string, error) {
+	schemas := make(map[string]string)
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return schemas, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != schemaFileExt {
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		schemas[strings.TrimSuffix(file.Name(), schemaFileExt)] = string(content)
+	}
+	return schemas, nil
+}
+
+// Add the schema versions in subdirectories of dir to the embedded ones, so
+// a new version can be tried before it is embedded. Embedded versions cannot
+// be replaced.
+func LoadSchemaVersions(dir string) error {
+	versions, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, version := range versions {
+		if !version.IsDir() {
+			continue
+		}
+		if embeddedVersions[version.Name()] {
+			return fmt.Errorf("schema version %s is embedded", version.Name())
+		}
+		files, err := filepath.Glob(filepath.Join(dir, version.Name(), "*"+schemaFileExt))
+		if err != nil {
+			return err
+		}
+		versionFiles := make(map[string]string, len(files))
+		for _, file := range files {
+			content, err := ioutil.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			versionFiles[filepath.Base(file)] = string(content)
+		}
+		schemaFiles[version.Name()] = versionFiles
+	}
+	return nil
+}
+
+func (v PaymentValidatorImpl) ValidatePayment(payment *types.Payment) []*types.FieldError {
+	messages := validate(v.payment, payment)
+	if schema, ok := v.organisationSchemas[payment.OrganisationId]; ok {
+		messages = append(messages, validate(schema, payment)...)
+	}
+	return returnMessages(messages)
+}
+
+func (v PaymentValidatorImpl) ValidateAttributes(attributes *types.PaymentAttributes) []*types.FieldError {
+	return returnMessages(validate(v.attributes, attributes))
+}
+
+// Organisation constraints apply to the payments the template generates,
+// their errors are reported on the template fields
+func (v PaymentValidatorImpl) ValidateStandingOrder(order *types.StandingOrder) []*types.FieldError {
+	messages := validate(v.standingOrder, order)
+	if schema, ok := v.organisationSchemas[order.OrganisationId]; ok && order.Template != nil {
+		payment := &types.Payment{
+			Type:           "Payment",
+			OrganisationId: order.OrganisationId,
+			Attributes:     order.Template,
+		}
+		for _, fieldErr := range validate(schema, payment) {
+			if strings.HasPrefix(fieldErr.Field, "attributes") {
+				fieldErr.Field = "template" + strings.TrimPrefix(fieldErr.Field, "attributes")
+			}
+			messages = append(messages, fieldErr)
+		}
+	}
+	return returnMessages(messages)
+}
+
+func (v PaymentValidatorImpl) ValidateAccount(account *types.Account) []*types.FieldError {
+	return returnMessages(validate(v.account, account))
+}
+
+func (v PaymentValidatorImpl) ValidateBeneficiary(beneficiary *types.Beneficiary) []*types.FieldError {
+	return returnMessages(validate(v.beneficiary, beneficiary))
+}
+
+func (v PaymentValidatorImpl) Version() string {
+	return v.version
+}
+
+func validate(schema *gojsonschema.Schema, value interface{}) []*types.FieldError {
+	result, err := schema.Validate(gojsonschema.NewGoLoader(value))
+	messages := make([]*types.FieldError, 0)
+	if err != nil {
+		return append(messages, &types.FieldError{Message: "Failed to validate"})
 	}
 	if !result.Valid() {
 		for _, desc := range result.Errors() {
-			messages = append(messages, desc.Description())
+			if summaryErrorTypes[desc.Type()] {
+				continue
+			}
+			messages = append(messages, &types.FieldError{
+				Field:   errorField(desc),
+				Message: desc.Description(),
+			})
 		}
 	}
-	return returnMessages(messages)
+	return messages
+}
+
+// Schema error types that only summarise errors of nested schemas
+var summaryErrorTypes = map[string]bool{
+	"number_all_of":  true,
+	"number_any_of":  true,
+	"number_one_of":  true,
+	"condition_then": true,
+	"condition_else": true,
+}
+
+// Path of the field a schema error refers to, required errors are reported
+// on the missing property rather than its parent
+func errorField(desc gojsonschema.ResultError) string {
+	field := desc.Field()
+	if property, ok := desc.Details()["property"].(string); ok && desc.Type() == "required" {
+		if field == gojsonschema.STRING_ROOT_SCHEMA_PROPERTY {
+			return property
+		}
+		return strings.Join([]string{field, property}, ".")
+	}
+	return field
 }
 
-func returnMessages(messages []string) []string {
+func returnMessages(messages []*types.FieldError) []*types.FieldError {
 	if len(messages) > 0 {
 		return messages
 	}
 	return nil
 }
 
-func getPaymentSchema() map[string]interface{} {
-	return map[string]interface{}{
-		"type": "object",
-		"properties": map[string]interface{}{
-			"type": map[string]interface{}{
-				"type": "string",
-				"enum": [...]string{"Payment"},
-			},
-			"id": map[string]interface{}{
-				"type": "string",
-			},
-			"organisation_id": map[string]interface{}{
-				"type": "string",
-			},
-			"attributes": getPaymentAttributesSchema(),
-		},
-		"required": [...]string{"type", "organisation_id"},
-	}
-}
-
-func getPaymentAttributesSchema() map[string]interface{} {
-	return map[string]interface{}{
-		"type": "object",
-		"properties": map[string]interface{}{
-			"amount": map[string]interface{}{
-				"type":             "number",
-				"exclusiveMinimum": 0,
-			},
-			"beneficiary_party": getPaymentPartySchema(),
-			"debtor_party":      getPaymentPartySchema(),
-			"end_to_end_reference": map[string]interface{}{
-				"type": "string",
-			},
-		},
-		"required": [...]string{"amount", "beneficiary_party", "debtor_party", "end_to_end_reference"},
-	}
-}
-
-func getPaymentPartySchema() map[string]interface{} {
-	return map[string]interface{}{
-		"type": "object",
-		"properties": map[string]interface{}{
-			"bank_id": map[string]interface{}{
-				"type": "string",
-			},
-			"bank_id_code": map[string]interface{}{
-				"type": "string",
-			},
-			"name": map[string]interface{}{
-				"type": "string",
-			},
-		},
-		"required": [...]string{"bank_id", "bank_id_code", "name"},
+// Compile schema with every schema of the version available to $ref
+func compileSchema(version string, root gojsonschema.JSONLoader) (*gojsonschema.Schema, error) {
+	loader := gojsonschema.NewSchemaLoader()
+	for _, name := range schemaFileNames(version) {
+		if err := loader.AddSchemas(gojsonschema.NewStringLoader(schemaFiles[version][name])); err != nil {
+			return nil, err
+		}
+	}
+	return loader.Compile(root)
+}
+
+func getVersions(files map[string]map[string]string) map[string]bool {
+	versions := make(map[string]bool, len(files))
+	for version := range files {
+		versions[version] = true
+	}
+	return versions
+}
+
+func schemaId(version string, file string) string {
+	return schemaBaseId + version + "/" + file
+}
+
+func schemaFileNames(version string) []string {
+	names := make([]string, 0, len(schemaFiles[version]))
+	for name := range schemaFiles[version] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Parsed schema documents of a version by file name
+func getSchemaDocuments(version string) map[string]map[string]interface{} {
+	documents := make(map[string]map[string]interface{})
+	for name, content := range schemaFiles[version] {
+		var document map[string]interface{}
+		if err := json.Unmarshal([]byte(content), &document); err != nil {
+			log.Panicf("Invalid schema %s/%s: %s", version, name, err.Error())
+		}
+		documents[name] = document
 	}
+	return documents
 }
@@ -0,0 +1,190 @@
+package batchfile
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brunovale91/payment-api/types"
+)
+
+// NACHA records are 94 characters, in blocks of 10 records
+const (
+	nachaRecordLength = 94
+	nachaBlockSize    = 10
+)
+
+// Largest amount in cents of an entry detail record
+const maxNachaAmount = 9999999999
+
+// Service class of batches that only contain credits
+const creditsOnly = "220"
+
+// Transaction code of an automated deposit to a checking account
+const checkingCredit = "22"
+
+type NachaConfig struct {
+	// Routing number of the receiving point of the file, 9 digits
+	ImmediateDestination     string
+	ImmediateDestinationName string
+
+	// Originator of the file, 10 characters, a company id or a routing number
+	// after a space
+	ImmediateOrigin     string
+	ImmediateOriginName string
+
+	CompanyName string
+
+	// Company identification, 10 characters
+	CompanyId string
+
+	// Standard entry class code, e.g. PPD or CCD
+	EntryClass string
+
+	// Routing number of the originating depository financial institution
+	// without its check digit, 8 digits
+	OriginatingDFI string
+}
+
+// NACHA ACH file with a single batch of credits to the beneficiaries of
+// payments, effective on the latest of effectiveDate and their processing
+// dates
+func NewNacha(config *NachaConfig, payments []*types.Payment, now time.Time, effectiveDate time.Time) ([]byte, error) {
+	var errors formatErrors
+	if !isRoutingNumber(config.ImmediateDestination) {
+		errors.add("immediate destination must be a routing number")
+	}
+	if !isImmediateOrigin(config.ImmediateOrigin) {
+		errors.add("immediate origin must have 10 digits or a space and 9 digits")
+	}
+	if !isDigits(config.OriginatingDFI, 8) {
+		errors.add("originating DFI must have 8 digits")
+	}
+	if strings.TrimSpace(config.CompanyId) == "" || len(config.CompanyId) > 10 {
+		errors.add("company id must have 1 to 10 characters")
+	}
+	if len(config.EntryClass) != 3 {
+		errors.add("entry class must have 3 characters")
+	}
+	if len(payments) == 0 {
+		errors.add("no payments to export")
+	}
+
+	entries := make([]string, 0, len(payments))
+	var entryHash, creditTotal int64
+	for i, payment := range payments {
+		attributes := payment.Attributes
+		if attributes == nil || attributes.BeneficiaryParty == nil {
+			errors.add("payment %s has no beneficiary", payment.Id)
+			continue
+		}
+		beneficiary := attributes.BeneficiaryParty
+		if beneficiary.BankIdCode != "USABA" || !isRoutingNumber(beneficiary.BankId) {
+			errors.add("payment %s beneficiary bank is not an ABA routing number", payment.Id)
+			continue
+		}
+		if beneficiary.AccountNumber == "" || len(beneficiary.AccountNumber) > 17 {
+			errors.add("payment %s beneficiary account number must have 1 to 17 characters", payment.Id)
+		}
+		if attributes.Currency != "" && attributes.Currency != "USD" {
+			errors.add("payment %s is not in USD", payment.Id)
+		}
+		amount := int64(math.Round(attributes.Amount * 100))
+		if amount <= 0 || amount > maxNachaAmount {
+			errors.add("payment %s amount is out of range", payment.Id)
+		}
+		if attributes.ProcessingDate != "" {
+			if date, err := time.Parse("2006-01-02", attributes.ProcessingDate); err == nil && date.After(effectiveDate) {
+				effectiveDate = date
+			}
+		}
+		routing, _ := strconv.ParseInt(beneficiary.BankId[:8], 10, 64)
+		entryHash += routing
+		creditTotal += amount
+		entries = append(entries, "6"+
+			checkingCredit+
+			beneficiary.BankId+
+			alpha(beneficiary.AccountNumber, 17, isNachaCharacter)+
+			numeric(amount, 10)+
+			alpha(attributes.EndToEndReference, 15, isNachaCharacter)+
+			alpha(beneficiaryName(beneficiary), 22, isNachaCharacter)+
+			"  "+
+			"0"+
+			config.OriginatingDFI+numeric(int64(i+1), 7))
+	}
+	if err := errors.err(); err != nil {
+		return nil, err
+	}
+
+	// Hash totals keep their 10 rightmost digits
+	entryHash = entryHash % 10000000000
+	companyId := alpha(config.CompanyId, 10, isNachaCharacter)
+	records := []string{
+		"1" + "01" +
+			" " + config.ImmediateDestination +
+			config.ImmediateOrigin +
+			now.Format("060102") + now.Format("1504") + "A" + "094" + "10" + "1" +
+			alpha(config.ImmediateDestinationName, 23, isNachaCharacter) +
+			alpha(config.ImmediateOriginName, 23, isNachaCharacter) +
+			strings.Repeat(" ", 8),
+		"5" + creditsOnly +
+			alpha(config.CompanyName, 16, isNachaCharacter) +
+			strings.Repeat(" ", 20) +
+			companyId +
+			alpha(config.EntryClass, 3, isNachaCharacter) +
+			alpha("PAYMENT", 10, isNachaCharacter) +
+			strings.Repeat(" ", 6) +
+			effectiveDate.Format("060102") +
+			strings.Repeat(" ", 3) + "1" +
+			config.OriginatingDFI + numeric(1, 7),
+	}
+	records = append(records, entries...)
+	records = append(records,
+		"8"+creditsOnly+
+			numeric(int64(len(entries)), 6)+
+			numeric(entryHash, 10)+
+			numeric(0, 12)+numeric(creditTotal, 12)+
+			companyId+
+			strings.Repeat(" ", 19)+strings.Repeat(" ", 6)+
+			config.OriginatingDFI+numeric(1, 7))
+	blocks := (len(records) + 1 + nachaBlockSize - 1) / nachaBlockSize
+	records = append(records,
+		"9"+numeric(1, 6)+
+			numeric(int64(blocks), 6)+
+			numeric(int64(len(entries)), 8)+
+			numeric(entryHash, 10)+
+			numeric(0, 12)+numeric(creditTotal, 12)+
+			strings.Repeat(" ", 39))
+	for len(records)%nachaBlockSize != 0 {
+		records = append(records, strings.Repeat("9", nachaRecordLength))
+	}
+
+	nachaLength := func(string) int { return nachaRecordLength }
+	if err := validateRecords(records, nachaLength, isNachaCharacter); err != nil {
+		return nil, err
+	}
+	return joinRecords(records), nil
+}
+
+// ABA routing number, 9 digits with a weighted 3 7 1 checksum
+func isRoutingNumber(value string) bool {
+	if !isDigits(value, 9) {
+		return false
+	}
+	weights := []int{3, 7, 1}
+	sum := 0
+	for i, char := range value {
+		sum += int(char-'0') * weights[i%3]
+	}
+	return sum%10 == 0
+}
+
+func isImmediateOrigin(origin string) bool {
+	return isDigits(origin, 10) || (strings.HasPrefix(origin, " ") && isDigits(origin[1:], 9))
+}
+
+// Upper case letters, digits and printable punctuation
+func isNachaCharacter(char rune) bool {
+	return char >= ' ' && char <= '~' && !(char >= 'a' && char <= 'z')
+}
@@ -0,0 +1,81 @@
+package batchfile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Payments cannot be written in the requested file format
+type FormatError struct {
+	Message string
+}
+
+func (e FormatError) Error() string {
+	return e.Message
+}
+
+// Problems found while building a file, reported together
+type formatErrors []string
+
+func (e *formatErrors) add(format string, args ...interface{}) {
+	*e = append(*e, fmt.Sprintf(format, args...))
+}
+
+func (e formatErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return FormatError{Message: strings.Join(e, "; ")}
+}
+
+// Left justified, space padded upper case text. Characters outside the
+// allowed set become spaces.
+func alpha(value string, length int, allowed func(rune) bool) string {
+	converted := []rune(strings.ToUpper(value))
+	for i, char := range converted {
+		if !allowed(char) {
+			converted[i] = ' '
+		}
+	}
+	text := string(converted)
+	if len(text) > length {
+		return text[:length]
+	}
+	return text + strings.Repeat(" ", length-len(text))
+}
+
+// Right justified, zero padded number, numbers that do not fit are reported
+// by validateRecords as records of the wrong length
+func numeric(value int64, length int) string {
+	return fmt.Sprintf("%0*d", length, value)
+}
+
+func isDigits(value string, length int) bool {
+	if len(value) != length {
+		return false
+	}
+	_, err := strconv.ParseUint(value, 10, 64)
+	return err == nil
+}
+
+// Every record must have the length of its format and only allowed characters
+func validateRecords(records []string, length func(string) int, allowed func(rune) bool) error {
+	var errors formatErrors
+	for i, record := range records {
+		if len(record) != length(record) {
+			errors.add("record %d has length %d instead of %d", i+1, len(record), length(record))
+		}
+		for _, char := range record {
+			if !allowed(char) {
+				errors.add("record %d has invalid character %q", i+1, char)
+				break
+			}
+		}
+	}
+	return errors.err()
+}
+
+func joinRecords(records []string) []byte {
+	return []byte(strings.Join(records, "\r\n") + "\r\n")
+}
@@ -0,0 +1,234 @@
+package batchfile
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brunovale91/payment-api/types"
+)
+
+// Label records are 80 characters, detail and contra records 100
+const (
+	labelLength  = 80
+	detailLength = 100
+)
+
+// Largest amount in pence of a detail record
+const maxStandard18Amount = 99999999999
+
+// Bacs transaction codes of a credit and its balancing contra debit
+const (
+	creditTransaction = "99"
+	contraTransaction = "17"
+)
+
+type Standard18Config struct {
+	// Bacs service user number, 6 digits
+	ServiceUserNumber string
+
+	// Service user name, written to every detail record
+	ServiceUserName string
+}
+
+// Sort code and account of a UK party
+type ukAccount struct {
+	sortCode string
+	account  string
+}
+
+// Bacs Standard 18 file of credits to the beneficiaries of payments, one
+// contra record per originating account. Payments are processed on the latest
+// of their processing dates.
+func NewStandard18(config *Standard18Config, payments []*types.Payment, now time.Time) ([]byte, error) {
+	var errors formatErrors
+	if !isDigits(config.ServiceUserNumber, 6) {
+		errors.add("service user number must have 6 digits")
+	}
+	if len(payments) == 0 {
+		errors.add("no payments to export")
+	}
+	processingDate := now
+	groups := make(map[ukAccount][]string)
+	totals := make(map[ukAccount]int64)
+	var creditTotal int64
+	for _, payment := range payments {
+		attributes := payment.Attributes
+		if attributes == nil || attributes.DebtorParty == nil || attributes.BeneficiaryParty == nil {
+			errors.add("payment %s has no parties", payment.Id)
+			continue
+		}
+		origin, ok := getUkAccount(attributes.DebtorParty)
+		if !ok {
+			errors.add("payment %s debtor has no UK sort code and account number", payment.Id)
+		}
+		destination, ok := getUkAccount(attributes.BeneficiaryParty)
+		if !ok {
+			errors.add("payment %s beneficiary has no UK sort code and account number", payment.Id)
+		}
+		if attributes.Currency != "" && attributes.Currency != "GBP" {
+			errors.add("payment %s is not in GBP", payment.Id)
+		}
+		amount := int64(math.Round(attributes.Amount * 100))
+		if amount <= 0 || amount > maxStandard18Amount {
+			errors.add("payment %s amount is out of range", payment.Id)
+		}
+		reference := bacsReference(attributes)
+		if countAlphanumeric(reference) < 6 {
+			errors.add("payment %s reference must have at least 6 letters or digits", payment.Id)
+		}
+		if attributes.ProcessingDate != "" {
+			if date, err := time.Parse("2006-01-02", attributes.ProcessingDate); err == nil && date.After(processingDate) {
+				processingDate = date
+			}
+		}
+		groups[origin] = append(groups[origin], destination.sortCode+
+			alpha(destination.account, 8, isBacsCharacter)+
+			"0"+
+			creditTransaction+
+			origin.sortCode+
+			alpha(origin.account, 8, isBacsCharacter)+
+			strings.Repeat(" ", 4)+
+			numeric(amount, 11)+
+			alpha(config.ServiceUserName, 18, isBacsCharacter)+
+			alpha(reference, 18, isBacsCharacter)+
+			alpha(beneficiaryName(attributes.BeneficiaryParty), 18, isBacsCharacter))
+		totals[origin] += amount
+		creditTotal += amount
+	}
+	if err := errors.err(); err != nil {
+		return nil, err
+	}
+
+	records := []string{
+		"VOL1" + numeric(1, 6) + " " + strings.Repeat(" ", 26) +
+			strings.Repeat(" ", 4) + config.ServiceUserNumber + strings.Repeat(" ", 4) +
+			strings.Repeat(" ", 28) + "1",
+		fileLabel("HDR1", config.ServiceUserNumber, now, 0),
+		formatLabel("HDR2"),
+		"UHL1" + julianDate(processingDate) + "999999    " + "00" + "000000" + "1 DAILY  " + "001" +
+			strings.Repeat(" ", 40),
+	}
+	for _, origin := range sortedAccounts(groups) {
+		sort.Strings(groups[origin])
+		records = append(records, groups[origin]...)
+		records = append(records, origin.sortCode+
+			alpha(origin.account, 8, isBacsCharacter)+
+			"0"+
+			contraTransaction+
+			origin.sortCode+
+			alpha(origin.account, 8, isBacsCharacter)+
+			strings.Repeat(" ", 4)+
+			numeric(totals[origin], 11)+
+			alpha(config.ServiceUserName, 18, isBacsCharacter)+
+			alpha("CONTRA", 18, isBacsCharacter)+
+			alpha(config.ServiceUserName, 18, isBacsCharacter))
+	}
+	records = append(records,
+		fileLabel("EOF1", config.ServiceUserNumber, now, blockCount(len(payments)+len(groups))),
+		formatLabel("EOF2"),
+		"UTL1"+numeric(creditTotal, 13)+numeric(creditTotal, 13)+
+			numeric(int64(len(groups)), 7)+numeric(int64(len(payments)), 7)+strings.Repeat(" ", 36))
+
+	if err := validateRecords(records, standard18Length, isBacsCharacter); err != nil {
+		return nil, err
+	}
+	return joinRecords(records), nil
+}
+
+// HDR1 and EOF1 labels, which differ in their name and block count
+func fileLabel(name string, serviceUserNumber string, now time.Time, blockCount int) string {
+	return name +
+		"A" + serviceUserNumber + "S  " + serviceUserNumber + " " +
+		numeric(1, 6) + "0001" + "0001" + strings.Repeat(" ", 6) +
+		julianDate(now) + julianDate(now) + " " +
+		numeric(int64(blockCount), 6) + strings.Repeat(" ", 20)
+}
+
+// Blocks of 2000 characters the detail and contra records fill
+func blockCount(records int) int {
+	return (records*detailLength + 1999) / 2000
+}
+
+// HDR2 and EOF2 labels, fixed length records in blocks of 2000
+func formatLabel(name string) string {
+	return name + "F" + "02000" + "00100" + strings.Repeat(" ", 35) + "00" + strings.Repeat(" ", 28)
+}
+
+func standard18Length(record string) int {
+	for _, label := range []string{"VOL1", "HDR1", "HDR2", "UHL1", "EOF1", "EOF2", "UTL1"} {
+		if strings.HasPrefix(record, label) {
+			return labelLength
+		}
+	}
+	return detailLength
+}
+
+// Dates are written as a space, the year and the day of the year
+func julianDate(date time.Time) string {
+	return fmt.Sprintf(" %s%03d", date.Format("06"), date.YearDay())
+}
+
+// UK sort code and account number of a party, from its bank id and account
+// number or from a GB IBAN
+func getUkAccount(party *types.PaymentParty) (ukAccount, bool) {
+	if party.AccountNumberCode == "IBAN" {
+		return ukAccountFromIban(party.AccountNumber)
+	}
+	if party.BankIdCode == "GBDSC" && isDigits(party.BankId, 6) && isDigits(party.AccountNumber, 8) {
+		return ukAccount{sortCode: party.BankId, account: party.AccountNumber}, true
+	}
+	if party.BankIdCode == "IBAN" && party.AccountNumber == "" {
+		return ukAccountFromIban(party.BankId)
+	}
+	return ukAccount{}, false
+}
+
+func ukAccountFromIban(iban string) (ukAccount, bool) {
+	if len(iban) != 22 || !strings.HasPrefix(iban, "GB") || !isDigits(iban[8:], 14) {
+		return ukAccount{}, false
+	}
+	return ukAccount{sortCode: iban[8:14], account: iban[14:]}, true
+}
+
+func bacsReference(attributes *types.PaymentAttributes) string {
+	if attributes.Reference != "" {
+		return attributes.Reference
+	}
+	return attributes.EndToEndReference
+}
+
+func beneficiaryName(party *types.PaymentParty) string {
+	if party.AccountName != "" {
+		return party.AccountName
+	}
+	return party.Name
+}
+
+func countAlphanumeric(value string) int {
+	count := 0
+	for _, char := range strings.ToUpper(value) {
+		if char >= 'A' && char <= 'Z' || char >= '0' && char <= '9' {
+			count++
+		}
+	}
+	return count
+}
+
+func sortedAccounts(groups map[ukAccount][]string) []ukAccount {
+	accounts := make([]ukAccount, 0, len(groups))
+	for account := range groups {
+		accounts = append(accounts, account)
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].sortCode+accounts[i].account < accounts[j].sortCode+accounts[j].account
+	})
+	return accounts
+}
+
+// Bacs character set: upper case letters, digits, space and . & / -
+func isBacsCharacter(char rune) bool {
+	return char >= 'A' && char <= 'Z' || char >= '0' && char <= '9' || strings.ContainsRune(" .&/-", char)
+}
@@ -0,0 +1,126 @@
+package calendar
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// Holiday file extension, the file name is the calendar id
+const holidayFileExt = ".txt"
+
+type Calendar interface {
+
+	// Id of the calendar, e.g. a currency code
+	Id() string
+
+	// Whether date is neither a weekend day nor a holiday
+	IsBusinessDay(time.Time) bool
+
+	// First business day after date
+	NextBusinessDay(time.Time) time.Time
+
+	// Date if it is a business day, otherwise the next business day
+	RollForward(time.Time) time.Time
+}
+
+// Days are compared by their UTC date, times of day are kept
+type CalendarImpl struct {
+	id       string
+	holidays map[string]string
+}
+
+// Create calendar from holiday dates, formatted as 2006-01-02, to names
+func NewCalendar(id string, holidays map[string]string) Calendar {
+	return CalendarImpl{
+		id:       id,
+		holidays: holidays,
+	}
+}
+
+// Calendar with weekends as the only non business days
+func NewWeekendCalendar() Calendar {
+	return NewCalendar("", nil)
+}
+
+func (c CalendarImpl) Id() string {
+	return c.id
+}
+
+func (c CalendarImpl) IsBusinessDay(date time.Time) bool {
+	date = date.UTC()
+	if date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+		return false
+	}
+	_, holiday := c.holidays[date.Format(dateLayout)]
+	return !holiday
+}
+
+func (c CalendarImpl) NextBusinessDay(date time.Time) time.Time {
+	return c.RollForward(date.AddDate(0, 0, 1))
+}
+
+func (c CalendarImpl) RollForward(date time.Time) time.Time {
+	for !c.IsBusinessDay(date) {
+		date = date.AddDate(0, 0, 1)
+	}
+	return date
+}
+
+// Load every holiday file in a directory by calendar id
+func LoadCalendars(dir string) (map[string]Calendar, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	calendars := make(map[string]Calendar)
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != holidayFileExt {
+			continue
+		}
+		id := strings.TrimSuffix(file.Name(), holidayFileExt)
+		calendar, err := LoadCalendar(id, filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		calendars[id] = calendar
+	}
+	return calendars, nil
+}
+
+// Load calendar from a holiday file, with a date and its name per line.
+// Blank lines and lines starting with # are ignored.
+func LoadCalendar(id string, file string) (Calendar, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	holidays := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.SplitN(text, " ", 2)
+		if _, err := time.Parse(dateLayout, fields[0]); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid holiday date %q", file, line, fields[0])
+		}
+		name := ""
+		if len(fields) > 1 {
+			name = strings.TrimSpace(fields[1])
+		}
+		holidays[fields[0]] = name
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewCalendar(id, holidays), nil
+}
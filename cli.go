@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/brunovale91/payment-api/fx"
+	"github.com/brunovale91/payment-api/types"
+)
+
+// Run a command instead of serving the api, e.g.
+// ./main export-pain001 -organisation_id org -out payments.xml
+func runCommand(config *ConfigProperties, args []string) error {
+	switch args[0] {
+	case "export-pain001":
+		return exportPain001(config, args[1:])
+	case "import-rates":
+		return importRates(config, args[1:])
+	case "check-ledger":
+		return checkLedger(config)
+	case "find-duplicate-references":
+		return findDuplicateReferences(config)
+	}
+	return fmt.Errorf("unknown command %s", args[0])
+}
+
+func exportPain001(config *ConfigProperties, args []string) error {
+	flags := flag.NewFlagSet("export-pain001", flag.ContinueOnError)
+	organisationId := flags.String("organisation_id", "", "export payments of this organisation")
+	ids := flags.String("id", "", "comma separated payment ids to export")
+	out := flags.String("out", "", "output file, defaults to stdout")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	filter := &types.PaymentFilter{OrganisationId: *organisationId}
+	if *ids != "" {
+		filter.Ids = strings.Split(*ids, ",")
+	}
+	document, err := getApplication(config).export.ExportPain001(filter)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = os.Stdout.Write(document)
+		return err
+	}
+	return ioutil.WriteFile(*out, document, 0644)
+}
+
+// Upload exchange rates from a CSV file, e.g.
+// ./main import-rates -file rates.csv
+func importRates(config *ConfigProperties, args []string) error {
+	flags := flag.NewFlagSet("import-rates", flag.ContinueOnError)
+	file := flags.String("file", "", "CSV file of base_currency,quote_currency,rate,effective_at lines")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return fmt.Errorf("-file is required")
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	rates, err := fx.ParseRates(f)
+	if err != nil {
+		return err
+	}
+	created, err := getFxService(config).CreateRates(rates)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d rates\n", len(created))
+	return nil
+}
+
+// Check that ledger entries sum to zero in every currency, e.g.
+// ./main check-ledger
+func checkLedger(config *ConfigProperties) error {
+	if err := getLedgerService(config).CheckInvariant(); err != nil {
+		return err
+	}
+	fmt.Println("Ledger is balanced")
+	return nil
+}
+
+// List payments sharing an end to end reference within an organisation, one
+// line per reference, e.g.
+// ./main find-duplicate-references
+// The reference index is only created once they are changed or deleted.
+func findDuplicateReferences(config *ConfigProperties) error {
+	duplicates, err := getApplication(config).paymentStore.GetDuplicateReferences()
+	if err != nil {
+		return err
+	}
+	for _, duplicate := range duplicates {
+		fmt.Printf("%s\t%s\t%s\n", duplicate.OrganisationId, duplicate.EndToEndReference,
+			strings.Join(duplicate.PaymentIds, ","))
+	}
+	fmt.Printf("Found %d duplicate references\n", len(duplicates))
+	return nil
+}
@@ -1,22 +1,250 @@
 package main
 
+import (
+	"time"
+
+	"github.com/brunovale91/payment-api/batchfile"
+	"github.com/brunovale91/payment-api/services"
+	"github.com/brunovale91/payment-api/types"
+)
+
+// Event stream implementations
+const (
+	ChangeStream  = "changestream"
+	PollingStream = "polling"
+)
+
 type ConfigProperties struct {
-	MongoURL   string
-	Database   string
-	Collection string
-	Port       string
+	MongoURL         string
+	Database         string
+	Collection       string
+	OutboxCollection string
+	RelayInterval    time.Duration
+	RelayBatchSize   int64
+	EventStream      string
+	PollInterval     time.Duration
+	SchemaVersions   []string
+	Port             string
+
+	// Payments with the same amount and accounts created within the window
+	// are duplicates, rejected or flagged by policy
+	DuplicateWindow               time.Duration
+	DuplicatePolicy               string
+	OrganisationDuplicatePolicies map[string]string
+
+	// Amount and velocity limits of payments, per organisation
+	DefaultLimits      *types.Limits
+	OrganisationLimits map[string]*types.Limits
+
+	// Release of scheduled payments, leased by one replica at a time
+	SchedulerInterval      time.Duration
+	SchedulerLeaseDuration time.Duration
+	SchedulerBatchSize     int
+
+	// Source of the current time of the scheduler and standing orders, the
+	// system clock when nil
+	Clock services.Clock
+
+	// Directory of holiday files by calendar id, payments are processed on
+	// business days of the calendar of their currency or debtor country
+	CalendarDirectory string
+
+	// Scheme to cut-off time of day in UTC, payments submitted later are
+	// processed on the next business day
+	CutOffs map[string]time.Duration
+
+	// Schemes settling every day, whatever the calendar
+	ContinuousSchemes []string
+
+	// Pricing of payment charges by scheme, per organisation
+	DefaultPricing      services.Pricing
+	OrganisationPricing map[string]services.Pricing
+
+	// Approvals required by payments over a threshold, per organisation
+	DefaultApprovalPolicy        *services.ApprovalPolicy
+	OrganisationApprovalPolicies map[string]*services.ApprovalPolicy
+
+	// Collection of camt.053 reconciliation reports
+	ReconciliationCollection string
+
+	// Collection of standing orders, generated by the scheduler
+	StandingOrderCollection string
+
+	// Collections of exchange rates and quotes, quoted rates are guaranteed
+	// for QuoteTTL
+	FxRateCollection  string
+	FxQuoteCollection string
+	QuoteTTL          time.Duration
+
+	// Collection of double-entry ledger entries posted for payment changes
+	LedgerCollection string
+
+	// Collections of saved accounts and beneficiaries payments reference
+	AccountCollection     string
+	BeneficiaryCollection string
+
+	// Collection of per-organisation counters that serialise the payment
+	// writes organisation limits are checked for
+	CounterCollection string
+
+	// Name of the initiating party in exported ISO 20022 messages
+	InitiatingPartyName string
+
+	// BIC in the basic header of exported MT103 messages, the debtor bank
+	// BIC is used when empty
+	SwiftSenderBIC string
+
+	// Service user of exported Bacs Standard 18 files
+	Standard18 *batchfile.Standard18Config
+
+	// Originator and receiving point of exported NACHA files
+	Nacha *batchfile.NachaConfig
+
+	// Directory of JSON schemas with extra payment constraints, one file per
+	// organisation named by its id, e.g. org1.json
+	OrganisationSchemaDirectory string
+
+	// Directory of schema versions that are not embedded, one subdirectory
+	// of schema files per version. Versions are served when listed in
+	// SchemaVersions.
+	SchemaDirectory string
 }
 
 var Config = &ConfigProperties{
-	MongoURL:   "mongodb://mongodb:27017",
-	Database:   "paymentsDev",
-	Collection: "payments",
-	Port:       "8080",
+	MongoURL:         "mongodb://mongodb:27017/?replicaSet=rs0",
+	Database:         "paymentsDev",
+	Collection:       "payments",
+	OutboxCollection: "outbox",
+	RelayInterval:    time.Second,
+	RelayBatchSize:   100,
+	EventStream:      ChangeStream,
+	PollInterval:     time.Second,
+	SchemaVersions:   []string{"v1"},
+	Port:             "8080",
+
+	ReconciliationCollection: "reconciliations",
+	StandingOrderCollection:  "standingOrders",
+
+	FxRateCollection:  "fxRates",
+	FxQuoteCollection: "fxQuotes",
+	QuoteTTL:          5 * time.Minute,
+
+	LedgerCollection:      "ledgerEntries",
+	AccountCollection:     "accounts",
+	BeneficiaryCollection: "beneficiaries",
+	CounterCollection:     "organisationCounters",
+
+	SchedulerInterval:      time.Minute,
+	SchedulerLeaseDuration: time.Minute,
+	SchedulerBatchSize:     100,
+
+	CalendarDirectory: "calendar/holidays",
+
+	OrganisationSchemaDirectory: "schemas/organisations",
+	CutOffs: map[string]time.Duration{
+		"BACS":  21 * time.Hour,
+		"CHAPS": 16 * time.Hour,
+		"SEPA":  15 * time.Hour,
+		"SWIFT": 14 * time.Hour,
+	},
+	ContinuousSchemes: []string{"FPS"},
+
+	DuplicateWindow: 24 * time.Hour,
+	DuplicatePolicy: services.DuplicateFlag,
+
+	InitiatingPartyName: "Payment API",
+	Standard18: &batchfile.Standard18Config{
+		ServiceUserNumber: "123456",
+		ServiceUserName:   "Payment API",
+	},
+	Nacha: &batchfile.NachaConfig{
+		ImmediateDestination:     "021000021",
+		ImmediateDestinationName: "Receiving Bank",
+		ImmediateOrigin:          "1234567890",
+		ImmediateOriginName:      "Payment API",
+		CompanyName:              "Payment API",
+		CompanyId:                "1234567890",
+		EntryClass:               "PPD",
+		OriginatingDFI:           "02100002",
+	},
 }
 
 var TestConfig = &ConfigProperties{
-	MongoURL:   "mongodb://mongodb:27017",
-	Database:   "paymentsTest",
-	Collection: "payments",
-	Port:       "8080",
+	MongoURL:         "mongodb://mongodb:27017/?replicaSet=rs0",
+	Database:         "paymentsTest",
+	Collection:       "payments",
+	OutboxCollection: "outbox",
+	RelayInterval:    time.Second,
+	RelayBatchSize:   100,
+	EventStream:      ChangeStream,
+	PollInterval:     time.Second,
+	SchemaVersions:   []string{"v1"},
+	Port:             "8080",
+
+	ReconciliationCollection: "reconciliations",
+	StandingOrderCollection:  "standingOrders",
+
+	FxRateCollection:  "fxRates",
+	FxQuoteCollection: "fxQuotes",
+	QuoteTTL:          5 * time.Minute,
+
+	LedgerCollection:      "ledgerEntries",
+	AccountCollection:     "accounts",
+	BeneficiaryCollection: "beneficiaries",
+	CounterCollection:     "organisationCounters",
+
+	SchedulerInterval:      time.Minute,
+	SchedulerLeaseDuration: time.Minute,
+	SchedulerBatchSize:     100,
+
+	CalendarDirectory: "calendar/holidays",
+
+	OrganisationSchemaDirectory: "testdata/organisation_schemas",
+	SchemaDirectory:             "testdata/schemas",
+	CutOffs: map[string]time.Duration{
+		"BACS":  21 * time.Hour,
+		"CHAPS": 16 * time.Hour,
+		"SEPA":  15 * time.Hour,
+		"SWIFT": 14 * time.Hour,
+	},
+	ContinuousSchemes: []string{"FPS"},
+
+	DuplicateWindow: 24 * time.Hour,
+	DuplicatePolicy: services.DuplicateFlag,
+	OrganisationDuplicatePolicies: map[string]string{
+		"test-reject-duplicates": services.DuplicateReject,
+	},
+	OrganisationLimits: map[string]*types.Limits{
+		"test-limits":         {MaxAmount: 100, DailyAmount: 150, HourlyCount: 3},
+		"test-monthly-limits": {MonthlyAmount: 100},
+	},
+	OrganisationApprovalPolicies: map[string]*services.ApprovalPolicy{
+		"test-approvals": {Threshold: 1000, RequiredApprovals: 2},
+	},
+	OrganisationPricing: map[string]services.Pricing{
+		"test-fees": {
+			"":      {Flat: 0.5},
+			"SWIFT": {Flat: 5, Percentage: 0.1, Min: 10, Max: 50, BearerCode: types.BearerDebtor},
+			"BACS": {Tiers: []*services.FeeTier{
+				{UpTo: 1000, Flat: 0.2},
+				{Percentage: 0.05},
+			}},
+		},
+	},
+
+	InitiatingPartyName: "Payment API",
+	Standard18: &batchfile.Standard18Config{
+		ServiceUserNumber: "123456",
+		ServiceUserName:   "Payment API",
+	},
+	Nacha: &batchfile.NachaConfig{
+		ImmediateDestination:     "021000021",
+		ImmediateDestinationName: "Receiving Bank",
+		ImmediateOrigin:          "1234567890",
+		ImmediateOriginName:      "Payment API",
+		CompanyName:              "Payment API",
+		CompanyId:                "1234567890",
+		EntryClass:               "PPD",
+		OriginatingDFI:           "02100002",
+	},
 }
@@ -0,0 +1,41 @@
+package events
+
+import (
+	"log"
+
+	"github.com/brunovale91/payment-api/types"
+)
+
+type Publisher interface {
+
+	// Publish event, returning an error if it could not be delivered
+	Publish(*types.PaymentEvent) error
+}
+
+// Publisher that only logs events, used when no broker is configured
+type LogPublisher struct{}
+
+func NewLogPublisher() Publisher {
+	return LogPublisher{}
+}
+
+func (p LogPublisher) Publish(event *types.PaymentEvent) error {
+	log.Printf("Event %s %s for payment %s", event.Id, event.Type, event.PaymentId)
+	return nil
+}
+
+// In-process publisher that sends events to a channel, used by tests
+type ChannelPublisher struct {
+	Events chan *types.PaymentEvent
+}
+
+func NewChannelPublisher(size int) ChannelPublisher {
+	return ChannelPublisher{
+		Events: make(chan *types.PaymentEvent, size),
+	}
+}
+
+func (p ChannelPublisher) Publish(event *types.PaymentEvent) error {
+	p.Events <- event
+	return nil
+}
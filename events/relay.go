@@ -0,0 +1,85 @@
+package events
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/brunovale91/payment-api/store"
+)
+
+type RelayConfig struct {
+	Interval  time.Duration
+	BatchSize int64
+}
+
+type Relay interface {
+
+	// Start relaying outbox events in the background
+	Start()
+
+	// Stop the background relay, later calls do nothing
+	Stop()
+
+	// Publish pending outbox events in order and return how many were published
+	RelayPending() (int, error)
+}
+
+type RelayImpl struct {
+	config    *RelayConfig
+	outbox    store.OutboxStore
+	publisher Publisher
+	stop      chan struct{}
+	stopOnce  *sync.Once
+}
+
+func NewRelay(config *RelayConfig, outbox store.OutboxStore, publisher Publisher) Relay {
+	return RelayImpl{
+		config:    config,
+		outbox:    outbox,
+		publisher: publisher,
+		stop:      make(chan struct{}),
+		stopOnce:  &sync.Once{},
+	}
+}
+
+func (r RelayImpl) Start() {
+	go func() {
+		ticker := time.NewTicker(r.config.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				if _, err := r.RelayPending(); err != nil {
+					log.Printf("Error relaying events: %s", err.Error())
+				}
+			}
+		}
+	}()
+}
+
+func (r RelayImpl) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+}
+
+// A failed publish stops the batch so later events are never published
+// ahead of an earlier one; it is retried on the next run.
+func (r RelayImpl) RelayPending() (int, error) {
+	events, err := r.outbox.GetPendingEvents(r.config.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	for i, event := range events {
+		if err := r.publisher.Publish(event); err != nil {
+			return i, err
+		}
+		if err := r.outbox.MarkEventPublished(event.Id); err != nil {
+			return i, err
+		}
+	}
+	return len(events), nil
+}
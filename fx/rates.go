@@ -0,0 +1,67 @@
+package fx
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brunovale91/payment-api/types"
+)
+
+// Columns of a rate file, an optional first line may name them
+var rateColumns = []string{"base_currency", "quote_currency", "rate", "effective_at"}
+
+// Rate file could not be read
+type FormatError struct {
+	Message string
+}
+
+func (e FormatError) Error() string {
+	return e.Message
+}
+
+// Parse a CSV rate file with a base currency, quote currency, rate and RFC
+// 3339 effective time per line. Rates without an effective time are left for
+// the caller to date.
+func ParseRates(r io.Reader) ([]*types.FxRate, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	rates := make([]*types.FxRate, 0)
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, FormatError{Message: err.Error()}
+		}
+		if line == 1 && strings.EqualFold(record[0], rateColumns[0]) {
+			continue
+		}
+		if len(record) < 3 || len(record) > len(rateColumns) {
+			return nil, FormatError{Message: fmt.Sprintf("line %d: expected columns %s", line, strings.Join(rateColumns, ","))}
+		}
+		rate, err := strconv.ParseFloat(record[2], 64)
+		if err != nil {
+			return nil, FormatError{Message: fmt.Sprintf("line %d: invalid rate %q", line, record[2])}
+		}
+		fxRate := &types.FxRate{
+			BaseCurrency:  strings.ToUpper(record[0]),
+			QuoteCurrency: strings.ToUpper(record[1]),
+			Rate:          rate,
+		}
+		if len(record) > 3 && record[3] != "" {
+			effectiveAt, err := time.Parse(time.RFC3339, record[3])
+			if err != nil {
+				return nil, FormatError{Message: fmt.Sprintf("line %d: invalid effective time %q", line, record[3])}
+			}
+			fxRate.EffectiveAt = effectiveAt.UTC()
+		}
+		rates = append(rates, fxRate)
+	}
+	return rates, nil
+}
@@ -0,0 +1,131 @@
+package iso20022
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+
+	"github.com/brunovale91/payment-api/types"
+)
+
+// BankToCustomerStatement document, elements are matched by local name so
+// camt.053.001.02 to camt.053.001.08 statements can be read
+type Camt053Document struct {
+	XMLName    xml.Name           `xml:"Document"`
+	Statements []*StatementRecord `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type StatementRecord struct {
+	Id      string            `xml:"Id"`
+	Account *StatementAccount `xml:"Acct"`
+	Entries []*EntryRecord    `xml:"Ntry"`
+}
+
+// Account a statement is for, its servicer BIC is BIC up to camt.053.001.04
+// and BICFI from camt.053.001.05
+type StatementAccount struct {
+	Id    *AccountId `xml:"Id"`
+	BIC   string     `xml:"Svcr>FinInstnId>BIC"`
+	BICFI string     `xml:"Svcr>FinInstnId>BICFI"`
+}
+
+type EntryRecord struct {
+	Reference    string               `xml:"NtryRef"`
+	Amount       *InstructedAmount    `xml:"Amt"`
+	CreditDebit  string               `xml:"CdtDbtInd"`
+	BookingDate  string               `xml:"BookgDt>Dt"`
+	Transactions []*TransactionRecord `xml:"NtryDtls>TxDtls"`
+}
+
+type TransactionRecord struct {
+	EndToEndId      string            `xml:"Refs>EndToEndId"`
+	Amount          *InstructedAmount `xml:"Amt"`
+	DebtorName      string            `xml:"RltdPties>Dbtr>Nm"`
+	DebtorPartyName string            `xml:"RltdPties>Dbtr>Pty>Nm"`
+	DebtorAccount   *Account          `xml:"RltdPties>DbtrAcct"`
+	CreditorName    string            `xml:"RltdPties>Cdtr>Nm"`
+	CreditorParty   string            `xml:"RltdPties>Cdtr>Pty>Nm"`
+	CreditorAccount *Account          `xml:"RltdPties>CdtrAcct"`
+}
+
+// Read statement transactions, each flattened with the entry it was booked in
+func ParseCamt053(data []byte) ([]*types.StatementEntry, error) {
+	var document Camt053Document
+	if err := xml.Unmarshal(data, &document); err != nil {
+		return nil, FormatError{Message: "Invalid camt.053 document: " + err.Error()}
+	}
+	entries := make([]*types.StatementEntry, 0)
+	for _, statement := range document.Statements {
+		for _, entry := range statement.Entries {
+			transactions := entry.Transactions
+			if len(transactions) == 0 {
+				transactions = []*TransactionRecord{{}}
+			}
+			for _, transaction := range transactions {
+				entries = append(entries, toStatementEntry(statement, entry, transaction))
+			}
+		}
+	}
+	return entries, nil
+}
+
+func toStatementEntry(statement *StatementRecord, entry *EntryRecord, transaction *TransactionRecord) *types.StatementEntry {
+	amount := transaction.Amount
+	if amount == nil {
+		amount = entry.Amount
+	}
+	result := &types.StatementEntry{
+		StatementId:       statement.Id,
+		EntryReference:    entry.Reference,
+		EndToEndReference: strings.TrimSpace(transaction.EndToEndId),
+		CreditDebit:       entry.CreditDebit,
+		BookingDate:       entry.BookingDate,
+	}
+	if statement.Account != nil {
+		result.Account = accountId(&Account{Id: statement.Account.Id})
+		result.AccountBankId = firstNonEmpty(statement.Account.BICFI, statement.Account.BIC)
+	}
+	if amount != nil {
+		result.Currency = amount.Currency
+		result.Amount = parseAmount(amount.Value)
+	}
+	// The counterparty of money received is the debtor, of money sent the creditor
+	if entry.CreditDebit == "CRDT" {
+		result.CounterpartyName = firstNonEmpty(transaction.DebtorName, transaction.DebtorPartyName)
+		result.CounterpartyAccount = accountId(transaction.DebtorAccount)
+	} else {
+		result.CounterpartyName = firstNonEmpty(transaction.CreditorName, transaction.CreditorParty)
+		result.CounterpartyAccount = accountId(transaction.CreditorAccount)
+	}
+	return result
+}
+
+func parseAmount(value string) float64 {
+	amount, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0
+	}
+	return amount
+}
+
+func accountId(account *Account) string {
+	if account == nil || account.Id == nil {
+		return ""
+	}
+	if account.Id.IBAN != "" {
+		return account.Id.IBAN
+	}
+	if account.Id.Other != nil {
+		return account.Id.Other.Id
+	}
+	return ""
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,353 @@
+package iso20022
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brunovale91/payment-api/types"
+	"github.com/google/uuid"
+)
+
+const Pain001Namespace = "urn:iso:std:iso:20022:tech:xsd:pain.001.001.09"
+
+// Max35Text identifiers, e.g. MsgId and PmtInfId
+const maxIdLength = 35
+
+type Pain001Config struct {
+	InitiatingParty string
+}
+
+// Payments cannot be expressed in the requested format, or a document could
+// not be read
+type FormatError struct {
+	Message string
+}
+
+func (e FormatError) Error() string {
+	return e.Message
+}
+
+// CustomerCreditTransferInitiationV09 document, only the elements this api
+// can fill are modelled
+type Pain001Document struct {
+	XMLName  xml.Name                  `xml:"Document"`
+	Xmlns    string                    `xml:"xmlns,attr"`
+	Initiate *CustomerCreditTransferV9 `xml:"CstmrCdtTrfInitn"`
+}
+
+type CustomerCreditTransferV9 struct {
+	GroupHeader        *GroupHeader          `xml:"GrpHdr"`
+	PaymentInformation []*PaymentInformation `xml:"PmtInf"`
+}
+
+type GroupHeader struct {
+	MessageId            string     `xml:"MsgId"`
+	CreationDateTime     string     `xml:"CreDtTm"`
+	NumberOfTransactions int        `xml:"NbOfTxs"`
+	ControlSum           string     `xml:"CtrlSum"`
+	InitiatingParty      *PartyName `xml:"InitgPty"`
+}
+
+type PaymentInformation struct {
+	PaymentInformationId  string                       `xml:"PmtInfId"`
+	PaymentMethod         string                       `xml:"PmtMtd"`
+	NumberOfTransactions  int                          `xml:"NbOfTxs"`
+	ControlSum            string                       `xml:"CtrlSum"`
+	RequestedExecution    *DateChoice                  `xml:"ReqdExctnDt"`
+	Debtor                *Party                       `xml:"Dbtr"`
+	DebtorAccount         *Account                     `xml:"DbtrAcct"`
+	DebtorAgent           *Agent                       `xml:"DbtrAgt"`
+	CreditTransferDetails []*CreditTransferTransaction `xml:"CdtTrfTxInf"`
+}
+
+type DateChoice struct {
+	Date string `xml:"Dt"`
+}
+
+type CreditTransferTransaction struct {
+	PaymentId       *PaymentId           `xml:"PmtId"`
+	Amount          *Amount              `xml:"Amt"`
+	CreditorAgent   *Agent               `xml:"CdtrAgt"`
+	Creditor        *Party               `xml:"Cdtr"`
+	CreditorAccount *Account             `xml:"CdtrAcct"`
+	Purpose         *Purpose             `xml:"Purp,omitempty"`
+	Remittance      *RemittanceInfoField `xml:"RmtInf,omitempty"`
+}
+
+type PaymentId struct {
+	InstructionId string `xml:"InstrId"`
+	EndToEndId    string `xml:"EndToEndId"`
+}
+
+type Amount struct {
+	Instructed *InstructedAmount `xml:"InstdAmt"`
+}
+
+type InstructedAmount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type PartyName struct {
+	Name string `xml:"Nm"`
+}
+
+type Party struct {
+	Name    string         `xml:"Nm"`
+	Address *PostalAddress `xml:"PstlAdr,omitempty"`
+}
+
+type PostalAddress struct {
+	Country     string   `xml:"Ctry,omitempty"`
+	AddressLine []string `xml:"AdrLine"`
+}
+
+type Account struct {
+	Id   *AccountId `xml:"Id"`
+	Name string     `xml:"Nm,omitempty"`
+}
+
+type AccountId struct {
+	IBAN  string          `xml:"IBAN,omitempty"`
+	Other *GenericAccount `xml:"Othr,omitempty"`
+}
+
+type GenericAccount struct {
+	Id string `xml:"Id"`
+}
+
+type Agent struct {
+	Institution *FinancialInstitution `xml:"FinInstnId"`
+}
+
+type FinancialInstitution struct {
+	BICFI         string            `xml:"BICFI,omitempty"`
+	ClearingId    *ClearingMemberId `xml:"ClrSysMmbId,omitempty"`
+	PostalAddress *PostalAddress    `xml:"PstlAdr,omitempty"`
+	Other         *GenericAccount   `xml:"Othr,omitempty"`
+}
+
+type ClearingMemberId struct {
+	System   *ClearingSystemCode `xml:"ClrSysId"`
+	MemberId string              `xml:"MmbId"`
+}
+
+type ClearingSystemCode struct {
+	Code string `xml:"Cd"`
+}
+
+type Purpose struct {
+	Proprietary string `xml:"Prtry"`
+}
+
+type RemittanceInfoField struct {
+	Unstructured string `xml:"Ustrd"`
+}
+
+// Clearing system codes of bank id codes identifying a clearing member
+var clearingSystems = map[string]string{
+	"GBDSC": "GBDSC",
+	"USABA": "USABA",
+}
+
+// Build a pain.001 document with one PmtInf block per debtor account and
+// execution date. Every payment needs a currency and both parties.
+func NewPain001(config *Pain001Config, payments []*types.Payment, now time.Time) (*Pain001Document, error) {
+	if len(payments) == 0 {
+		return nil, FormatError{Message: "No payments to export"}
+	}
+	for _, payment := range payments {
+		if err := checkExportable(payment); err != nil {
+			return nil, err
+		}
+	}
+
+	groups := make(map[string][]*types.Payment)
+	keys := make([]string, 0)
+	for _, payment := range payments {
+		key := debtorKey(payment, now)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], payment)
+	}
+	sort.Strings(keys)
+
+	transfer := &CustomerCreditTransferV9{
+		GroupHeader: &GroupHeader{
+			MessageId:            newMessageId(),
+			CreationDateTime:     now.UTC().Format("2006-01-02T15:04:05"),
+			NumberOfTransactions: len(payments),
+			ControlSum:           formatAmount(controlSum(payments)),
+			InitiatingParty:      &PartyName{Name: truncate(config.InitiatingParty, 140)},
+		},
+	}
+	for i, key := range keys {
+		transfer.PaymentInformation = append(transfer.PaymentInformation,
+			newPaymentInformation(transfer.GroupHeader.MessageId, i+1, groups[key], now))
+	}
+	return &Pain001Document{
+		Xmlns:    Pain001Namespace,
+		Initiate: transfer,
+	}, nil
+}
+
+// Encode document with an XML declaration
+func MarshalPain001(document *Pain001Document) ([]byte, error) {
+	body, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+func checkExportable(payment *types.Payment) error {
+	attributes := payment.Attributes
+	if attributes == nil || attributes.DebtorParty == nil || attributes.BeneficiaryParty == nil {
+		return FormatError{Message: fmt.Sprintf("Payment %s has no debtor or beneficiary", payment.Id)}
+	}
+	if attributes.Currency == "" {
+		return FormatError{Message: fmt.Sprintf("Payment %s has no currency", payment.Id)}
+	}
+	return nil
+}
+
+func newPaymentInformation(messageId string, index int, payments []*types.Payment, now time.Time) *PaymentInformation {
+	debtor := payments[0].Attributes.DebtorParty
+	information := &PaymentInformation{
+		PaymentInformationId: fmt.Sprintf("%s-%d", messageId[:24], index),
+		PaymentMethod:        "TRF",
+		NumberOfTransactions: len(payments),
+		ControlSum:           formatAmount(controlSum(payments)),
+		RequestedExecution:   &DateChoice{Date: executionDate(payments[0], now)},
+		Debtor:               toParty(debtor),
+		DebtorAccount:        toAccount(debtor),
+		DebtorAgent:          toAgent(debtor),
+	}
+	for _, payment := range payments {
+		information.CreditTransferDetails = append(information.CreditTransferDetails, toTransaction(payment))
+	}
+	return information
+}
+
+func toTransaction(payment *types.Payment) *CreditTransferTransaction {
+	attributes := payment.Attributes
+	transaction := &CreditTransferTransaction{
+		PaymentId: &PaymentId{
+			InstructionId: truncate(payment.Id, maxIdLength),
+			EndToEndId:    truncate(attributes.EndToEndReference, maxIdLength),
+		},
+		Amount: &Amount{
+			Instructed: &InstructedAmount{
+				Currency: attributes.Currency,
+				Value:    formatAmount(toMinorUnits(attributes.Amount)),
+			},
+		},
+		CreditorAgent:   toAgent(attributes.BeneficiaryParty),
+		Creditor:        toParty(attributes.BeneficiaryParty),
+		CreditorAccount: toAccount(attributes.BeneficiaryParty),
+	}
+	if attributes.PaymentPurpose != "" {
+		transaction.Purpose = &Purpose{Proprietary: truncate(attributes.PaymentPurpose, maxIdLength)}
+	}
+	if attributes.Reference != "" {
+		transaction.Remittance = &RemittanceInfoField{Unstructured: truncate(attributes.Reference, 140)}
+	}
+	return transaction
+}
+
+func toParty(party *types.PaymentParty) *Party {
+	result := &Party{Name: truncate(party.Name, 140)}
+	if party.Country != "" || len(party.Address) > 0 {
+		result.Address = &PostalAddress{
+			Country:     party.Country,
+			AddressLine: party.Address,
+		}
+	}
+	return result
+}
+
+// Account from the party account number, falling back to the bank id when it
+// is an IBAN
+func toAccount(party *types.PaymentParty) *Account {
+	account := &Account{Id: &AccountId{}, Name: truncate(party.AccountName, 70)}
+	switch {
+	case party.AccountNumberCode == "IBAN":
+		account.Id.IBAN = party.AccountNumber
+	case party.AccountNumber != "":
+		account.Id.Other = &GenericAccount{Id: party.AccountNumber}
+	case party.BankIdCode == "IBAN":
+		account.Id.IBAN = party.BankId
+	default:
+		account.Id.Other = &GenericAccount{Id: party.BankId}
+	}
+	return account
+}
+
+func toAgent(party *types.PaymentParty) *Agent {
+	institution := &FinancialInstitution{}
+	if party.BankIdCode == "BIC" || party.BankIdCode == "SWBIC" {
+		institution.BICFI = party.BankId
+	} else if system, ok := clearingSystems[party.BankIdCode]; ok {
+		institution.ClearingId = &ClearingMemberId{
+			System:   &ClearingSystemCode{Code: system},
+			MemberId: party.BankId,
+		}
+	} else if party.BankIdCode == "IBAN" {
+		// An IBAN identifies the account, the agent is derived from it
+		institution.Other = &GenericAccount{Id: "NOTPROVIDED"}
+	} else {
+		institution.Other = &GenericAccount{Id: party.BankId}
+	}
+	if len(party.BankAddress) > 0 {
+		institution.PostalAddress = &PostalAddress{AddressLine: party.BankAddress}
+	}
+	return &Agent{Institution: institution}
+}
+
+func debtorKey(payment *types.Payment, now time.Time) string {
+	debtor := payment.Attributes.DebtorParty
+	return strings.Join([]string{
+		debtor.BankIdCode, debtor.BankId, debtor.AccountNumber, debtor.Name, executionDate(payment, now),
+	}, "|")
+}
+
+func executionDate(payment *types.Payment, now time.Time) string {
+	if payment.Attributes.ProcessingDate != "" {
+		return payment.Attributes.ProcessingDate
+	}
+	return now.UTC().Format("2006-01-02")
+}
+
+// Control sums are added in minor units to avoid float rounding
+func controlSum(payments []*types.Payment) int64 {
+	var sum int64
+	for _, payment := range payments {
+		sum += toMinorUnits(payment.Attributes.Amount)
+	}
+	return sum
+}
+
+func toMinorUnits(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+func formatAmount(minorUnits int64) string {
+	return strconv.FormatFloat(float64(minorUnits)/100, 'f', 2, 64)
+}
+
+func newMessageId() string {
+	return strings.Replace(uuid.New().String(), "-", "", -1)
+}
+
+func truncate(value string, length int) string {
+	if len(value) > length {
+		return value[:length]
+	}
+	return value
+}
@@ -0,0 +1,39 @@
+package iso20022
+
+// ExternalReturnReason1Code values a refund can be given, by code
+var ReturnReasons = map[string]string{
+	"AC01": "Incorrect account number",
+	"AC04": "Closed account number",
+	"AC06": "Blocked account",
+	"AG01": "Transaction forbidden",
+	"AG02": "Invalid bank operation code",
+	"AM04": "Insufficient funds",
+	"AM05": "Duplication",
+	"BE04": "Missing creditor address",
+	"CUST": "Requested by customer",
+	"DUPL": "Duplicate payment",
+	"FOCR": "Following cancellation request",
+	"FR01": "Fraud",
+	"MD01": "No mandate",
+	"MD06": "Refund request by end customer",
+	"MS02": "Not specified reason customer generated",
+	"MS03": "Not specified reason agent generated",
+	"RC01": "Bank identifier incorrect",
+	"RR04": "Regulatory reason",
+	"TECH": "Technical problem",
+}
+
+// ExternalCancellationReason1Code values a cancellation can be given, by code
+var CancellationReasons = map[string]string{
+	"AC03": "Invalid creditor account number",
+	"AGNT": "Incorrect agent",
+	"AM09": "Wrong amount",
+	"COVR": "Cover cancelled or returned",
+	"CURR": "Incorrect currency",
+	"CUST": "Requested by customer",
+	"CUTA": "Cancel upon unable to apply",
+	"DUPL": "Duplicate payment",
+	"FRAD": "Fraudulent origin",
+	"TECH": "Technical problem",
+	"UPAY": "Undue payment",
+}
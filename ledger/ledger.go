@@ -0,0 +1,192 @@
+package ledger
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/brunovale91/payment-api/types"
+	"github.com/google/uuid"
+)
+
+// Accounts of the charges of the sending institution and of currency
+// conversion, one balance per currency
+const (
+	FeeAccount = "fees"
+	FxAccount  = "fx"
+)
+
+// ISO 4217 code for transactions without a currency, payments without one
+// are posted in it
+const NoCurrency = "XXX"
+
+// Net effect of a payment on an account in a currency, credits positive
+type Leg struct {
+	AccountId string
+	Currency  string
+	Amount    float64
+}
+
+// Entries would not sum to zero in a currency
+type UnbalancedError struct {
+	Currency string
+	Total    float64
+}
+
+func (e UnbalancedError) Error() string {
+	return fmt.Sprintf("Ledger entries in %s sum to %s instead of zero", e.Currency, strconv.FormatFloat(e.Total, 'f', -1, 64))
+}
+
+// Account of a party of an organisation: the saved account it was resolved
+// from, which keeps its entries when its details change, or else the
+// organisation id, bank id and account number when it has one
+func AccountId(organisationId string, savedAccountId string, party *types.PaymentParty) string {
+	if savedAccountId != "" {
+		return savedAccountId
+	}
+	if party == nil || party.BankId == "" {
+		return ""
+	}
+	if party.AccountNumber == "" {
+		return organisationId + ":" + party.BankId
+	}
+	return organisationId + ":" + party.BankId + ":" + party.AccountNumber
+}
+
+// Legs a payment has in its state. Accepted payments move their amount from
+// the debtor to the beneficiary account, through the fx account when settled
+// in another currency, and their charges to the fee account. Payments pending
+// approval, scheduled, cancelled or deleted have none.
+func Legs(payment *types.Payment) []*Leg {
+	if payment == nil || payment.Attributes == nil || (payment.Status != "" && payment.Status != types.PaymentAccepted) {
+		return nil
+	}
+	attributes := payment.Attributes
+	debtor := AccountId(payment.OrganisationId, attributes.DebtorAccountId, attributes.DebtorParty)
+	beneficiary := AccountId(payment.OrganisationId, attributes.BeneficiaryAccountId, attributes.BeneficiaryParty)
+	if debtor == "" || beneficiary == "" {
+		return nil
+	}
+	currency := currencyOf(attributes.Currency)
+	legs := []*Leg{{AccountId: debtor, Currency: currency, Amount: -attributes.Amount}}
+	if attributes.SettlementCurrency != "" && attributes.SettlementCurrency != currency {
+		legs = append(legs,
+			&Leg{AccountId: FxAccount, Currency: currency, Amount: attributes.Amount},
+			&Leg{AccountId: FxAccount, Currency: attributes.SettlementCurrency, Amount: -attributes.SettlementAmount},
+			&Leg{AccountId: beneficiary, Currency: attributes.SettlementCurrency, Amount: attributes.SettlementAmount})
+	} else {
+		legs = append(legs, &Leg{AccountId: beneficiary, Currency: currency, Amount: attributes.Amount})
+	}
+	if charges := attributes.ChargesInformation; charges != nil {
+		for _, charge := range charges.SenderCharges {
+			legs = append(legs,
+				&Leg{AccountId: debtor, Currency: currencyOf(charge.Currency), Amount: -charge.Amount},
+				&Leg{AccountId: FeeAccount, Currency: currencyOf(charge.Currency), Amount: charge.Amount})
+		}
+		if charges.ReceiverChargesAmount > 0 {
+			legs = append(legs,
+				&Leg{AccountId: beneficiary, Currency: currencyOf(charges.ReceiverChargesCurrency), Amount: -charges.ReceiverChargesAmount},
+				&Leg{AccountId: FeeAccount, Currency: currencyOf(charges.ReceiverChargesCurrency), Amount: charges.ReceiverChargesAmount})
+		}
+	}
+	return legs
+}
+
+// Entries bringing the posted entries of a payment to the legs of its state,
+// nil when the payment is deleted, reversing everything posted. Nothing is
+// returned when the posted entries already match.
+func Post(paymentId string, payment *types.Payment, eventType string, posted []*types.LedgerEntry, now time.Time) ([]*types.LedgerEntry, error) {
+	net := make(map[Leg]int64)
+	for _, leg := range Legs(payment) {
+		net[Leg{AccountId: leg.AccountId, Currency: leg.Currency}] += cents(leg.Amount)
+	}
+	for _, entry := range posted {
+		net[Leg{AccountId: entry.AccountId, Currency: entry.Currency}] -= cents(signed(entry))
+	}
+
+	keys := make([]Leg, 0, len(net))
+	for key, amount := range net {
+		if amount != 0 {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Currency != keys[j].Currency {
+			return keys[i].Currency < keys[j].Currency
+		}
+		return keys[i].AccountId < keys[j].AccountId
+	})
+
+	transactionId, err := uuid.NewUUID()
+	if err != nil {
+		return nil, err
+	}
+	totals := make(map[string]float64)
+	entries := make([]*types.LedgerEntry, 0, len(keys))
+	for _, key := range keys {
+		id, err := uuid.NewUUID()
+		if err != nil {
+			return nil, err
+		}
+		entry := &types.LedgerEntry{
+			Id:            id.String(),
+			TransactionId: transactionId.String(),
+			PaymentId:     paymentId,
+			EventType:     eventType,
+			AccountId:     key.AccountId,
+			Currency:      key.Currency,
+			Direction:     types.Credit,
+			Amount:        float64(net[key]) / 100,
+			CreatedAt:     now,
+		}
+		if entry.Amount < 0 {
+			entry.Direction = types.Debit
+			entry.Amount = -entry.Amount
+		}
+		totals[entry.Currency] += signed(entry)
+		entries = append(entries, entry)
+	}
+	if err := CheckBalanced(totals); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Check that totals of entries by currency are zero, to the cent
+func CheckBalanced(totals map[string]float64) error {
+	currencies := make([]string, 0, len(totals))
+	for currency := range totals {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	for _, currency := range currencies {
+		if cents(totals[currency]) != 0 {
+			return UnbalancedError{Currency: currency, Total: math.Round(totals[currency]*100) / 100}
+		}
+	}
+	return nil
+}
+
+// Amount of an entry, credits positive
+func signed(entry *types.LedgerEntry) float64 {
+	if entry.Direction == types.Debit {
+		return -entry.Amount
+	}
+	return entry.Amount
+}
+
+func cents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+func currencyOf(currency string) string {
+	if currency == "" {
+		return NoCurrency
+	}
+	return currency
+}
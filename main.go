@@ -3,30 +3,273 @@ package main
 import (
 	"log"
 	"net/http"
+	"os"
+	"sync"
 
 	"github.com/brunovale91/payment-api/api"
+	"github.com/brunovale91/payment-api/calendar"
+	"github.com/brunovale91/payment-api/events"
+	"github.com/brunovale91/payment-api/iso20022"
+	"github.com/brunovale91/payment-api/scheduler"
 	"github.com/brunovale91/payment-api/services"
 	"github.com/brunovale91/payment-api/store"
+	"github.com/brunovale91/payment-api/swift"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		if err := runCommand(Config, os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 	api := getPaymentApi(Config)
 	if api != nil {
+		getEventRelay(Config, events.NewLogPublisher()).Start()
+		getScheduler(Config).Start()
 		log.Fatal(http.ListenAndServe(":"+Config.Port, api))
 	}
 }
 
 func getPaymentApi(config *ConfigProperties) http.Handler {
-	paymentStore, err := store.NewPaymentStore(&store.PaymentStoreConfig{
-		URL:        config.MongoURL,
-		Database:   config.Database,
-		Collection: config.Collection,
-	})
+	app := getApplication(config)
+	if config.SchemaDirectory != "" {
+		if err := api.LoadSchemaVersions(config.SchemaDirectory); err != nil {
+			log.Fatalf("Failed to load schema versions: %s", err.Error())
+			return nil
+		}
+	}
+	organisationSchemas, err := api.LoadOrganisationSchemas(config.OrganisationSchemaDirectory)
 	if err != nil {
-		log.Fatal("Failed to initialize data store")
+		log.Fatalf("Failed to load organisation schemas: %s", err.Error())
 		return nil
 	}
-	paymentService := services.NewPaymentService(paymentStore)
-	router := api.NewApiRouter(paymentService)
+	validators := make([]api.PaymentValidator, 0, len(config.SchemaVersions))
+	for _, version := range config.SchemaVersions {
+		validator, err := api.NewPaymentValidator(version, organisationSchemas)
+		if err != nil {
+			log.Fatalf("Failed to compile %s schemas", version)
+			return nil
+		}
+		validators = append(validators, validator)
+	}
+	router := api.NewApiRouter(&api.Services{
+		Payments:        app.payments,
+		Events:          services.NewEventService(app.eventStream),
+		Export:          app.export,
+		Reconciliations: app.reconciliations,
+		StandingOrders:  app.standingOrders,
+		Calendars:       app.calendars,
+		Fx:              app.fx,
+		Ledger:          app.ledger,
+		Accounts:        app.accounts,
+		Beneficiaries:   app.beneficiaries,
+	}, validators...)
 	return router
 }
+
+// Stores and services of a configuration, created once over one database
+// client and shared by the api, the event relay, the scheduler and commands
+type application struct {
+	paymentStore    store.PaymentStore
+	outboxStore     store.OutboxStore
+	eventStream     store.EventStream
+	payments        services.PaymentService
+	standingOrders  services.StandingOrderService
+	reconciliations services.ReconciliationService
+	export          services.ExportService
+	calendars       services.CalendarService
+	fx              services.FxService
+	ledger          services.LedgerService
+	accounts        services.AccountService
+	beneficiaries   services.BeneficiaryService
+}
+
+var applications = make(map[*ConfigProperties]*application)
+var applicationsMutex sync.Mutex
+
+func getApplication(config *ConfigProperties) *application {
+	applicationsMutex.Lock()
+	defer applicationsMutex.Unlock()
+	if app, ok := applications[config]; ok {
+		return app
+	}
+	storeConfig := getStoreConfig(config)
+	client, err := store.NewClient(storeConfig)
+	if err != nil {
+		log.Fatal("Failed to connect to data store")
+		return nil
+	}
+	storeConfig.Client = client
+
+	paymentStore, err := store.NewPaymentStore(storeConfig)
+	if err != nil {
+		log.Fatal("Failed to initialize data store")
+		return nil
+	}
+	outboxStore, err := store.NewOutboxStore(storeConfig)
+	if err != nil {
+		log.Fatal("Failed to initialize outbox store")
+		return nil
+	}
+	eventStream, err := getEventStream(config, storeConfig, outboxStore)
+	if err != nil {
+		log.Fatal("Failed to initialize event stream")
+		return nil
+	}
+	reconciliationStore, err := store.NewReconciliationStore(storeConfig)
+	if err != nil {
+		log.Fatal("Failed to initialize reconciliation store")
+		return nil
+	}
+	standingOrderStore, err := store.NewStandingOrderStore(storeConfig)
+	if err != nil {
+		log.Fatal("Failed to initialize standing order store")
+		return nil
+	}
+	fxStore, err := store.NewFxStore(storeConfig)
+	if err != nil {
+		log.Fatal("Failed to initialize fx store")
+		return nil
+	}
+	ledgerStore, err := store.NewLedgerStore(storeConfig)
+	if err != nil {
+		log.Fatal("Failed to initialize ledger store")
+		return nil
+	}
+	accountStore, err := store.NewAccountStore(storeConfig)
+	if err != nil {
+		log.Fatal("Failed to initialize account store")
+		return nil
+	}
+	beneficiaryStore, err := store.NewBeneficiaryStore(storeConfig)
+	if err != nil {
+		log.Fatal("Failed to initialize beneficiary store")
+		return nil
+	}
+
+	app := &application{
+		paymentStore:  paymentStore,
+		outboxStore:   outboxStore,
+		eventStream:   eventStream,
+		calendars:     getCalendarService(config),
+		fx:            services.NewFxService(fxStore, &services.FxConfig{QuoteTTL: config.QuoteTTL}),
+		ledger:        services.NewLedgerService(ledgerStore),
+		accounts:      services.NewAccountService(accountStore),
+		beneficiaries: services.NewBeneficiaryService(beneficiaryStore),
+	}
+	app.export = getExportService(config, paymentStore, app.calendars)
+	app.payments = getPaymentService(config, app)
+	app.reconciliations = services.NewReconciliationService(paymentStore, reconciliationStore)
+	app.standingOrders = services.NewStandingOrderService(standingOrderStore, app.payments, app.calendars, getClock(config))
+	applications[config] = app
+	return app
+}
+
+func getCalendarService(config *ConfigProperties) services.CalendarService {
+	calendars, err := calendar.LoadCalendars(config.CalendarDirectory)
+	if err != nil {
+		log.Fatalf("Failed to load calendars: %s", err.Error())
+		return nil
+	}
+	return services.NewCalendarService(&services.CalendarConfig{
+		Calendars:         calendars,
+		CutOffs:           config.CutOffs,
+		ContinuousSchemes: config.ContinuousSchemes,
+	})
+}
+
+func getFxService(config *ConfigProperties) services.FxService {
+	return getApplication(config).fx
+}
+
+func getLedgerService(config *ConfigProperties) services.LedgerService {
+	return getApplication(config).ledger
+}
+
+func getPaymentService(config *ConfigProperties, app *application) services.PaymentService {
+	return services.NewPaymentService(app.paymentStore, &services.PaymentConfig{
+		Duplicates: &services.DuplicateConfig{
+			Window:               config.DuplicateWindow,
+			DefaultPolicy:        config.DuplicatePolicy,
+			OrganisationPolicies: config.OrganisationDuplicatePolicies,
+		},
+		Limits: &services.LimitConfig{
+			DefaultLimits:      config.DefaultLimits,
+			OrganisationLimits: config.OrganisationLimits,
+		},
+		Approvals: &services.ApprovalConfig{
+			DefaultPolicy:        config.DefaultApprovalPolicy,
+			OrganisationPolicies: config.OrganisationApprovalPolicies,
+		},
+		Fees: &services.FeeConfig{
+			DefaultPricing:      config.DefaultPricing,
+			OrganisationPricing: config.OrganisationPricing,
+		},
+		Calendars:     app.calendars,
+		Fx:            app.fx,
+		Accounts:      app.accounts,
+		Beneficiaries: app.beneficiaries,
+	})
+}
+
+func getExportService(config *ConfigProperties, paymentStore store.PaymentStore, calendars services.CalendarService) services.ExportService {
+	return services.NewExportService(paymentStore, calendars, &services.ExportConfig{
+		Pain001: &iso20022.Pain001Config{
+			InitiatingParty: config.InitiatingPartyName,
+		},
+		MT103: &swift.MT103Config{
+			SenderBIC: config.SwiftSenderBIC,
+		},
+		Standard18: config.Standard18,
+		Nacha:      config.Nacha,
+	})
+}
+
+func getEventStream(config *ConfigProperties, storeConfig *store.PaymentStoreConfig, outboxStore store.OutboxStore) (store.EventStream, error) {
+	if config.EventStream == PollingStream {
+		return store.NewPollingStream(outboxStore, config.PollInterval, config.RelayBatchSize), nil
+	}
+	return store.NewChangeStream(storeConfig)
+}
+
+func getEventRelay(config *ConfigProperties, publisher events.Publisher) events.Relay {
+	return events.NewRelay(&events.RelayConfig{
+		Interval:  config.RelayInterval,
+		BatchSize: config.RelayBatchSize,
+	}, getApplication(config).outboxStore, publisher)
+}
+
+func getClock(config *ConfigProperties) services.Clock {
+	if config.Clock == nil {
+		return services.SystemClock{}
+	}
+	return config.Clock
+}
+
+func getScheduler(config *ConfigProperties) scheduler.Scheduler {
+	app := getApplication(config)
+	return scheduler.NewScheduler(&scheduler.SchedulerConfig{
+		Interval:      config.SchedulerInterval,
+		LeaseDuration: config.SchedulerLeaseDuration,
+		BatchSize:     config.SchedulerBatchSize,
+	}, app.paymentStore, app.standingOrders, getClock(config))
+}
+
+func getStoreConfig(config *ConfigProperties) *store.PaymentStoreConfig {
+	return &store.PaymentStoreConfig{
+		URL:              config.MongoURL,
+		Database:         config.Database,
+		Collection:       config.Collection,
+		OutboxCollection: config.OutboxCollection,
+
+		ReconciliationCollection: config.ReconciliationCollection,
+		StandingOrderCollection:  config.StandingOrderCollection,
+		FxRateCollection:         config.FxRateCollection,
+		FxQuoteCollection:        config.FxQuoteCollection,
+		LedgerCollection:         config.LedgerCollection,
+		AccountCollection:        config.AccountCollection,
+		BeneficiaryCollection:    config.BeneficiaryCollection,
+		CounterCollection:        config.CounterCollection,
+	}
+}
@@ -1,14 +1,31 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/brunovale91/payment-api/api"
+	"github.com/brunovale91/payment-api/calendar"
+	"github.com/brunovale91/payment-api/events"
+	"github.com/brunovale91/payment-api/iso20022"
 	"github.com/brunovale91/payment-api/types"
+	"github.com/go-chi/chi"
 )
 
 var validPayment = &types.Payment{
@@ -17,30 +34,61 @@ var validPayment = &types.Payment{
 	Attributes: &types.PaymentAttributes{
 		Amount: 3,
 		BeneficiaryParty: &types.PaymentParty{
-			BankId:     "id",
-			BankIdCode: "code",
+			BankId:     "403000",
+			BankIdCode: "GBDSC",
 			Name:       "name",
 		},
 		DebtorParty: &types.PaymentParty{
-			BankId:     "id2",
-			BankIdCode: "code2",
-			Name:       "name2",
+			BankId:            "GB29NWBK60161331926819",
+			BankIdCode:        "IBAN",
+			Name:              "name2",
+			AccountNumber:     "GB29NWBK60161331926819",
+			AccountNumberCode: "IBAN",
+			AccountName:       "account2",
+			Address:           []string{"1 High Street", "London"},
+			Country:           "GB",
 		},
 		EndToEndReference: "test1",
 	},
 }
 
+// Copy of validPayment with its attributes and parties changed by edit
+func newPayment(edit func(*types.PaymentAttributes)) *types.Payment {
+	payment := *validPayment
+	payment.Attributes = copyAttributes(validPayment.Attributes, edit)
+	return &payment
+}
+
+// Copy of validPaymentUpdate with its attributes and parties changed by edit
+func newPaymentUpdate(edit func(*types.PaymentAttributes)) *types.Payment {
+	update := *validPaymentUpdate
+	update.Attributes = copyAttributes(validPaymentUpdate.Attributes, edit)
+	return &update
+}
+
+func copyAttributes(attributes *types.PaymentAttributes, edit func(*types.PaymentAttributes)) *types.PaymentAttributes {
+	copied := *attributes
+	debtor := *attributes.DebtorParty
+	beneficiary := *attributes.BeneficiaryParty
+	copied.DebtorParty = &debtor
+	copied.BeneficiaryParty = &beneficiary
+	if edit != nil {
+		edit(&copied)
+	}
+	return &copied
+}
+
 var validPaymentUpdate = &types.Payment{
 	Attributes: &types.PaymentAttributes{
 		Amount: 5,
 		BeneficiaryParty: &types.PaymentParty{
-			BankId:     "id",
-			BankIdCode: "code",
+			BankId:     "403000",
+			BankIdCode: "GBDSC",
 			Name:       "name",
 		},
 		DebtorParty: &types.PaymentParty{
-			BankId:     "id2",
-			BankIdCode: "code2",
+			BankId:     "GB29NWBK60161331926819",
+			BankIdCode: "IBAN",
 			Name:       "name2",
 		},
 		EndToEndReference: "test1",
@@ -51,13 +99,13 @@ var invalidPaymentUpdate = &types.Payment{
 	Attributes: &types.PaymentAttributes{
 		Amount: 5,
 		BeneficiaryParty: &types.PaymentParty{
-			BankId:     "id",
-			BankIdCode: "code",
+			BankId:     "403000",
+			BankIdCode: "GBDSC",
 			Name:       "name",
 		},
 		DebtorParty: &types.PaymentParty{
-			BankId:     "id2",
-			BankIdCode: "code2",
+			BankId:     "GB29NWBK60161331926819",
+			BankIdCode: "IBAN",
 		},
 		EndToEndReference: "test1",
 	},
@@ -69,13 +117,13 @@ var invalidPaymentType = &types.Payment{
 	Attributes: &types.PaymentAttributes{
 		Amount: 3,
 		BeneficiaryParty: &types.PaymentParty{
-			BankId:     "id",
-			BankIdCode: "code",
+			BankId:     "403000",
+			BankIdCode: "GBDSC",
 			Name:       "name",
 		},
 		DebtorParty: &types.PaymentParty{
-			BankId:     "id2",
-			BankIdCode: "code2",
+			BankId:     "GB29NWBK60161331926819",
+			BankIdCode: "IBAN",
 			Name:       "name2",
 		},
 		EndToEndReference: "test1",
@@ -88,171 +136,2289 @@ var invalidPaymentAmout = &types.Payment{
 	Attributes: &types.PaymentAttributes{
 		Amount: -1,
 		BeneficiaryParty: &types.PaymentParty{
-			BankId:     "id",
-			BankIdCode: "code",
+			BankId:     "403000",
+			BankIdCode: "GBDSC",
 			Name:       "name",
 		},
 		DebtorParty: &types.PaymentParty{
-			BankId:     "id2",
-			BankIdCode: "code2",
+			BankId:     "GB29NWBK60161331926819",
+			BankIdCode: "IBAN",
 			Name:       "name2",
 		},
 		EndToEndReference: "test1",
 	},
 }
 
-func TestCreatePayment(t *testing.T) {
+func TestCreatePayment(t *testing.T) {
+	ts := httptest.NewServer(getPaymentApi(TestConfig))
+	defer ts.Close()
+	deleteAllPayments(ts, t)
+
+	res := createPayment(ts, t, createPaymentBody(t, invalidPaymentAmout))
+	httpError := parseHttpError(res)
+	res.Body.Close()
+	if res.StatusCode != 400 {
+		t.Errorf("Status code should be 400: is %d", res.StatusCode)
+	}
+	if len(httpError.Messages) == 0 {
+		t.Errorf("Http Error should have error messages")
+	}
+
+	res = createPayment(ts, t, createPaymentBody(t, invalidPaymentType))
+	res.Body.Close()
+	if res.StatusCode != 400 {
+		t.Errorf("Status code should be 400: is %d", res.StatusCode)
+	}
+
+	res = createPayment(ts, t, createPaymentBody(t, validPayment))
+	payment := parsePayment(res)
+	res.Body.Close()
+	if payment.Id == "" {
+		t.Error("Created payment should have id")
+	}
+
+	res = getPayment(ts, t, payment.Id)
+	payment = parsePayment(res)
+	if res.StatusCode != 200 {
+		t.Errorf("Status code should be 200: is %d", res.StatusCode)
+	}
+}
+
+func TestCreatePaymentBankDetails(t *testing.T) {
+	ts := httptest.NewServer(getPaymentApi(TestConfig))
+	defer ts.Close()
+
+	payment := newPayment(func(attributes *types.PaymentAttributes) {
+		attributes.DebtorParty = &types.PaymentParty{
+			BankId:     "GB29NWBK60161331926818",
+			BankIdCode: "IBAN",
+			Name:       "name2",
+		}
+		attributes.BeneficiaryParty = &types.PaymentParty{
+			BankId:     "403000",
+			BankIdCode: "code",
+			Name:       "name",
+		}
+	})
+
+	res := createPayment(ts, t, createPaymentBody(t, payment))
+	httpError := parseHttpError(res)
+	res.Body.Close()
+	if res.StatusCode != 400 {
+		t.Errorf("Status code should be 400: is %d", res.StatusCode)
+	}
+	fields := make(map[string]bool)
+	for _, err := range httpError.Errors {
+		fields[err.Field] = true
+	}
+	for _, field := range []string{"attributes.debtor_party.bank_id", "attributes.beneficiary_party.bank_id_code"} {
+		if !fields[field] {
+			t.Errorf("Http Error should have an error for %s", field)
+		}
+	}
+}
+
+func TestCreatePaymentBankIdFormats(t *testing.T) {
+	ts := httptest.NewServer(getPaymentApi(TestConfig))
+	defer ts.Close()
+
+	for _, step := range []struct {
+		bankIdCode string
+		bankId     string
+	}{
+		{"SWBIC", "DEUT12FF"},
+		{"SWBIC", "DEUTDEFF5"},
+		{"BIC", "deutdeff"},
+		{"GBDSC", "40300"},
+		{"GBDSC", "40-30-00"},
+		{"USABA", "021000022"},
+		{"USABA", "02100002"},
+	} {
+		payment := newPayment(func(attributes *types.PaymentAttributes) {
+			attributes.BeneficiaryParty.BankId = step.bankId
+			attributes.BeneficiaryParty.BankIdCode = step.bankIdCode
+		})
+		res := createPayment(ts, t, createPaymentBody(t, payment))
+		fields := errorFields(parseHttpError(res))
+		res.Body.Close()
+		if res.StatusCode != 400 || !fields["attributes.beneficiary_party.bank_id"] {
+			t.Errorf("Bank id %s of code %s should fail on attributes.beneficiary_party.bank_id: status is %d",
+				step.bankId, step.bankIdCode, res.StatusCode)
+		}
+	}
+}
+
+func TestCreatePaymentSchemeRules(t *testing.T) {
+	ts := httptest.NewServer(getPaymentApi(TestConfig))
+	defer ts.Close()
+	deleteAllPayments(ts, t)
+
+	payment := newPayment(func(attributes *types.PaymentAttributes) {
+		attributes.PaymentScheme = "FPS"
+		attributes.SchemePaymentType = "ImmediatePayment"
+		attributes.Currency = "GBP"
+		attributes.ProcessingDate = "2099-05-01"
+		attributes.Reference = "invoice 1"
+		attributes.NumericReference = "1002001"
+	})
+
+	res := createPayment(ts, t, createPaymentBody(t, payment))
+	created := parsePayment(res)
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Errorf("Status code should be 200: is %d", res.StatusCode)
+	}
+	if created.Attributes.PaymentScheme != "FPS" || created.Attributes.ProcessingDate != "2099-05-01" {
+		t.Errorf("Created payment should keep scheme and processing date: is %+v", created.Attributes)
+	}
+
+	payment.Attributes.PaymentScheme = "SEPA"
+	res = createPayment(ts, t, createPaymentBody(t, payment))
+	httpError := parseHttpError(res)
+	res.Body.Close()
+	if res.StatusCode != 400 {
+		t.Errorf("Status code should be 400: is %d", res.StatusCode)
+	}
+	currencyError := false
+	for _, err := range httpError.Errors {
+		currencyError = currencyError || err.Field == "attributes.currency"
+	}
+	if !currencyError {
+		t.Errorf("SEPA payment in GBP should have a currency error")
+	}
+}
+
+func TestCreatePaymentOrganisationSchema(t *testing.T) {
+	ts := httptest.NewServer(getPaymentApi(TestConfig))
+	defer ts.Close()
+	deleteAllPayments(ts, t)
+
+	payment := newPayment(func(attributes *types.PaymentAttributes) {
+		attributes.Amount = 2000
+	})
+	payment.OrganisationId = "test-schema"
+
+	res := createPayment(ts, t, createPaymentBody(t, payment))
+	fields := errorFields(parseHttpError(res))
+	res.Body.Close()
+	if res.StatusCode != 400 {
+		t.Errorf("Status code should be 400: is %d", res.StatusCode)
+	}
+	for _, field := range []string{"attributes.amount", "attributes.reference"} {
+		if !fields[field] {
+			t.Errorf("Http Error should have an organisation constraint error for %s", field)
+		}
+	}
+
+	payment.Attributes.Amount = 500
+	payment.Attributes.Reference = "invoice 1"
+	res = createPayment(ts, t, createPaymentBody(t, payment))
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Errorf("Status code should be 200: is %d", res.StatusCode)
+	}
+}
+
+func TestSchemaVersions(t *testing.T) {
+	config := *TestConfig
+	config.SchemaVersions = []string{"v1", "v2"}
+	ts := httptest.NewServer(getPaymentApi(&config))
+	defer ts.Close()
+	deleteAllPayments(ts, t)
+
+	// v2 requires a payment scheme, which v1 payments can leave out
+	res := createPayment(ts, t, createPaymentBody(t, validPayment))
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Errorf("v1 status code should be 200: is %d", res.StatusCode)
+	}
+
+	res, err := http.Post(ts.URL+"/v2/api/payments", "application/json", bytes.NewReader(createPaymentBody(t, validPayment)))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fields := errorFields(parseHttpError(res))
+	res.Body.Close()
+	if res.StatusCode != 400 {
+		t.Errorf("v2 status code should be 400: is %d", res.StatusCode)
+	}
+	if !fields["attributes.payment_scheme"] {
+		t.Errorf("v2 Http Error should have an error for attributes.payment_scheme")
+	}
+}
+
+func TestCreatePaymentDuplicates(t *testing.T) {
+	ts := httptest.NewServer(getPaymentApi(TestConfig))
+	defer ts.Close()
+	deleteAllPayments(ts, t)
+
+	// Flagged by the organisation policy when the reference differs
+	res := createPayment(ts, t, createPaymentBody(t, validPayment))
+	first := parsePayment(res)
+	res.Body.Close()
+	payment := newPayment(func(attributes *types.PaymentAttributes) {
+		attributes.EndToEndReference = "test1-again"
+	})
+	res = createPayment(ts, t, createPaymentBody(t, payment))
+	second := parsePayment(res)
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Errorf("Status code should be 200: is %d", res.StatusCode)
+	}
+	if first.PossibleDuplicate || !second.PossibleDuplicate {
+		t.Errorf("Only the second payment should be flagged as a possible duplicate")
+	}
+
+	// The same amount in another currency is not a duplicate
+	payment = newPayment(func(attributes *types.PaymentAttributes) {
+		attributes.EndToEndReference = "test1-gbp"
+		attributes.Currency = "GBP"
+	})
+	res = createPayment(ts, t, createPaymentBody(t, payment))
+	other := parsePayment(res)
+	res.Body.Close()
+	if other.PossibleDuplicate {
+		t.Errorf("Payment in another currency should not be flagged as a possible duplicate")
+	}
+	deleteAllPayments(ts, t)
+
+	// Rejected by the duplicate check of the organisation policy and, for
+	// flagging organisations, by the unique end to end reference
+	for _, organisationId := range []string{"test-reject-duplicates", "test"} {
+		payment := newPayment(nil)
+		payment.OrganisationId = organisationId
+		res := createPayment(ts, t, createPaymentBody(t, payment))
+		existing := parsePayment(res)
+		res.Body.Close()
+		if organisationId == "test-reject-duplicates" {
+			payment.Attributes.EndToEndReference = "test1-again"
+		}
+		res = createPayment(ts, t, createPaymentBody(t, payment))
+		httpError := parseHttpError(res)
+		res.Body.Close()
+		if res.StatusCode != 409 {
+			t.Errorf("Status code should be 409: is %d", res.StatusCode)
+		}
+		if httpError.ExistingId != existing.Id {
+			t.Errorf("Conflict should point at payment %s: points at %s", existing.Id, httpError.ExistingId)
+		}
+	}
+}
+
+func TestEndToEndReferenceUnique(t *testing.T) {
+	ts := httptest.NewServer(getPaymentApi(TestConfig))
+	defer ts.Close()
+	deleteAllPayments(ts, t)
+
+	res := createPayment(ts, t, createPaymentBody(t, validPayment))
+	first := parsePayment(res)
+	res.Body.Close()
+
+	payment := newPayment(func(attributes *types.PaymentAttributes) {
+		attributes.EndToEndReference = "test2"
+	})
+	res = createPayment(ts, t, createPaymentBody(t, payment))
+	second := parsePayment(res)
+	res.Body.Close()
+
+	res = updatePayment(ts, t, second.Id, createPaymentBody(t, validPaymentUpdate))
+	httpError := parseHttpError(res)
+	res.Body.Close()
+	if res.StatusCode != 409 || httpError.ExistingId != first.Id {
+		t.Errorf("Update to a used reference should conflict with %s: status %d, existing id %s", first.Id, res.StatusCode, httpError.ExistingId)
+	}
+
+	res = getPaymentsQuery(ts, t, "/references/test2?organisation_id="+validPayment.OrganisationId)
+	found := parsePayment(res)
+	res.Body.Close()
+	if res.StatusCode != 200 || found.Id != second.Id {
+		t.Errorf("Reference test2 should find payment %s: status %d", second.Id, res.StatusCode)
+	}
+
+	res = getPaymentsQuery(ts, t, "/references/test2?organisation_id=other")
+	res.Body.Close()
+	if res.StatusCode != 404 {
+		t.Errorf("Status code should be 404 for another organisation: is %d", res.StatusCode)
+	}
+
+	res = getPaymentsQuery(ts, t, "/references/test2")
+	res.Body.Close()
+	if res.StatusCode != 400 {
+		t.Errorf("Status code should be 400 without organisation id: is %d", res.StatusCode)
+	}
+
+	duplicates, err := getApplication(TestConfig).paymentStore.GetDuplicateReferences()
+	if err != nil || len(duplicates) != 0 {
+		t.Errorf("No payments should share a reference: error %v, duplicates %d", err, len(duplicates))
+	}
+}
+
+func TestPaymentLimits(t *testing.T) {
+	ts := httptest.NewServer(getPaymentApi(TestConfig))
+	defer ts.Close()
+	deleteAllPayments(ts, t)
+
+	payment := newPayment(nil)
+	payment.OrganisationId = "test-limits"
+
+	// Limits are a maximum of 100, 150 a day and 3 payments an hour
+	ids := make([]string, 0)
+	for i, amount := range []float64{101, 80, 80, 50, 10, 5} {
+		payment.Attributes.Amount = amount
+		payment.Attributes.EndToEndReference = "limits" + strconv.Itoa(i)
+		res := createPayment(ts, t, createPaymentBody(t, payment))
+		if res.StatusCode == 200 {
+			ids = append(ids, parsePayment(res).Id)
+		} else {
+			httpError := parseHttpError(res)
+			expected := map[float64]string{101: "max_amount", 80: "daily_amount", 5: "hourly_count"}[amount]
+			if res.StatusCode != 422 || httpError.Limit != expected {
+				t.Errorf("Payment of %f should hit limit %s: status %d, limit %s", amount, expected, res.StatusCode, httpError.Limit)
+			}
+		}
+		res.Body.Close()
+	}
+	if len(ids) != 3 {
+		t.Fatalf("3 payments should be created: %d were", len(ids))
+	}
+
+	res, err := http.Get(ts.URL + "/v1/api/limits?organisation_id=test-limits")
+	if err != nil {
+		t.Fatalf("Failed to get limits: %s", err.Error())
+	}
+	var usage types.LimitUsage
+	json.NewDecoder(res.Body).Decode(&usage)
+	res.Body.Close()
+	if len(usage.Currencies) != 1 || usage.Currencies[0].DailyAmount != 140 || usage.HourlyCount != 3 || usage.Limits.DailyAmount != 150 {
+		t.Errorf("Usage should be 140 of 150 today and 3 payments this hour: is %+v", usage)
+	}
+
+	update := newPaymentUpdate(func(attributes *types.PaymentAttributes) {
+		attributes.Amount = 95
+		attributes.EndToEndReference = "limits1"
+	})
+	res = updatePayment(ts, t, ids[0], createPaymentBody(t, update))
+	res.Body.Close()
+	if res.StatusCode != 422 {
+		t.Errorf("Update over the daily limit should have status 422: is %d", res.StatusCode)
+	}
+
+	// Concurrent payments cannot together exceed the daily limit
+	deleteAllPayments(ts, t)
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		concurrent := newPayment(func(attributes *types.PaymentAttributes) {
+			attributes.Amount = 60
+			attributes.EndToEndReference = "concurrent" + strconv.Itoa(i)
+		})
+		concurrent.OrganisationId = "test-limits"
+		wg.Add(1)
+		go func(body []byte) {
+			defer wg.Done()
+			res := createPayment(ts, t, body)
+			res.Body.Close()
+		}(createPaymentBody(t, concurrent))
+	}
+	wg.Wait()
+	res, _ = http.Get(ts.URL + "/v1/api/limits?organisation_id=test-limits")
+	usage = types.LimitUsage{}
+	json.NewDecoder(res.Body).Decode(&usage)
+	res.Body.Close()
+	if len(usage.Currencies) != 1 || usage.Currencies[0].DailyAmount > 150 {
+		t.Errorf("Concurrent payments should stay within 150 today: are %+v", usage.Currencies)
+	}
+}
+
+func TestPaymentMonthlyLimits(t *testing.T) {
+	ts := httptest.NewServer(getPaymentApi(TestConfig))
+	defer ts.Close()
+	deleteAllPayments(ts, t)
+
+	// Limit of 100 a month, amounts of each currency are summed separately
+	payments := []struct {
+		amount   float64
+		currency string
+		status   int
+	}{
+		{60, "GBP", 200},
+		{50, "GBP", 422},
+		{40, "GBP", 200},
+		{90, "EUR", 200},
+	}
+	for i, expected := range payments {
+		payment := newPayment(func(attributes *types.PaymentAttributes) {
+			attributes.Amount = expected.amount
+			attributes.Currency = expected.currency
+			attributes.EndToEndReference = "monthly" + strconv.Itoa(i)
+		})
+		payment.OrganisationId = "test-monthly-limits"
+		res := createPayment(ts, t, createPaymentBody(t, payment))
+		httpError := parseHttpError(res)
+		res.Body.Close()
+		if res.StatusCode != expected.status {
+			t.Errorf("Payment of %.2f %s should have status %d: is %d", expected.amount, expected.currency, expected.status, res.StatusCode)
+		}
+		if expected.status == 422 && httpError.Limit != "monthly_amount" {
+			t.Errorf("Payment of %.2f %s should hit the monthly limit: hit %s", expected.amount, expected.currency, httpError.Limit)
+		}
+	}
+
+	res, err := http.Get(ts.URL + "/v1/api/limits?organisation_id=test-monthly-limits")
+	if err != nil {
+		t.Fatalf("Failed to get limits: %s", err.Error())
+	}
+	var usage types.LimitUsage
+	json.NewDecoder(res.Body).Decode(&usage)
+	res.Body.Close()
+	monthly := make(map[string]float64)
+	for _, currency := range usage.Currencies {
+		monthly[currency.Currency] = currency.MonthlyAmount
+	}
+	if monthly["GBP"] != 100 || monthly["EUR"] != 90 {
+		t.Errorf("Usage should be 100 GBP and 90 EUR this month: is %v", monthly)
+	}
+}
+
+func TestPaymentApprovals(t *testing.T) {
+	ts := httptest.NewServer(getPaymentApi(TestConfig))
+	defer ts.Close()
+	deleteAllPayments(ts, t)
+
+	payment := newPayment(nil)
+	payment.OrganisationId = "test-approvals"
+
+	// Payments over 1000 need two approvals by users other than their creator
+	payment.Attributes.Amount = 500
+	payment.Attributes.EndToEndReference = "approvals0"
+	res := requestAsUser(ts, t, "POST", "/v1/api/payments", "maker", createPaymentBody(t, payment))
+	created := parsePayment(res)
+	res.Body.Close()
+	if created.Status != types.PaymentAccepted {
+		t.Errorf("Payment under the threshold should be accepted: is %s", created.Status)
+	}
+
+	payment.Attributes.Amount = 5000
+	payment.Attributes.EndToEndReference = "approvals1"
+	res = requestAsUser(ts, t, "POST", "/v1/api/payments", "maker", createPaymentBody(t, payment))
+	created = parsePayment(res)
+	res.Body.Close()
+	if created.Status != types.PaymentPendingApproval || created.CreatedBy != "maker" {
+		t.Fatalf("Payment over the threshold should be pending approval by maker: is %s by %s", created.Status, created.CreatedBy)
+	}
+
+	approvals := "/v1/api/payments/" + created.Id + "/approvals"
+	for _, step := range []struct {
+		user   string
+		status int
+		state  string
+	}{
+		{"", 400, ""},
+		{"maker", 403, ""},
+		{"checker1", 200, types.PaymentPendingApproval},
+		{"checker1", 409, ""},
+		{"checker2", 200, types.PaymentAccepted},
+		{"checker3", 409, ""},
+	} {
+		res = requestAsUser(ts, t, "POST", approvals, step.user, nil)
+		if res.StatusCode != step.status {
+			t.Errorf("Approval by %q should have status %d: is %d", step.user, step.status, res.StatusCode)
+		} else if step.status == 200 {
+			if approved := parsePayment(res); approved.Status != step.state {
+				t.Errorf("Payment should be %s after approval by %s: is %s", step.state, step.user, approved.Status)
+			}
+		}
+		res.Body.Close()
+	}
+
+	res = requestAsUser(ts, t, "POST", "/v1/api/payments/invalid/approvals", "checker1", nil)
+	res.Body.Close()
+	if res.StatusCode != 404 {
+		t.Errorf("Approval of missing payment should have status 404: is %d", res.StatusCode)
+	}
+
+	// Updates need approving again
+	update := newPaymentUpdate(func(attributes *types.PaymentAttributes) {
+		attributes.Amount = 6000
+		attributes.EndToEndReference = "approvals1"
+	})
+	res = requestAsUser(ts, t, "PUT", "/v1/api/payments/"+created.Id, "checker1", createPaymentBody(t, update))
+	updated := parsePayment(res)
+	res.Body.Close()
+	if updated.Status != types.PaymentPendingApproval || len(updated.Approvals) != 0 {
+		t.Errorf("Updated payment should be pending approval again: is %s with %d approvals", updated.Status, len(updated.Approvals))
+	}
+	res = requestAsUser(ts, t, "POST", approvals, "checker1", nil)
+	res.Body.Close()
+	if res.StatusCode != 403 {
+		t.Errorf("Approval by the last editor should have status 403: is %d", res.StatusCode)
+	}
+
+	// Writes that need approving must name their user
+	res = requestAsUser(ts, t, "PUT", "/v1/api/payments/"+created.Id, "", createPaymentBody(t, update))
+	res.Body.Close()
+	if res.StatusCode != 400 {
+		t.Errorf("Update needing approval without a user should have status 400: is %d", res.StatusCode)
+	}
+	payment.Attributes.EndToEndReference = "approvals2"
+	res = createPayment(ts, t, createPaymentBody(t, payment))
+	res.Body.Close()
+	if res.StatusCode != 400 {
+		t.Errorf("Payment needing approval without a user should have status 400: is %d", res.StatusCode)
+	}
+}
+
+// Clock fixed at a time, to release scheduled payments without waiting
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+// Test configuration with the clock fixed at a time
+func configAt(at time.Time) *ConfigProperties {
+	config := *TestConfig
+	config.Clock = fixedClock(at)
+	return &config
+}
+
+func TestScheduledPayments(t *testing.T) {
+	ts := httptest.NewServer(getPaymentApi(TestConfig))
+	defer ts.Close()
+	deleteAllPayments(ts, t)
+
+	now := time.Now().UTC()
+	payment := newPayment(nil)
+	executionDate := now.Add(time.Hour)
+	payment.ExecutionDate = &executionDate
+
+	payment.Attributes.EndToEndReference = "scheduled0"
+	res := createPayment(ts, t, createPaymentBody(t, payment))
+	scheduled := parsePayment(res)
+	res.Body.Close()
+	if scheduled.Status != types.PaymentScheduled {
+		t.Fatalf("Payment with an execution date should be scheduled: is %s", scheduled.Status)
+	}
+	payment.Attributes.EndToEndReference = "scheduled1"
+	res = createPayment(ts, t, createPaymentBody(t, payment))
+	cancelled := parsePayment(res)
+	res.Body.Close()
+
+	schedule := []byte(`{"execution_date": "` + now.Add(2*time.Hour).Format(time.RFC3339) + `"}`)
+	res = requestAsUser(ts, t, "PUT", "/v1/api/payments/"+scheduled.Id+"/schedule", "", schedule)
+	rescheduled := parsePayment(res)
+	res.Body.Close()
+	if rescheduled.ExecutionDate == nil || !rescheduled.ExecutionDate.After(executionDate) {
+		t.Errorf("Payment should be rescheduled 2 hours ahead: is %v", rescheduled.ExecutionDate)
+	}
+	res = requestAsUser(ts, t, "PUT", "/v1/api/payments/"+scheduled.Id+"/schedule", "", []byte(`{}`))
+	res.Body.Close()
+	if res.StatusCode != 400 {
+		t.Errorf("Reschedule without execution date should have status 400: is %d", res.StatusCode)
+	}
+	res = requestAsUser(ts, t, "DELETE", "/v1/api/payments/"+cancelled.Id+"/schedule", "", nil)
+	res.Body.Close()
+	if res.StatusCode != 400 {
+		t.Errorf("Cancelling schedule without reason code should have status 400: is %d", res.StatusCode)
+	}
+	res = requestAsUser(ts, t, "DELETE", "/v1/api/payments/"+cancelled.Id+"/schedule", "user1", []byte(`{"reason_code": "CUST"}`))
+	cancelledPayment := parsePayment(res)
+	res.Body.Close()
+	if cancelledPayment.Status != types.PaymentCancelled {
+		t.Errorf("Payment should be cancelled: is %s", cancelledPayment.Status)
+	}
+	if c := cancelledPayment.Cancellation; c == nil || c.ReasonCode != "CUST" || c.CancelledBy != "user1" {
+		t.Errorf("Cancellation of scheduled payment should be recorded: is %+v", c)
+	}
+
+	for _, step := range []struct {
+		at       time.Time
+		released int
+	}{
+		{now.Add(90 * time.Minute), 0},
+		{now.Add(3 * time.Hour), 1},
+		{now.Add(3 * time.Hour), 0},
+	} {
+		released, err := getScheduler(configAt(step.at)).ReleaseDue()
+		if err != nil {
+			t.Fatalf("Failed to release payments: %s", err.Error())
+		}
+		if released != step.released {
+			t.Errorf("Released payments at %s should be %d: are %d", step.at, step.released, released)
+		}
+	}
+
+	res = getPayment(ts, t, scheduled.Id)
+	if released := parsePayment(res); released.Status != types.PaymentAccepted {
+		t.Errorf("Due payment should be accepted: is %s", released.Status)
+	}
+	res.Body.Close()
+	for _, id := range []string{scheduled.Id, cancelled.Id} {
+		res = requestAsUser(ts, t, "DELETE", "/v1/api/payments/"+id+"/schedule", "", []byte(`{"reason_code": "CUST"}`))
+		res.Body.Close()
+		if res.StatusCode != 409 {
+			t.Errorf("Cancelling payment %s that is not scheduled should have status 409: is %d", id, res.StatusCode)
+		}
+	}
+
+	scheduler := getScheduler(TestConfig)
+	scheduler.Start()
+	scheduler.Stop()
+	scheduler.Stop()
+}
+
+func TestStandingOrders(t *testing.T) {
+	ts := httptest.NewServer(getPaymentApi(TestConfig))
+	defer ts.Close()
+	deleteAllPayments(ts, t)
+
+	// Monthly from Thursday 31 January, the 31 March occurrence is a Sunday
+	startDate := time.Date(2030, time.January, 31, 9, 0, 0, 0, time.UTC)
+	template := newPayment(func(attributes *types.PaymentAttributes) {
+		attributes.EndToEndReference = "standing" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	}).Attributes
+	order := &types.StandingOrder{
+		Type:           "StandingOrder",
+		OrganisationId: validPayment.OrganisationId,
+		Template:       template,
+		Recurrence: &types.Recurrence{
+			Frequency: types.Monthly,
+			StartDate: &startDate,
+			Count:     3,
+		},
+	}
+	body, _ := json.Marshal(order)
+	res := requestAsUser(ts, t, "POST", "/v1/api/standing-orders", "maker", body)
+	created := parseStandingOrder(res)
+	res.Body.Close()
+	if created.Status != types.StandingOrderActive || !created.NextExecutionDate.Equal(startDate) {
+		t.Fatalf("Standing order should be active from %s: is %s from %v", startDate, created.Status, created.NextExecutionDate)
+	}
+
+	body, _ = json.Marshal(&types.StandingOrder{Type: "StandingOrder", OrganisationId: "test", Template: template})
+	res = requestAsUser(ts, t, "POST", "/v1/api/standing-orders", "", body)
+	res.Body.Close()
+	if res.StatusCode != 400 {
+		t.Errorf("Standing order without recurrence should have status 400: is %d", res.StatusCode)
+	}
+
+	orderPath := "/v1/api/standing-orders/" + created.Id
+	for _, step := range []struct {
+		action string
+		status int
+	}{
+		{"pause", 200},
+		{"pause", 409},
+		{"generate", 0},
+		{"resume", 200},
+		{"resume", 409},
+	} {
+		if step.action == "generate" {
+			getScheduler(configAt(startDate.AddDate(0, 1, 0))).GenerateDue()
+			continue
+		}
+		res = requestAsUser(ts, t, "POST", orderPath+"/"+step.action, "", nil)
+		res.Body.Close()
+		if res.StatusCode != step.status {
+			t.Errorf("Standing order %s should have status %d: is %d", step.action, step.status, res.StatusCode)
+		}
+	}
+
+	expected := []time.Time{
+		startDate,
+		time.Date(2030, time.February, 28, 9, 0, 0, 0, time.UTC),
+		time.Date(2030, time.April, 1, 9, 0, 0, 0, time.UTC),
+	}
+	for _, step := range []struct {
+		at        time.Time
+		generated int
+		status    string
+	}{
+		{time.Date(2030, time.March, 1, 0, 0, 0, 0, time.UTC), 2, types.StandingOrderActive},
+		{time.Date(2030, time.April, 2, 0, 0, 0, 0, time.UTC), 3, types.StandingOrderCompleted},
+	} {
+		if _, err := getScheduler(configAt(step.at)).GenerateDue(); err != nil {
+			t.Fatalf("Failed to generate payments: %s", err.Error())
+		}
+		res = requestAsUser(ts, t, "GET", orderPath, "", nil)
+		generated := parseStandingOrder(res)
+		res.Body.Close()
+		if len(generated.Generated) != step.generated || generated.Status != step.status {
+			t.Fatalf("Standing order should be %s with %d payments at %s: is %s with %d",
+				step.status, step.generated, step.at, generated.Status, len(generated.Generated))
+		}
+		for i, payment := range generated.Generated {
+			if !payment.ExecutionDate.Equal(expected[i]) || payment.PaymentId == "" {
+				t.Errorf("Occurrence %d should be a payment on %s: is %+v", i+1, expected[i], payment)
+			}
+		}
+		if step.status == types.StandingOrderCompleted {
+			res = getPayment(ts, t, generated.Generated[0].PaymentId)
+			payment := parsePayment(res)
+			res.Body.Close()
+			if payment.Attributes.EndToEndReference != template.EndToEndReference+"-1" || payment.CreatedBy != "maker" {
+				t.Errorf("Generated payment should have reference %s-1 by maker: is %s by %s",
+					template.EndToEndReference, payment.Attributes.EndToEndReference, payment.CreatedBy)
+			}
+
+			// Occurrences caught up together repeat the amount without
+			// being duplicates
+			for _, occurrence := range generated.Generated[1:] {
+				res = getPayment(ts, t, occurrence.PaymentId)
+				payment := parsePayment(res)
+				res.Body.Close()
+				if payment.PossibleDuplicate || payment.StandingOrderId != created.Id {
+					t.Errorf("Payment %s of standing order %s should not be flagged as a duplicate: is %+v",
+						occurrence.PaymentId, created.Id, payment)
+				}
+			}
+		}
+	}
+
+	res = requestAsUser(ts, t, "GET", "/v1/api/standing-orders/invalid", "", nil)
+	res.Body.Close()
+	if res.StatusCode != 404 {
+		t.Errorf("Missing standing order should have status 404: is %d", res.StatusCode)
+	}
+}
+
+func TestResumeStandingOrder(t *testing.T) {
+	// Resumed on Saturday 16 March, after the occurrences of 15 January,
+	// February and March
+	resumedAt := time.Date(2030, time.March, 16, 0, 0, 0, 0, time.UTC)
+	ts := httptest.NewServer(getPaymentApi(configAt(resumedAt)))
+	defer ts.Close()
+	deleteAllPayments(ts, t)
+
+	startDate := time.Date(2030, time.January, 15, 9, 0, 0, 0, time.UTC)
+	template := newPayment(func(attributes *types.PaymentAttributes) {
+		attributes.EndToEndReference = "resumed" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	}).Attributes
+	body, _ := json.Marshal(&types.StandingOrder{
+		Type:           "StandingOrder",
+		OrganisationId: validPayment.OrganisationId,
+		Template:       template,
+		Recurrence: &types.Recurrence{
+			Frequency: types.Monthly,
+			StartDate: &startDate,
+		},
+	})
+	res := requestAsUser(ts, t, "POST", "/v1/api/standing-orders", "maker", body)
+	created := parseStandingOrder(res)
+	res.Body.Close()
+	orderPath := "/v1/api/standing-orders/" + created.Id
+	for _, action := range []string{"pause", "resume"} {
+		res = requestAsUser(ts, t, "POST", orderPath+"/"+action, "", nil)
+		res.Body.Close()
+		if res.StatusCode != 200 {
+			t.Fatalf("Standing order %s should have status 200: is %d", action, res.StatusCode)
+		}
+	}
+
+	if _, err := getScheduler(configAt(resumedAt)).GenerateDue(); err != nil {
+		t.Fatalf("Failed to generate payments: %s", err.Error())
+	}
+	res = requestAsUser(ts, t, "GET", orderPath, "", nil)
+	resumed := parseStandingOrder(res)
+	res.Body.Close()
+	next := time.Date(2030, time.April, 15, 9, 0, 0, 0, time.UTC)
+	if resumed.Occurrences != 3 || len(resumed.Generated) != 0 || !resumed.NextExecutionDate.Equal(next) {
+		t.Errorf("Occurrences due while paused should be skipped until %s: %d occurrences, %d generated, next %v",
+			next, resumed.Occurrences, len(resumed.Generated), resumed.NextExecutionDate)
+	}
+}
+
+func TestRefundPayment(t *testing.T) {
+	ts := httptest.NewServer(getPaymentApi(TestConfig))
+	defer ts.Close()
+	deleteAllPayments(ts, t)
+
+	res := createPayment(ts, t, createPaymentBody(t, validPayment))
+	payment := parsePayment(res)
+	res.Body.Close()
+	refunds := "/v1/api/payments/" + payment.Id + "/refunds"
+
+	// The payment of 3 is refunded by 1.25 and then the remaining 1.75
+	for _, step := range []struct {
+		body   string
+		status int
+		amount float64
+	}{
+		{`{"amount": 1.25, "reason_code": "MD06"}`, 200, 1.25},
+		{`{"amount": 1, "reason_code": "XXXX"}`, 400, 0},
+		{`{"amount": 2, "reason_code": "CUST"}`, 400, 0},
+		{`{"reason_code": "CUST"}`, 200, 1.75},
+		{`{"reason_code": "CUST"}`, 400, 0},
+	} {
+		res = requestAsUser(ts, t, "POST", refunds, "", []byte(step.body))
+		if res.StatusCode != step.status {
+			t.Errorf("Refund %s should have status %d: is %d", step.body, step.status, res.StatusCode)
+		} else if step.status == 200 {
+			refund := parsePayment(res)
+			if refund.RelatedPaymentId != payment.Id || refund.Attributes.Amount != step.amount ||
+				refund.Attributes.DebtorParty.Name != payment.Attributes.BeneficiaryParty.Name {
+				t.Errorf("Refund of %f should go back to the debtor of %s: is %+v", step.amount, payment.Id, refund)
+			}
+			res.Body.Close()
+			res = requestAsUser(ts, t, "POST", "/v1/api/payments/"+refund.Id+"/refunds", "", []byte(step.body))
+			if res.StatusCode != 409 {
+				t.Errorf("Refund of a refund should have status 409: is %d", res.StatusCode)
+			}
+		}
+		res.Body.Close()
+	}
+
+	res = getPayment(ts, t, payment.Id)
+	refunded := parsePayment(res)
+	res.Body.Close()
+	if len(refunded.Refunds) != 2 || refunded.Refunds[0].ReturnReason != "MD06" {
+		t.Errorf("Payment should list its 2 refunds: is %+v", refunded.Refunds)
+	}
+	res = updatePayment(ts, t, refunded.Id, createPaymentBody(t, refunded))
+	res.Body.Close()
+	if res.StatusCode != 409 {
+		t.Errorf("Status updating a refunded payment should be 409: is %d", res.StatusCode)
+	}
+	res = deletePayment(ts, t, refunded.Id)
+	res.Body.Close()
+	if res.StatusCode != 409 {
+		t.Errorf("Status deleting a refunded payment should be 409: is %d", res.StatusCode)
+	}
+
+	// Cancelled refunds return nothing, their amount can be refunded again
+	res = requestAsUser(ts, t, "POST", "/v1/api/payments/"+refunded.Refunds[1].PaymentId+"/cancellation", "operator",
+		[]byte(`{"reason_code": "CUST"}`))
+	res.Body.Close()
+	res = requestAsUser(ts, t, "POST", refunds, "", []byte(`{"reason_code": "CUST"}`))
+	refund := parsePayment(res)
+	res.Body.Close()
+	if res.StatusCode != 200 || refund.Attributes == nil || refund.Attributes.Amount != 1.75 {
+		t.Errorf("Amount of the cancelled refund should be refundable: status %d, refund %+v", res.StatusCode, refund)
+	}
+
+	res = requestAsUser(ts, t, "POST", "/v1/api/payments/invalid/refunds", "", []byte(`{"reason_code": "CUST"}`))
+	res.Body.Close()
+	if res.StatusCode != 404 {
+		t.Errorf("Refund of missing payment should have status 404: is %d", res.StatusCode)
+	}
+}
+
+func TestCancelPayment(t *testing.T) {
+	ts := httptest.NewServer(getPaymentApi(TestConfig))
+	defer ts.Close()
+	deleteAllPayments(ts, t)
+
+	res := createPayment(ts, t, createPaymentBody(t, validPayment))
+	payment := parsePayment(res)
+	res.Body.Close()
+	cancellation := "/v1/api/payments/" + payment.Id + "/cancellation"
+
+	res = requestAsUser(ts, t, "POST", cancellation, "operator", []byte(`{"reason_code": "XXXX"}`))
+	res.Body.Close()
+	if res.StatusCode != 400 {
+		t.Errorf("Cancellation with unknown reason should have status 400: is %d", res.StatusCode)
+	}
+	res = requestAsUser(ts, t, "POST", cancellation, "operator", []byte(`{"reason_code": "DUPL"}`))
+	cancelled := parsePayment(res)
+	res.Body.Close()
+	if cancelled.Status != types.PaymentCancelled || cancelled.Cancellation == nil ||
+		cancelled.Cancellation.ReasonCode != "DUPL" || cancelled.Cancellation.CancelledBy != "operator" {
+		t.Errorf("Payment should be cancelled by operator as DUPL: is %s with %+v", cancelled.Status, cancelled.Cancellation)
+	}
+
+	res = getPayment(ts, t, payment.Id)
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Errorf("Cancelled payment should be kept: status is %d", res.StatusCode)
+	}
+	for _, request := range []struct {
+		method string
+		path   string
+		body   []byte
+	}{
+		{"POST", cancellation, []byte(`{"reason_code": "CUST"}`)},
+		{"PUT", "/v1/api/payments/" + payment.Id, createPaymentBody(t, validPaymentUpdate)},
+		{"POST", "/v1/api/payments/" + payment.Id + "/refunds", []byte(`{"reason_code": "CUST"}`)},
+	} {
+		res = requestAsUser(ts, t, request.method, request.path, "", request.body)
+		res.Body.Close()
+		if res.StatusCode != 409 {
+			t.Errorf("%s %s of cancelled payment should have status 409: is %d", request.method, request.path, res.StatusCode)
+		}
+	}
+
+	res = requestAsUser(ts, t, "POST", "/v1/api/payments/invalid/cancellation", "", []byte(`{"reason_code": "CUST"}`))
+	res.Body.Close()
+	if res.StatusCode != 404 {
+		t.Errorf("Cancellation of missing payment should have status 404: is %d", res.StatusCode)
+	}
+}
+
+func TestProcessingDates(t *testing.T) {
+	ts := httptest.NewServer(getPaymentApi(TestConfig))
+	defer ts.Close()
+	deleteAllPayments(ts, t)
+
+	for _, step := range []struct {
+		calendar string
+		date     string
+		status   int
+		next     string
+	}{
+		{"GBP", "2026-12-24", 200, "2026-12-29"},
+		{"EUR", "2026-12-24", 200, "2026-12-28"},
+		{"USD", "2026-11-25", 200, "2026-11-27"},
+		{"GBP", "24/12/2026", 400, ""},
+		{"XXX", "2026-12-24", 404, ""},
+	} {
+		res, err := http.Get(ts.URL + "/v1/api/calendars/" + step.calendar + "/next-business-day?date=" + step.date)
+		if err != nil {
+			t.Fatalf("Failed to get next business day: %s", err.Error())
+		}
+		var businessDay types.BusinessDay
+		json.NewDecoder(res.Body).Decode(&businessDay)
+		res.Body.Close()
+		if res.StatusCode != step.status || businessDay.NextBusinessDay != step.next {
+			t.Errorf("Next business day after %s in %s should be %q with status %d: is %q with status %d",
+				step.date, step.calendar, step.next, step.status, businessDay.NextBusinessDay, res.StatusCode)
+		}
+	}
+
+	// Sterling payments are processed on business days in England and Wales
+	payment := newPayment(func(attributes *types.PaymentAttributes) {
+		attributes.Currency = "GBP"
+	})
+	for i, step := range []struct {
+		date   string
+		status int
+	}{
+		{"2027-12-27", 400},
+		{"2020-01-02", 400},
+		{"2027-12-29", 200},
+		{"", 200},
+	} {
+		payment.Attributes.ProcessingDate = step.date
+		payment.Attributes.EndToEndReference = "processing" + strconv.Itoa(i)
+		res := createPayment(ts, t, createPaymentBody(t, payment))
+		created := parsePayment(res)
+		res.Body.Close()
+		if res.StatusCode != step.status {
+			t.Errorf("Payment processed on %q should have status %d: is %d", step.date, step.status, res.StatusCode)
+		} else if step.status == 200 && step.date == "" {
+			date, err := time.Parse("2006-01-02", created.Attributes.ProcessingDate)
+			if err != nil || date.Weekday() == time.Saturday || date.Weekday() == time.Sunday ||
+				date.Before(time.Now().UTC().Truncate(24*time.Hour)) {
+				t.Errorf("Processing date should be set to a business day from today: is %q", created.Attributes.ProcessingDate)
+			}
+		}
+	}
+
+	// Edits keep a processing date that passed since it was set
+	payment.Attributes.EndToEndReference = "processing-passed"
+	res := createPayment(ts, t, createPaymentBody(t, payment))
+	created := parsePayment(res)
+	res.Body.Close()
+	created.Attributes.ProcessingDate = "2020-01-02"
+	passed, err := getApplication(TestConfig).paymentStore.UpdatePayment(created, types.PaymentUpdated, nil)
+	if err != nil {
+		t.Fatalf("Failed to move processing date: %s", err.Error())
+	}
+	passed.Attributes.Reference = "edited"
+	res = updatePayment(ts, t, passed.Id, createPaymentBody(t, passed))
+	updated := parsePayment(res)
+	res.Body.Close()
+	if res.StatusCode != 200 || updated.Attributes.ProcessingDate != "2020-01-02" {
+		t.Errorf("Edit should keep processing date 2020-01-02 with status 200: is %q with status %d",
+			updated.Attributes.ProcessingDate, res.StatusCode)
+	}
+}
+
+func TestFxConversion(t *testing.T) {
+	ts := httptest.NewServer(getPaymentApi(TestConfig))
+	defer ts.Close()
+	deleteAllPayments(ts, t)
+
+	res := requestAsUser(ts, t, "POST", "/v1/api/fx-rates", "",
+		[]byte(`{"data": [{"base_currency": "GBP", "quote_currency": "EUR", "rate": 1.15}]}`))
+	var rates types.FxRates
+	json.NewDecoder(res.Body).Decode(&rates)
+	res.Body.Close()
+	if res.StatusCode != 200 || len(rates.Data) != 1 || rates.Data[0].Id == "" {
+		t.Fatalf("Rate upload should have status 200: is %d with %+v", res.StatusCode, rates.Data)
+	}
+	eurRate := rates.Data[0]
+	res = requestAsUser(ts, t, "POST", "/v1/api/fx-rates/import", "",
+		[]byte("base_currency,quote_currency,rate,effective_at\nUSD,GBP,0.8,\n"))
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Errorf("Rate file import should have status 200: is %d", res.StatusCode)
+	}
+	for _, body := range []string{
+		`{"data": [{"base_currency": "GBP", "quote_currency": "GBP", "rate": 1}]}`,
+		`{"data": [{"base_currency": "GBP", "quote_currency": "EUR", "rate": 0}]}`,
+	} {
+		res = requestAsUser(ts, t, "POST", "/v1/api/fx-rates", "", []byte(body))
+		res.Body.Close()
+		if res.StatusCode != 400 {
+			t.Errorf("Rate upload %s should have status 400: is %d", body, res.StatusCode)
+		}
+	}
+
+	// Without a quote the current rate applies
+	converted := newPayment(func(attributes *types.PaymentAttributes) {
+		attributes.Amount = 100
+		attributes.Currency = "GBP"
+		attributes.SettlementCurrency = "EUR"
+		attributes.EndToEndReference = "fx-rate"
+	})
+	res = createPayment(ts, t, createPaymentBody(t, converted))
+	payment := parsePayment(res)
+	res.Body.Close()
+	if payment.Attributes == nil || payment.Attributes.SettlementAmount != 115 || payment.Attributes.InstructedAmount != 100 ||
+		payment.Fx == nil || payment.Fx.RateId != eurRate.Id || payment.Fx.Rate != 1.15 {
+		t.Errorf("Payment should settle 115 EUR at rate %s: is %+v with %+v", eurRate.Id, payment.Attributes, payment.Fx)
+	}
+
+	// Updates keep the applied rate while the currencies do not change
+	res = requestAsUser(ts, t, "POST", "/v1/api/fx-rates", "",
+		[]byte(`{"data": [{"base_currency": "GBP", "quote_currency": "EUR", "rate": 1.2}]}`))
+	res.Body.Close()
+	converted.Attributes.Amount = 200
+	res = updatePayment(ts, t, payment.Id, createPaymentBody(t, converted))
+	updated := parsePayment(res)
+	res.Body.Close()
+	if updated.Attributes == nil || updated.Attributes.SettlementAmount != 230 || updated.Fx == nil || updated.Fx.RateId != eurRate.Id {
+		t.Errorf("Updated payment should settle 230 EUR at rate %s: is %+v with %+v", eurRate.Id, updated.Attributes, updated.Fx)
+	}
+
+	// GBP to USD is the inverse of the imported USD to GBP rate
+	res = requestAsUser(ts, t, "POST", "/v1/api/quotes", "",
+		[]byte(`{"instructed_currency": "GBP", "instructed_amount": 100, "settlement_currency": "USD"}`))
+	var quote types.FxQuote
+	json.NewDecoder(res.Body).Decode(&quote)
+	res.Body.Close()
+	if res.StatusCode != 200 || quote.SettlementAmount != 125 || !quote.ExpiresAt.After(time.Now()) {
+		t.Fatalf("Quote should settle 125 USD until it expires: status %d with %+v", res.StatusCode, quote)
+	}
+	res, err := http.Get(ts.URL + "/v1/api/quotes/" + quote.Id)
+	if err != nil {
+		t.Fatalf("Failed to get quote: %s", err.Error())
+	}
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Errorf("Quote should be found: status is %d", res.StatusCode)
+	}
+
+	converted.Attributes.SettlementCurrency = "USD"
+	converted.Attributes.QuoteId = quote.Id
+	for _, step := range []struct {
+		amount    float64
+		reference string
+		status    int
+	}{
+		{50, "fx-quote-amount", 400},
+		{100, "fx-quote", 200},
+		{100, "fx-quote-again", 400},
+	} {
+		converted.Attributes.Amount = step.amount
+		converted.Attributes.EndToEndReference = step.reference
+		res = createPayment(ts, t, createPaymentBody(t, converted))
+		if res.StatusCode != step.status {
+			t.Errorf("Payment of %f with quote should have status %d: is %d", step.amount, step.status, res.StatusCode)
+		} else if step.status == 200 {
+			payment = parsePayment(res)
+			if payment.Attributes.SettlementAmount != 125 || payment.Fx == nil || payment.Fx.QuoteId != quote.Id ||
+				payment.Fx.RateId != quote.RateId {
+				t.Errorf("Payment should settle 125 USD at quote %s: is %+v with %+v", quote.Id, payment.Attributes, payment.Fx)
+			}
+			res.Body.Close()
+			res, err = http.Get(ts.URL + "/v1/api/quotes/" + quote.Id)
+			if err != nil {
+				t.Fatalf("Failed to get quote: %s", err.Error())
+			}
+			var used types.FxQuote
+			json.NewDecoder(res.Body).Decode(&used)
+			if used.PaymentId != payment.Id {
+				t.Errorf("Quote should be used by payment %s: is used by %s", payment.Id, used.PaymentId)
+			}
+		}
+		res.Body.Close()
+	}
+
+	for _, body := range []string{
+		`{"instructed_currency": "GBP", "instructed_amount": 100, "settlement_currency": "XTS"}`,
+		`{"instructed_currency": "GBP", "instructed_amount": 100, "settlement_currency": "GBP"}`,
+		`{"instructed_currency": "GBP", "settlement_currency": "USD"}`,
+	} {
+		res = requestAsUser(ts, t, "POST", "/v1/api/quotes", "", []byte(body))
+		res.Body.Close()
+		if res.StatusCode != 400 {
+			t.Errorf("Quote %s should have status 400: is %d", body, res.StatusCode)
+		}
+	}
+	res, err = http.Get(ts.URL + "/v1/api/quotes/invalid")
+	if err != nil {
+		t.Fatalf("Failed to get quote: %s", err.Error())
+	}
+	res.Body.Close()
+	if res.StatusCode != 404 {
+		t.Errorf("Missing quote should have status 404: is %d", res.StatusCode)
+	}
+}
+
+func TestPaymentCharges(t *testing.T) {
+	ts := httptest.NewServer(getPaymentApi(TestConfig))
+	defer ts.Close()
+	deleteAllPayments(ts, t)
+
+	for _, step := range []struct {
+		body     string
+		status   int
+		bearer   string
+		sender   float64
+		receiver float64
+	}{
+		{`{"organisation_id": "test-fees", "attributes": {"amount": 100}}`, 200, "SHAR", 0.5, 0},
+		{`{"organisation_id": "test-fees", "attributes": {"amount": 1000, "payment_scheme": "SWIFT"}}`, 200, "DEBT", 10, 0},
+		{`{"organisation_id": "test-fees", "attributes": {"amount": 100000, "payment_scheme": "SWIFT"}}`, 200, "DEBT", 50, 0},
+		{`{"organisation_id": "test-fees", "attributes": {"amount": 20000, "payment_scheme": "SWIFT",
+			"charges_information": {"bearer_code": "CRED"}}}`, 200, "CRED", 0, 25},
+		{`{"organisation_id": "test-fees", "attributes": {"amount": 500, "payment_scheme": "BACS"}}`, 200, "SHAR", 0.2, 0},
+		{`{"organisation_id": "test-fees", "attributes": {"amount": 10000, "payment_scheme": "BACS"}}`, 200, "SHAR", 5, 0},
+		{`{"organisation_id": "test", "attributes": {"amount": 100}}`, 200, "", 0, 0},
+		{`{"organisation_id": "test", "attributes": {"amount": 100, "charges_information": {"bearer_code": "SHAR",
+			"sender_charges": [{"amount": 7, "currency": "GBP"}], "receiver_charges_amount": 3}}}`, 200, "SHAR", 0, 0},
+		{`{"organisation_id": "test-fees", "attributes": {"amount": 100, "charges_information": {"bearer_code": "OUR"}}}`, 400, "", 0, 0},
+		{`{"organisation_id": "test-fees"}`, 400, "", 0, 0},
+	} {
+		res := requestAsUser(ts, t, "POST", "/v1/api/payments/fee-estimate", "", []byte(step.body))
+		if res.StatusCode != step.status {
+			t.Errorf("Fee estimate %s should have status %d: is %d", step.body, step.status, res.StatusCode)
+		} else if step.status == 200 {
+			var charges types.ChargesInformation
+			json.NewDecoder(res.Body).Decode(&charges)
+			sender := 0.0
+			if len(charges.SenderCharges) > 0 {
+				sender = charges.SenderCharges[0].Amount
+			}
+			if charges.BearerCode != step.bearer || sender != step.sender || charges.ReceiverChargesAmount != step.receiver {
+				t.Errorf("Fee estimate %s should be borne by %s with sender %f and receiver %f: is %+v",
+					step.body, step.bearer, step.sender, step.receiver, charges)
+			}
+		}
+		res.Body.Close()
+	}
+
+	res := getPayments(ts, t)
+	payments := parsePayments(res)
+	res.Body.Close()
+	if len(payments.Data) != 0 {
+		t.Errorf("Fee estimates should not create payments: found %d", len(payments.Data))
+	}
+
+	payment := newPayment(nil)
+	payment.OrganisationId = "test-fees"
+	res = createPayment(ts, t, createPaymentBody(t, payment))
+	created := parsePayment(res)
+	res.Body.Close()
+	res = getPayment(ts, t, created.Id)
+	fetched := parsePayment(res)
+	res.Body.Close()
+	charges := fetched.Attributes.ChargesInformation
+	if charges == nil || charges.BearerCode != types.BearerShared || len(charges.SenderCharges) != 1 ||
+		charges.SenderCharges[0].Amount != 0.5 {
+		t.Errorf("Created payment should be charged 0.5 to its sender: is %+v", charges)
+	}
+
+	// Charges posted for an organisation without pricing are not booked
+	payment = newPayment(func(attributes *types.PaymentAttributes) {
+		attributes.EndToEndReference = "charges-posted"
+		attributes.ChargesInformation = &types.ChargesInformation{
+			BearerCode:    types.BearerShared,
+			SenderCharges: []*types.Charge{{Amount: 7, Currency: "GBP"}},
+		}
+	})
+	res = createPayment(ts, t, createPaymentBody(t, payment))
+	created = parsePayment(res)
+	res.Body.Close()
+	charges = created.Attributes.ChargesInformation
+	if charges == nil || charges.BearerCode != types.BearerShared || len(charges.SenderCharges) != 0 {
+		t.Errorf("Created payment should only keep its bearer: is %+v", charges)
+	}
+}
+
+func TestLedger(t *testing.T) {
+	ts := httptest.NewServer(getPaymentApi(TestConfig))
+	defer ts.Close()
+	deleteAllPayments(ts, t)
+
+	// Entries outlive deleted payments, so accounts are unique per run
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 10)
+	payment := newPayment(func(attributes *types.PaymentAttributes) {
+		attributes.Currency = "GBP"
+		attributes.Amount = 100
+		attributes.DebtorParty = &types.PaymentParty{BankId: "400300", BankIdCode: "GBDSC", AccountNumber: "D" + suffix}
+		attributes.BeneficiaryParty = &types.PaymentParty{BankId: "403000", BankIdCode: "GBDSC", AccountNumber: "B" + suffix}
+	})
+	debtor := "test:400300:D" + suffix
+	beneficiary := "test:403000:B" + suffix
+
+	res := requestAsUser(ts, t, "GET", "/v1/api/accounts/"+debtor+"/balance", "", nil)
+	res.Body.Close()
+	if res.StatusCode != 404 {
+		t.Errorf("Balance of account without entries should have status 404: is %d", res.StatusCode)
+	}
+
+	res = createPayment(ts, t, createPaymentBody(t, payment))
+	created := parsePayment(res)
+	res.Body.Close()
+	checkBalance(ts, t, debtor, -100)
+	checkBalance(ts, t, beneficiary, 100)
+
+	// The same account details of another organisation are another account
+	payment.OrganisationId = "test-import"
+	res = createPayment(ts, t, createPaymentBody(t, payment))
+	res.Body.Close()
+	checkBalance(ts, t, debtor, -100)
+	checkBalance(ts, t, "test-import:400300:D"+suffix, -100)
+
+	res = requestAsUser(ts, t, "GET", "/v1/api/accounts/"+debtor+"/entries?currency=GBP", "", nil)
+	var entries types.LedgerEntries
+	json.NewDecoder(res.Body).Decode(&entries)
+	res.Body.Close()
+	if len(entries.Data) != 1 || entries.Data[0].Direction != types.Debit || entries.Data[0].PaymentId != created.Id {
+		t.Errorf("Debtor should have one debit entry of payment %s: is %+v", created.Id, entries.Data)
+	}
+
+	res = requestAsUser(ts, t, "POST", "/v1/api/payments/"+created.Id+"/cancellation", "operator", []byte(`{"reason_code": "DUPL"}`))
+	res.Body.Close()
+	checkBalance(ts, t, debtor, 0)
+	checkBalance(ts, t, beneficiary, 0)
+
+	res = requestAsUser(ts, t, "GET", "/v1/api/accounts/"+debtor+"/entries", "", nil)
+	json.NewDecoder(res.Body).Decode(&entries)
+	res.Body.Close()
+	if len(entries.Data) != 2 || entries.Data[0].Direction != types.Credit || entries.Data[0].EventType != types.PaymentCancellation {
+		t.Errorf("Cancellation should reverse the debit of the debtor: entries are %+v", entries.Data)
+	}
+
+	if err := getLedgerService(TestConfig).CheckInvariant(); err != nil {
+		t.Errorf("Ledger should be balanced: %s", err.Error())
+	}
+}
+
+func checkBalance(ts *httptest.Server, t *testing.T, accountId string, expected float64) {
+	res := requestAsUser(ts, t, "GET", "/v1/api/accounts/"+accountId+"/balance", "", nil)
+	defer res.Body.Close()
+	var balance types.AccountBalance
+	json.NewDecoder(res.Body).Decode(&balance)
+	if len(balance.Balances) != 1 || balance.Balances[0].Currency != "GBP" || balance.Balances[0].Balance != expected {
+		t.Errorf("Account %s should have a GBP balance of %f: is %+v", accountId, expected, balance.Balances)
+	}
+}
+
+func TestAccounts(t *testing.T) {
+	ts := httptest.NewServer(getPaymentApi(TestConfig))
+	defer ts.Close()
+	deleteAllPayments(ts, t)
+
+	res := requestAsUser(ts, t, "POST", "/v1/api/accounts", "", []byte(`{"organisation_id": "test", "bank_id": "400300"}`))
+	res.Body.Close()
+	if res.StatusCode != 400 {
+		t.Errorf("Account without details should have status 400: is %d", res.StatusCode)
+	}
+
+	// Balances outlive deleted accounts, so account numbers are unique per run
+	suffix := strconv.FormatInt(time.Now().UnixNano()%100000000, 10)
+	accounts := make([]*types.Account, 0, 2)
+	for _, body := range []string{
+		`{"organisation_id": "test", "bank_id": "400300", "bank_id_code": "GBDSC",
+			"account_number": "D` + suffix + `", "account_number_code": "BBAN", "name": "Debtor"}`,
+		`{"organisation_id": "test", "bank_id": "403000", "bank_id_code": "GBDSC",
+			"account_number": "B` + suffix + `", "account_number_code": "BBAN", "name": "Beneficiary"}`,
+	} {
+		res = requestAsUser(ts, t, "POST", "/v1/api/accounts", "", []byte(body))
+		var account types.Account
+		json.NewDecoder(res.Body).Decode(&account)
+		res.Body.Close()
+		if res.StatusCode != 200 || account.Id == "" {
+			t.Fatalf("Account %s should be created: status is %d", body, res.StatusCode)
+		}
+		accounts = append(accounts, &account)
+	}
+	debtor, beneficiary := accounts[0], accounts[1]
+
+	res = requestAsUser(ts, t, "GET", "/v1/api/accounts/"+debtor.Id+"/balance", "", nil)
+	var balance types.AccountBalance
+	json.NewDecoder(res.Body).Decode(&balance)
+	res.Body.Close()
+	if res.StatusCode != 200 || len(balance.Balances) != 0 {
+		t.Errorf("Saved account without entries should have no balances: status is %d with %+v", res.StatusCode, balance.Balances)
+	}
+
+	payment := newPayment(func(attributes *types.PaymentAttributes) {
+		attributes.Currency = "GBP"
+		attributes.DebtorParty = nil
+		attributes.BeneficiaryParty = nil
+		attributes.DebtorAccountId = debtor.Id
+		attributes.BeneficiaryAccountId = beneficiary.Id
+	})
+	res = createPayment(ts, t, createPaymentBody(t, payment))
+	created := parsePayment(res)
+	res.Body.Close()
+	if res.StatusCode != 200 || created.Attributes.DebtorParty == nil || created.Attributes.DebtorParty.Name != "Debtor" ||
+		created.Attributes.BeneficiaryParty == nil || created.Attributes.BeneficiaryParty.AccountNumber != "B"+suffix {
+		t.Fatalf("Payment should snapshot the referenced accounts: status is %d with %+v", res.StatusCode, created.Attributes)
+	}
+
+	// Balances stay with the saved account when its number changes
+	res = requestAsUser(ts, t, "PUT", "/v1/api/accounts/"+debtor.Id, "", []byte(`{"organisation_id": "test", "bank_id": "400300",
+		"bank_id_code": "GBDSC", "account_number": "E`+suffix+`", "account_number_code": "BBAN", "name": "Renamed"}`))
+	var updated types.Account
+	json.NewDecoder(res.Body).Decode(&updated)
+	res.Body.Close()
+	if updated.Name != "Renamed" || updated.Version != 1 {
+		t.Errorf("Account should be renamed at version 1: is %s at %d", updated.Name, updated.Version)
+	}
+	res = getPayment(ts, t, created.Id)
+	fetched := parsePayment(res)
+	res.Body.Close()
+	if fetched.Attributes.DebtorParty.Name != "Debtor" {
+		t.Errorf("Payment should keep the account details it was created with: debtor is %s", fetched.Attributes.DebtorParty.Name)
+	}
+	checkBalance(ts, t, debtor.Id, -payment.Attributes.Amount)
+
+	// Scheme constraints apply to the parties of referenced accounts, SEPA
+	// needs IBAN accounts
+	sepa := *payment.Attributes
+	sepa.PaymentScheme = "SEPA"
+	sepa.Currency = "EUR"
+	sepa.EndToEndReference = "test-sepa-account"
+	for _, write := range []func([]byte) *http.Response{
+		func(body []byte) *http.Response { return createPayment(ts, t, body) },
+		func(body []byte) *http.Response { return updatePayment(ts, t, created.Id, body) },
+	} {
+		res = write(createPaymentBody(t, &types.Payment{Type: "Payment", OrganisationId: "test", Attributes: &sepa}))
+		httpError := parseHttpError(res)
+		res.Body.Close()
+		invalidParty := false
+		for _, fieldErr := range httpError.Errors {
+			invalidParty = invalidParty || strings.HasPrefix(fieldErr.Field, "attributes.debtor_party")
+		}
+		if res.StatusCode != 400 || !invalidParty {
+			t.Errorf("SEPA payment referencing a BBAN account should fail on attributes.debtor_party: status is %d with %+v", res.StatusCode, httpError.Errors)
+		}
+	}
+
+	payment.Attributes.EndToEndReference = "test-unknown-account"
+	payment.Attributes.DebtorAccountId = "unknown"
+	res = createPayment(ts, t, createPaymentBody(t, payment))
+	httpError := parseHttpError(res)
+	res.Body.Close()
+	if res.StatusCode != 400 || len(httpError.Errors) != 1 || httpError.Errors[0].Field != "attributes.debtor_account_id" {
+		t.Errorf("Payment referencing an unknown account should fail on attributes.debtor_account_id: status is %d", res.StatusCode)
+	}
+	payment.Attributes.DebtorAccountId = ""
+	res = createPayment(ts, t, createPaymentBody(t, payment))
+	res.Body.Close()
+	if res.StatusCode != 400 {
+		t.Errorf("Payment without debtor party or account should have status 400: is %d", res.StatusCode)
+	}
+
+	res = requestAsUser(ts, t, "GET", "/v1/api/accounts?organisation_id=test", "", nil)
+	var listed types.Accounts
+	json.NewDecoder(res.Body).Decode(&listed)
+	res.Body.Close()
+	found := false
+	for _, account := range listed.Data {
+		found = found || account.Id == beneficiary.Id
+	}
+	if !found {
+		t.Errorf("Accounts of organisation test should list account %s", beneficiary.Id)
+	}
+
+	for _, account := range accounts {
+		res = requestAsUser(ts, t, "DELETE", "/v1/api/accounts/"+account.Id, "", nil)
+		res.Body.Close()
+		if res.StatusCode != 200 {
+			t.Errorf("Account %s should be deleted: status is %d", account.Id, res.StatusCode)
+		}
+	}
+	res = requestAsUser(ts, t, "GET", "/v1/api/accounts/"+debtor.Id, "", nil)
+	res.Body.Close()
+	if res.StatusCode != 404 {
+		t.Errorf("Deleted account should have status 404: is %d", res.StatusCode)
+	}
+}
+
+func TestBeneficiaries(t *testing.T) {
+	ts := httptest.NewServer(getPaymentApi(TestConfig))
+	defer ts.Close()
+	deleteAllPayments(ts, t)
+
+	res := requestAsUser(ts, t, "GET", "/v1/api/beneficiaries", "", nil)
+	res.Body.Close()
+	if res.StatusCode != 400 {
+		t.Errorf("Beneficiaries without organisation_id should have status 400: is %d", res.StatusCode)
+	}
+
+	// Beneficiaries are kept across runs, so names are unique per run
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 10)
+	body := []byte(`{"organisation_id": "test", "name": "Acme Supplies ` + suffix + `",
+		"party": {"bank_id": "403000", "bank_id_code": "GBDSC", "name": "Acme Supplies Ltd"}}`)
+	res = requestAsUser(ts, t, "POST", "/v1/api/beneficiaries", "", body)
+	res.Body.Close()
+	if res.StatusCode != 400 {
+		t.Errorf("Beneficiary saved without a user should have status 400: is %d", res.StatusCode)
+	}
+	res = requestAsUser(ts, t, "POST", "/v1/api/beneficiaries", "alice", body)
+	beneficiary := parseBeneficiary(res)
+	res.Body.Close()
+	if beneficiary.Status != types.BeneficiaryUnverified || beneficiary.CreatedBy != "alice" {
+		t.Fatalf("Beneficiary should be saved unverified by alice: is %s by %s", beneficiary.Status, beneficiary.CreatedBy)
+	}
+	path := "/v1/api/beneficiaries/" + beneficiary.Id
+
+	payment := newPayment(func(attributes *types.PaymentAttributes) {
+		attributes.BeneficiaryParty = nil
+		attributes.BeneficiaryId = beneficiary.Id
+	})
+	payBeneficiary := func(reference string) *http.Response {
+		payment.Attributes.EndToEndReference = reference
+		return createPayment(ts, t, createPaymentBody(t, payment))
+	}
+
+	res = payBeneficiary("test-unverified")
+	httpError := parseHttpError(res)
+	res.Body.Close()
+	if res.StatusCode != 400 || len(httpError.Errors) != 1 || httpError.Errors[0].Field != "attributes.beneficiary_id" {
+		t.Errorf("Payment to an unverified beneficiary should fail on attributes.beneficiary_id: status is %d", res.StatusCode)
+	}
+
+	res = requestAsUser(ts, t, "POST", path+"/verification", "alice", nil)
+	res.Body.Close()
+	if res.StatusCode != 403 {
+		t.Errorf("Verification by the user that saved the beneficiary should have status 403: is %d", res.StatusCode)
+	}
+	res = requestAsUser(ts, t, "POST", path+"/verification", "bob", nil)
+	verified := parseBeneficiary(res)
+	res.Body.Close()
+	if verified.Status != types.BeneficiaryVerified || verified.VerifiedBy != "bob" {
+		t.Errorf("Beneficiary should be verified by bob: is %s by %s", verified.Status, verified.VerifiedBy)
+	}
+
+	res = payBeneficiary("test-verified")
+	created := parsePayment(res)
+	res.Body.Close()
+	if res.StatusCode != 200 || created.Attributes.BeneficiaryParty == nil || created.Attributes.BeneficiaryParty.Name != "Acme Supplies Ltd" {
+		t.Errorf("Payment should expand the beneficiary into its beneficiary party: status is %d", res.StatusCode)
+	}
+
+	res = requestAsUser(ts, t, "GET", "/v1/api/beneficiaries?organisation_id=test&name=acme+supplies+"+suffix, "", nil)
+	var found types.Beneficiaries
+	json.NewDecoder(res.Body).Decode(&found)
+	res.Body.Close()
+	if len(found.Data) != 1 || found.Data[0].Id != beneficiary.Id || found.Data[0].UsageCount != 1 || found.Data[0].LastUsedAt == nil {
+		t.Errorf("Name search should find the beneficiary used once: found %d", len(found.Data))
+	}
+
+	res = requestAsUser(ts, t, "PUT", path, "", bytes.Replace(body, []byte("Ltd"), []byte("Limited"), 1))
+	res.Body.Close()
+	if res.StatusCode != 400 {
+		t.Errorf("Beneficiary edited without a user should have status 400: is %d", res.StatusCode)
+	}
+	res = requestAsUser(ts, t, "PUT", path, "alice", bytes.Replace(body, []byte("Ltd"), []byte("Limited"), 1))
+	edited := parseBeneficiary(res)
+	res.Body.Close()
+	if edited.Status != types.BeneficiaryUnverified || edited.Party.Name != "Acme Supplies Limited" {
+		t.Errorf("Edited beneficiary should be unverified: is %s", edited.Status)
+	}
+	res = payBeneficiary("test-edited")
+	res.Body.Close()
+	if res.StatusCode != 400 {
+		t.Errorf("Payment to an edited beneficiary should have status 400 until verified again: is %d", res.StatusCode)
+	}
+
+	res = requestAsUser(ts, t, "DELETE", path, "", nil)
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Errorf("Beneficiary should be deleted: status is %d", res.StatusCode)
+	}
+	res = requestAsUser(ts, t, "GET", path, "", nil)
+	res.Body.Close()
+	if res.StatusCode != 404 {
+		t.Errorf("Deleted beneficiary should have status 404: is %d", res.StatusCode)
+	}
+}
+
+func TestUpdatePayment(t *testing.T) {
+	ts := httptest.NewServer(getPaymentApi(TestConfig))
+	defer ts.Close()
+	deleteAllPayments(ts, t)
+
+	res := updatePayment(ts, t, "invalid", createPaymentBody(t, validPaymentUpdate))
+	if res.StatusCode != 404 {
+		t.Errorf("Status code should be 404: is %d", res.StatusCode)
+	}
+
+	res = createPayment(ts, t, createPaymentBody(t, validPayment))
+	payment := parsePayment(res)
+	res.Body.Close()
+
+	res = updatePayment(ts, t, payment.Id, createPaymentBody(t, validPaymentUpdate))
+	payment = parsePayment(res)
+
+	if payment.Attributes.Amount != validPaymentUpdate.Attributes.Amount {
+		t.Errorf("Payment amount should be %f: is %f", validPaymentUpdate.Attributes.Amount, payment.Attributes.Amount)
+	}
+
+	res = updatePayment(ts, t, payment.Id, createPaymentBody(t, invalidPaymentUpdate))
+	if res.StatusCode != 400 {
+		t.Errorf("Status code should be 400: is %d", res.StatusCode)
+	}
+
+}
+
+func TestDeletePayment(t *testing.T) {
+	ts := httptest.NewServer(getPaymentApi(TestConfig))
+	defer ts.Close()
+	deleteAllPayments(ts, t)
+
+	res := getPayments(ts, t)
+	payments := parsePayments(res)
+	res.Body.Close()
+	if len(payments.Data) > 0 {
+		t.Errorf("Payment list size should be 0: is %d", len(payments.Data))
+	}
+
+	res = createPayment(ts, t, createPaymentBody(t, validPayment))
+	payment := parsePayment(res)
+	res.Body.Close()
+
+	res = getPayments(ts, t)
+	payments = parsePayments(res)
+	res.Body.Close()
+	if len(payments.Data) != 1 {
+		t.Errorf("Payment list size should be 1: is %d", len(payments.Data))
+	}
+
+	res = deletePayment(ts, t, "invalid")
+	if res.StatusCode != 404 {
+		t.Errorf("Status code should be 404: is %d", res.StatusCode)
+	}
+
+	res = deletePayment(ts, t, payment.Id)
+	res = getPayments(ts, t)
+	payments = parsePayments(res)
+	res.Body.Close()
+	if len(payments.Data) > 0 {
+		t.Errorf("Payment list size should be 0: is %d", len(payments.Data))
+	}
+}
+
+func TestGetPayment(t *testing.T) {
+	ts := httptest.NewServer(getPaymentApi(TestConfig))
+	defer ts.Close()
+
+	deleteAllPayments(ts, t)
+	res := createPayment(ts, t, createPaymentBody(t, validPayment))
+	payment := parsePayment(res)
+	res.Body.Close()
+
+	res = getPayment(ts, t, payment.Id)
+	payment = parsePayment(res)
+
+	if payment.OrganisationId != validPayment.OrganisationId {
+		t.Errorf("Payment organisation id should be %s: is %s", validPayment.OrganisationId, payment.OrganisationId)
+	}
+
+	res = getPayment(ts, t, "invalid")
+	if res.StatusCode != 404 {
+		t.Errorf("Status code should be 404: is %d", res.StatusCode)
+	}
+
+}
+
+func TestGetPayments(t *testing.T) {
+	ts := httptest.NewServer(getPaymentApi(TestConfig))
+	defer ts.Close()
+
+	deleteAllPayments(ts, t)
+
+	res := getPayments(ts, t)
+	if res.StatusCode != 200 {
+		t.Errorf("Status should be 200: is %d", res.StatusCode)
+	}
+	payments := parsePayments(res)
+	res.Body.Close()
+
+	if len(payments.Data) > 0 {
+		t.Errorf("Payment list size should be 0: is %d", len(payments.Data))
+	}
+
+	res = createPayment(ts, t, createPaymentBody(t, validPayment))
+	res.Body.Close()
+
+	res = getPayments(ts, t)
+	if res.StatusCode != 200 {
+		t.Errorf("Status should be 200: is %d", res.StatusCode)
+	}
+	payments = parsePayments(res)
+	res.Body.Close()
+
+	if len(payments.Data) != 1 {
+		t.Errorf("Payment list size should be 1: is %d", len(payments.Data))
+	}
+}
+
+func TestEventRelay(t *testing.T) {
 	ts := httptest.NewServer(getPaymentApi(TestConfig))
 	defer ts.Close()
 	deleteAllPayments(ts, t)
+	drainEvents(t)
 
-	res := createPayment(ts, t, createPaymentBody(t, invalidPaymentAmout))
-	httpError := parseHttpError(res)
+	publisher := events.NewChannelPublisher(10)
+	relay := getEventRelay(TestConfig, publisher)
+
+	res := createPayment(ts, t, createPaymentBody(t, validPayment))
+	payment := parsePayment(res)
 	res.Body.Close()
-	if res.StatusCode != 400 {
-		t.Errorf("Status code should be 400: is %d", res.StatusCode)
+
+	published, err := relay.RelayPending()
+	if err != nil {
+		t.Errorf("Failed to relay events: %s", err.Error())
 	}
-	if len(httpError.Messages) == 0 {
-		t.Errorf("Http Error should have error messages")
+	if published != 1 {
+		t.Errorf("Published events should be 1: is %d", published)
+	}
+	event := <-publisher.Events
+	if event.Type != types.PaymentCreated || event.PaymentId != payment.Id {
+		t.Errorf("Event should be %s for %s: is %s for %s", types.PaymentCreated, payment.Id, event.Type, event.PaymentId)
 	}
 
-	res = createPayment(ts, t, createPaymentBody(t, invalidPaymentType))
+	published, _ = relay.RelayPending()
+	if published != 0 {
+		t.Errorf("Published events should be 0: is %d", published)
+	}
+
+	relay.Start()
+	relay.Stop()
+	relay.Stop()
+}
+
+func TestStreamPayments(t *testing.T) {
+	testStreamPayments(t, TestConfig)
+}
+
+func TestPollingStreamPayments(t *testing.T) {
+	config := *TestConfig
+	config.EventStream = PollingStream
+	config.PollInterval = 100 * time.Millisecond
+	testStreamPayments(t, &config)
+}
+
+func testStreamPayments(t *testing.T, config *ConfigProperties) {
+	ts := httptest.NewServer(getPaymentApi(config))
+	defer ts.Close()
+	deleteAllPayments(ts, t)
+
+	res := openStream(ts, t, "", "")
 	res.Body.Close()
 	if res.StatusCode != 400 {
-		t.Errorf("Status code should be 400: is %d", res.StatusCode)
+		t.Errorf("Status without organisation should be 400: is %d", res.StatusCode)
 	}
 
-	res = createPayment(ts, t, createPaymentBody(t, validPayment))
-	payment := parsePayment(res)
+	res = openStream(ts, t, validPayment.OrganisationId, "not-a-cursor")
 	res.Body.Close()
-	if payment.Id == "" {
-		t.Error("Created payment should have id")
+	if res.StatusCode != 400 {
+		t.Errorf("Status with malformed Last-Event-ID should be 400: is %d", res.StatusCode)
 	}
 
-	res = getPayment(ts, t, payment.Id)
-	payment = parsePayment(res)
+	res = openStream(ts, t, validPayment.OrganisationId, "")
 	if res.StatusCode != 200 {
-		t.Errorf("Status code should be 200: is %d", res.StatusCode)
+		t.Errorf("Status should be 200: is %d", res.StatusCode)
+	}
+	created := createPayment(ts, t, createPaymentBody(t, validPayment))
+	payment := parsePayment(created)
+	created.Body.Close()
+
+	event, cursor := readStreamEvent(t, bufio.NewReader(res.Body), payment.Id)
+	res.Body.Close()
+	if event.Type != types.PaymentCreated {
+		t.Errorf("Event type should be %s: is %s", types.PaymentCreated, event.Type)
+	}
+
+	// Events written while disconnected are sent on reconnection
+	resumed := newPayment(func(attributes *types.PaymentAttributes) {
+		attributes.EndToEndReference = "stream1"
+	})
+	created = createPayment(ts, t, createPaymentBody(t, resumed))
+	payment = parsePayment(created)
+	created.Body.Close()
+
+	res = openStream(ts, t, validPayment.OrganisationId, cursor)
+	defer res.Body.Close()
+	event, _ = readStreamEvent(t, bufio.NewReader(res.Body), payment.Id)
+	if event.Type != types.PaymentCreated {
+		t.Errorf("Resumed event type should be %s: is %s", types.PaymentCreated, event.Type)
 	}
 }
 
-func TestUpdatePayment(t *testing.T) {
+// Open the payment event stream of an organisation, after lastEventId if not
+// empty. Reads fail rather than block when the expected event never comes.
+func openStream(ts *httptest.Server, t *testing.T, organisationId string, lastEventId string) *http.Response {
+	req, err := http.NewRequest("GET", ts.URL+"/v1/api/payments/stream?organisation_id="+organisationId, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if lastEventId != "" {
+		req.Header.Set("Last-Event-ID", lastEventId)
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return res
+}
+
+// Read stream events until one of the payment, return it with its id
+func readStreamEvent(t *testing.T, reader *bufio.Reader, paymentId string) (*types.PaymentEvent, string) {
+	var event types.PaymentEvent
+	var id string
+	for event.PaymentId != paymentId {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read event stream: %s", err.Error())
+		}
+		if strings.HasPrefix(line, "id: ") {
+			id = strings.TrimSpace(strings.TrimPrefix(line, "id: "))
+		} else if strings.HasPrefix(line, "data: ") {
+			json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event)
+		}
+	}
+	return &event, id
+}
+
+func TestOpenApiSpec(t *testing.T) {
+	validator, err := api.NewPaymentValidator("v1", nil)
+	if err != nil {
+		t.Fatalf("Failed to compile schemas: %s", err.Error())
+	}
+	router := api.NewApiRouter(&api.Services{}, validator)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/v1/openapi.json")
+	if err != nil {
+		log.Fatal(err)
+	}
+	var spec struct {
+		Paths map[string]map[string]interface{} `json:"paths"`
+	}
+	json.NewDecoder(res.Body).Decode(&spec)
+	res.Body.Close()
+
+	for _, asset := range []string{"swagger-ui.css", "swagger-ui-bundle.js"} {
+		res, err = http.Get(ts.URL + "/v1/swagger-ui/" + asset)
+		if err != nil {
+			log.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != 200 {
+			t.Errorf("Docs asset %s status should be 200: is %d", asset, res.StatusCode)
+		}
+	}
+
+	walkFunc := func(method string, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		// Mounted sub routers are walked as "/*/"
+		path := strings.TrimSuffix(strings.Replace(route, "/*/", "/", -1), "/")
+		if !strings.HasPrefix(path, "/v1/api/") {
+			return nil
+		}
+		if _, ok := spec.Paths[path][strings.ToLower(method)]; !ok {
+			t.Errorf("Route %s %s is missing from the OpenAPI spec", method, path)
+		}
+		return nil
+	}
+	chi.Walk(router, walkFunc)
+}
+
+func TestGetPaymentsFilter(t *testing.T) {
 	ts := httptest.NewServer(getPaymentApi(TestConfig))
 	defer ts.Close()
 	deleteAllPayments(ts, t)
 
-	res := updatePayment(ts, t, "invalid", createPaymentBody(t, validPaymentUpdate))
-	if res.StatusCode != 404 {
-		t.Errorf("Status code should be 404: is %d", res.StatusCode)
+	// Account type 0 is a value rather than a missing field
+	accountType := 0
+	payment := newPayment(func(attributes *types.PaymentAttributes) {
+		attributes.DebtorParty.AccountType = &accountType
+	})
+	res := createPayment(ts, t, createPaymentBody(t, payment))
+	res.Body.Close()
+
+	res = getPaymentsQuery(ts, t, "?debtor_party.account_number=GB29NWBK60161331926819&debtor_party.country=GB")
+	payments := parsePayments(res)
+	res.Body.Close()
+	if len(payments.Data) != 1 {
+		t.Fatalf("Payment list size should be 1: is %d", len(payments.Data))
+	}
+	debtor := payments.Data[0].Attributes.DebtorParty
+	if debtor.AccountName != "account2" || len(debtor.Address) != 2 || debtor.AccountType == nil || *debtor.AccountType != 0 {
+		t.Errorf("Debtor party should keep account name, type and address: is %+v", debtor)
 	}
 
-	res = createPayment(ts, t, createPaymentBody(t, validPayment))
-	payment := parsePayment(res)
+	res = getPaymentsQuery(ts, t, "?beneficiary_party.country=FR")
+	payments = parsePayments(res)
 	res.Body.Close()
+	if len(payments.Data) != 0 {
+		t.Errorf("Payment list size should be 0: is %d", len(payments.Data))
+	}
+}
 
-	res = updatePayment(ts, t, payment.Id, createPaymentBody(t, validPaymentUpdate))
-	payment = parsePayment(res)
+func TestExportPain001(t *testing.T) {
+	ts := httptest.NewServer(getPaymentApi(TestConfig))
+	defer ts.Close()
+	deleteAllPayments(ts, t)
 
-	if payment.Attributes.Amount != validPaymentUpdate.Attributes.Amount {
-		t.Errorf("Payment amount should be %f: is %f", validPaymentUpdate.Attributes.Amount, payment.Attributes.Amount)
+	ids := make([]string, 0)
+	for i, debtorName := range []string{"debtor1", "debtor1", "debtor2"} {
+		payment := newPayment(func(attributes *types.PaymentAttributes) {
+			attributes.EndToEndReference = "export" + strconv.Itoa(i)
+			attributes.DebtorParty.Name = debtorName
+			attributes.Currency = "GBP"
+			attributes.Amount = 10.1
+		})
+		res := createPayment(ts, t, createPaymentBody(t, payment))
+		ids = append(ids, parsePayment(res).Id)
+		res.Body.Close()
 	}
 
-	res = updatePayment(ts, t, payment.Id, createPaymentBody(t, invalidPaymentUpdate))
-	if res.StatusCode != 400 {
-		t.Errorf("Status code should be 400: is %d", res.StatusCode)
+	res := getPaymentsQuery(ts, t, "/export/pain.001?id="+strings.Join(ids, "&id="))
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Fatalf("Status code should be 200: is %d", res.StatusCode)
+	}
+	var document iso20022.Pain001Document
+	if err := xml.Unmarshal(body, &document); err != nil {
+		t.Fatalf("Failed to parse pain.001: %s", err.Error())
+	}
+	header := document.Initiate.GroupHeader
+	if header.NumberOfTransactions != 3 || header.ControlSum != "30.30" {
+		t.Errorf("Group header should have 3 transactions summing 30.30: is %d and %s", header.NumberOfTransactions, header.ControlSum)
+	}
+	if len(document.Initiate.PaymentInformation) != 2 {
+		t.Errorf("Payments should be grouped in 2 PmtInf blocks: is %d", len(document.Initiate.PaymentInformation))
+	}
+	if document.Xmlns != iso20022.Pain001Namespace {
+		t.Errorf("Namespace should be %s: is %s", iso20022.Pain001Namespace, document.Xmlns)
+	}
+	for _, information := range document.Initiate.PaymentInformation {
+		count := len(information.CreditTransferDetails)
+		sum := 0.0
+		for _, transaction := range information.CreditTransferDetails {
+			amount, _ := strconv.ParseFloat(transaction.Amount.Instructed.Value, 64)
+			sum += amount
+		}
+		controlSum, _ := strconv.ParseFloat(information.ControlSum, 64)
+		if information.NumberOfTransactions != count || math.Abs(controlSum-sum) > 0.001 {
+			t.Errorf("PmtInf %s should have %d transactions summing %.2f: is %d and %s", information.PaymentInformationId,
+				count, sum, information.NumberOfTransactions, information.ControlSum)
+		}
 	}
 
+	// Element order the schema sequences require, checked without the XSD
+	checkElementOrder(t, body, "CstmrCdtTrfInitn", []string{"GrpHdr", "PmtInf"})
+	checkElementOrder(t, body, "GrpHdr", []string{"MsgId", "CreDtTm", "NbOfTxs", "CtrlSum", "InitgPty"})
+	checkElementOrder(t, body, "PmtInf", []string{"PmtInfId", "PmtMtd", "NbOfTxs", "CtrlSum", "ReqdExctnDt",
+		"Dbtr", "DbtrAcct", "DbtrAgt", "CdtTrfTxInf"})
+	checkElementOrder(t, body, "CdtTrfTxInf", []string{"PmtId", "Amt", "CdtrAgt", "Cdtr", "CdtrAcct", "Purp", "RmtInf"})
+	validateXml(t, body, "testdata/pain.001.001.09.xsd")
 }
 
-func TestDeletePayment(t *testing.T) {
+// Check the children of every element named parent follow the order of
+// expected, children may repeat or be left out
+func checkElementOrder(t *testing.T, document []byte, parent string, expected []string) {
+	decoder := xml.NewDecoder(bytes.NewReader(document))
+	depth, parentDepth, position := 0, 0, 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			t.Fatalf("Failed to read document: %s", err.Error())
+		}
+		switch element := token.(type) {
+		case xml.StartElement:
+			depth++
+			if parentDepth == 0 && element.Name.Local == parent {
+				parentDepth, position = depth, 0
+			} else if parentDepth != 0 && depth == parentDepth+1 {
+				for position < len(expected) && expected[position] != element.Name.Local {
+					position++
+				}
+				if position == len(expected) {
+					t.Errorf("%s element %s is unexpected or out of order, order should be %v", parent, element.Name.Local, expected)
+					return
+				}
+			}
+		case xml.EndElement:
+			if depth == parentDepth {
+				parentDepth = 0
+			}
+			depth--
+		}
+	}
+}
+
+func TestExportImportMT103(t *testing.T) {
 	ts := httptest.NewServer(getPaymentApi(TestConfig))
 	defer ts.Close()
 	deleteAllPayments(ts, t)
 
-	res := getPayments(ts, t)
-	payments := parsePayments(res)
+	payment := newPayment(func(attributes *types.PaymentAttributes) {
+		attributes.BeneficiaryParty.BankId = "DEUTDEFF500"
+		attributes.BeneficiaryParty.BankIdCode = "SWBIC"
+		attributes.BeneficiaryParty.Name = "Jürgen Müller"
+		attributes.Currency = "EUR"
+		attributes.Amount = 12.5
+		attributes.Reference = "Invoice 42"
+	})
+	res := createPayment(ts, t, createPaymentBody(t, payment))
+	created := parsePayment(res)
 	res.Body.Close()
-	if len(payments.Data) > 0 {
-		t.Errorf("Payment list size should be 0: is %d", len(payments.Data))
+
+	res = getPaymentsQuery(ts, t, "/export/mt103?id="+created.Id)
+	messages, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Fatalf("Status code should be 200: is %d", res.StatusCode)
+	}
+	for _, field := range []string{":32A:", "EUR12,5", ":57A:DEUTDEFF500", "Jurgen Muller", ":70:Invoice 42", ":71A:SHA"} {
+		if !strings.Contains(string(messages), field) {
+			t.Errorf("MT103 should contain %s: %s", field, messages)
+		}
 	}
 
-	res = createPayment(ts, t, createPaymentBody(t, validPayment))
-	payment := parsePayment(res)
+	res, err := http.Post(ts.URL+"/v1/api/payments/import/mt103?organisation_id=test-import", "text/plain", bytes.NewReader(messages))
+	if err != nil {
+		t.Fatalf("Failed to import MT103: %s", err.Error())
+	}
+	imported := parsePayments(res)
 	res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Fatalf("Status code should be 200: is %d", res.StatusCode)
+	}
+	if len(imported.Data) != 1 || imported.Data[0].Attributes.Amount != 12.5 || imported.Data[0].Attributes.Currency != "EUR" {
+		t.Errorf("Imported payment should have amount 12.5 EUR")
+	}
 
-	res = getPayments(ts, t)
-	payments = parsePayments(res)
+	// The second message reuses the imported reference, so the first is not
+	// created either
+	batch := strings.Replace(string(messages), ":20:test1", ":20:batch1", 1) + string(messages)
+	res, _ = http.Post(ts.URL+"/v1/api/payments/import/mt103?organisation_id=test-import", "text/plain", strings.NewReader(batch))
 	res.Body.Close()
-	if len(payments.Data) != 1 {
-		t.Errorf("Payment list size should be 1: is %d", len(payments.Data))
+	if res.StatusCode != 409 {
+		t.Errorf("Status code should be 409 for a reused reference: is %d", res.StatusCode)
+	}
+	res = getPaymentsQuery(ts, t, "?organisation_id=test-import")
+	stored := parsePayments(res)
+	res.Body.Close()
+	if len(stored.Data) != 1 {
+		t.Errorf("Failed import should not create payments: organisation has %d payments", len(stored.Data))
 	}
 
-	res = deletePayment(ts, t, "invalid")
-	if res.StatusCode != 404 {
-		t.Errorf("Status code should be 404: is %d", res.StatusCode)
+	// Charges of the SWIFT pricing rule are stated in 71F when the creditor
+	// bears them and prepaid in 71G when the debtor does
+	for _, step := range []struct {
+		bearer   string
+		field    string
+		sender   float64
+		receiver float64
+	}{
+		{types.BearerCreditor, ":71F:EUR10,", 0, 10},
+		{types.BearerDebtor, ":71G:EUR10,", 10, 0},
+	} {
+		charged := newPayment(func(attributes *types.PaymentAttributes) {
+			attributes.BeneficiaryParty.BankId = "DEUTDEFF500"
+			attributes.BeneficiaryParty.BankIdCode = "SWBIC"
+			attributes.Currency = "EUR"
+			attributes.PaymentScheme = "SWIFT"
+			attributes.EndToEndReference = "mt103-" + step.bearer
+			attributes.ChargesInformation = &types.ChargesInformation{BearerCode: step.bearer}
+		})
+		charged.OrganisationId = "test-fees"
+		res = createPayment(ts, t, createPaymentBody(t, charged))
+		created = parsePayment(res)
+		res.Body.Close()
+
+		res = getPaymentsQuery(ts, t, "/export/mt103?id="+created.Id)
+		message, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if !strings.Contains(string(message), step.field) {
+			t.Errorf("MT103 of bearer %s should contain %s: %s", step.bearer, step.field, message)
+		}
+		res, _ = http.Post(ts.URL+"/v1/api/payments/import/mt103?organisation_id=test-import", "text/plain", bytes.NewReader(message))
+		imported = parsePayments(res)
+		res.Body.Close()
+		if len(imported.Data) != 1 {
+			t.Fatalf("MT103 of bearer %s should be imported: status is %d", step.bearer, res.StatusCode)
+		}
+		charges := imported.Data[0].Attributes.ChargesInformation
+		sender := 0.0
+		if charges != nil && len(charges.SenderCharges) > 0 {
+			sender = charges.SenderCharges[0].Amount
+		}
+		if charges == nil || charges.BearerCode != step.bearer || sender != step.sender || charges.ReceiverChargesAmount != step.receiver {
+			t.Errorf("Imported charges of bearer %s should be %f sender and %f receiver: are %+v", step.bearer, step.sender, step.receiver, charges)
+		}
 	}
 
-	res = deletePayment(ts, t, payment.Id)
-	res = getPayments(ts, t)
-	payments = parsePayments(res)
+	res, _ = http.Post(ts.URL+"/v1/api/payments/import/mt103?organisation_id=test-import", "text/plain",
+		strings.NewReader(strings.Replace(string(messages), ":71A:SHA", ":71A:BEN", 1)))
 	res.Body.Close()
-	if len(payments.Data) > 0 {
-		t.Errorf("Payment list size should be 0: is %d", len(payments.Data))
+	if res.StatusCode != 400 {
+		t.Errorf("Status code should be 400 for details of charges BEN without 71F: is %d", res.StatusCode)
+	}
+
+	res, _ = http.Post(ts.URL+"/v1/api/payments/import/mt103?organisation_id=test", "text/plain", strings.NewReader(":20:REF\r\n:23B:CRED\r\n-"))
+	res.Body.Close()
+	if res.StatusCode != 400 {
+		t.Errorf("Status code should be 400 for an incomplete message: is %d", res.StatusCode)
 	}
 }
 
-func TestGetPayment(t *testing.T) {
+func TestExportBatchFiles(t *testing.T) {
 	ts := httptest.NewServer(getPaymentApi(TestConfig))
 	defer ts.Close()
-
 	deleteAllPayments(ts, t)
-	res := createPayment(ts, t, createPaymentBody(t, validPayment))
-	payment := parsePayment(res)
+
+	payment := newPayment(func(attributes *types.PaymentAttributes) {
+		attributes.BeneficiaryParty.AccountNumber = "12345678"
+		attributes.BeneficiaryParty.AccountNumberCode = "BBAN"
+		attributes.Currency = "GBP"
+		attributes.Reference = "Invoice 12345"
+	})
+	res := createPayment(ts, t, createPaymentBody(t, payment))
+	ukPayment := parsePayment(res)
 	res.Body.Close()
 
-	res = getPayment(ts, t, payment.Id)
-	payment = parsePayment(res)
+	res = getPaymentsQuery(ts, t, "/export/bacs?id="+ukPayment.Id)
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Fatalf("Status code should be 200: is %d", res.StatusCode)
+	}
+	records := strings.Split(strings.TrimSuffix(string(body), "\r\n"), "\r\n")
+	if len(records) != 9 || !strings.HasPrefix(records[0], "VOL1") || !strings.HasPrefix(records[8], "UTL1") {
+		t.Errorf("Standard 18 file should have labels, a detail and a contra record: %s", body)
+	}
 
-	if payment.OrganisationId != validPayment.OrganisationId {
-		t.Errorf("Payment organisation id should be %s: is %s", validPayment.OrganisationId, payment.OrganisationId)
+	res = getPaymentsQuery(ts, t, "/export/nacha?id="+ukPayment.Id)
+	res.Body.Close()
+	if res.StatusCode != 400 {
+		t.Errorf("Status code should be 400 for a payment to a UK account: is %d", res.StatusCode)
 	}
 
-	res = getPayment(ts, t, "invalid")
-	if res.StatusCode != 404 {
-		t.Errorf("Status code should be 404: is %d", res.StatusCode)
+	payment.Attributes.BeneficiaryParty.BankId = "021000021"
+	payment.Attributes.BeneficiaryParty.BankIdCode = "USABA"
+	payment.Attributes.Currency = "USD"
+	payment.Attributes.EndToEndReference = "test2"
+	res = createPayment(ts, t, createPaymentBody(t, payment))
+	usPayment := parsePayment(res)
+	res.Body.Close()
+
+	res = getPaymentsQuery(ts, t, "/export/nacha?id="+usPayment.Id)
+	body, _ = ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Fatalf("Status code should be 200: is %d", res.StatusCode)
+	}
+	records = strings.Split(strings.TrimSuffix(string(body), "\r\n"), "\r\n")
+	if len(records)%10 != 0 || !strings.HasPrefix(records[2], "6") || !strings.HasPrefix(records[4], "9") {
+		t.Fatalf("NACHA file should be padded to blocks of 10 records: %s", body)
 	}
 
+	// Entries are effective on the next USD business day unless processed later
+	usd, err := calendar.LoadCalendar("USD", TestConfig.CalendarDirectory+"/USD.txt")
+	if err != nil {
+		t.Fatalf("Failed to load USD calendar: %s", err.Error())
+	}
+	effectiveDate := usd.NextBusinessDay(time.Now()).Format("060102")
+	if processingDate, err := time.Parse("2006-01-02", usPayment.Attributes.ProcessingDate); err == nil &&
+		processingDate.Format("060102") > effectiveDate {
+		effectiveDate = processingDate.Format("060102")
+	}
+	if records[1][69:75] != effectiveDate {
+		t.Errorf("NACHA batch should be effective on %s: is %s", effectiveDate, records[1][69:75])
+	}
 }
 
-func TestGetPayments(t *testing.T) {
+const camt053Statement = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02">
+  <BkToCstmrStmt>
+    <Stmt>
+      <Id>STMT-1</Id>
+      <Acct><Id><IBAN>GB29NWBK60161331926819</IBAN></Id></Acct>
+      <Ntry>
+        <NtryRef>E1</NtryRef>
+        <Amt Ccy="GBP">12.34</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <BookgDt><Dt>2019-05-01</Dt></BookgDt>
+        <NtryDtls>
+          <TxDtls>
+            <Refs><EndToEndId>%s</EndToEndId></Refs>
+            <RltdPties><Cdtr><Nm>name</Nm></Cdtr></RltdPties>
+          </TxDtls>
+        </NtryDtls>
+      </Ntry>
+      <Ntry>
+        <NtryRef>E2</NtryRef>
+        <Amt Ccy="GBP">1.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <NtryDtls>
+          <TxDtls>
+            <Refs><EndToEndId>unknown</EndToEndId></Refs>
+          </TxDtls>
+        </NtryDtls>
+      </Ntry>
+    </Stmt>
+  </BkToCstmrStmt>
+</Document>`
+
+func TestReconcileStatement(t *testing.T) {
 	ts := httptest.NewServer(getPaymentApi(TestConfig))
 	defer ts.Close()
-
 	deleteAllPayments(ts, t)
 
-	res := getPayments(ts, t)
+	reference := "reconcile" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	payment := newPayment(func(attributes *types.PaymentAttributes) {
+		attributes.Amount = 12.34
+		attributes.Currency = "GBP"
+		attributes.EndToEndReference = reference
+	})
+	res := createPayment(ts, t, createPaymentBody(t, payment))
+	created := parsePayment(res)
+	res.Body.Close()
+
+	statement := fmt.Sprintf(camt053Statement, reference)
+
+	// Statements only match payments of the organisation reconciling them
+	var report types.ReconciliationReport
+	res, _ = http.Post(ts.URL+"/v1/api/reconciliations?organisation_id=other", "application/xml", strings.NewReader(statement))
+	json.NewDecoder(res.Body).Decode(&report)
+	res.Body.Close()
+	if len(report.Matched) != 0 {
+		t.Errorf("Payment of another organisation should not match: matched %d entries", len(report.Matched))
+	}
+	res, _ = http.Post(ts.URL+"/v1/api/reconciliations", "application/xml", strings.NewReader(statement))
+	res.Body.Close()
+	if res.StatusCode != 400 {
+		t.Errorf("Status code should be 400 without an organisation: is %d", res.StatusCode)
+	}
+
+	res, err := http.Post(ts.URL+"/v1/api/reconciliations?organisation_id=test", "application/xml", strings.NewReader(statement))
+	if err != nil {
+		t.Fatalf("Failed to reconcile statement: %s", err.Error())
+	}
+	json.NewDecoder(res.Body).Decode(&report)
+	res.Body.Close()
 	if res.StatusCode != 200 {
-		t.Errorf("Status should be 200: is %d", res.StatusCode)
+		t.Fatalf("Status code should be 200: is %d", res.StatusCode)
 	}
-	payments := parsePayments(res)
+	if len(report.Matched) != 1 || report.Matched[0].PaymentId != created.Id {
+		t.Errorf("Entry E1 should match payment %s: matched %d entries", created.Id, len(report.Matched))
+	}
+	if len(report.Unmatched) != 1 || report.Unmatched[0].EntryReference != "E2" {
+		t.Errorf("Entry E2 should be unmatched: %d unmatched entries", len(report.Unmatched))
+	}
+
+	res = getPayment(ts, t, created.Id)
+	reconciled := parsePayment(res)
 	res.Body.Close()
+	if reconciled.Reconciliation == nil || reconciled.Reconciliation.StatementReference != "STMT-1" {
+		t.Errorf("Payment should be reconciled against statement STMT-1")
+	}
+	if reconciled.Version != created.Version+1 {
+		t.Errorf("Reconciliation should bump the payment version to %d: is %d", created.Version+1, reconciled.Version)
+	}
 
-	if len(payments.Data) > 0 {
-		t.Errorf("Payment list size should be 0: is %d", len(payments.Data))
+	// A second import of the same statement cannot match the payment again
+	res, _ = http.Post(ts.URL+"/v1/api/reconciliations?organisation_id=test", "application/xml", strings.NewReader(statement))
+	json.NewDecoder(res.Body).Decode(&report)
+	res.Body.Close()
+	if len(report.Matched) != 0 {
+		t.Errorf("Reconciled payment should not match again: matched %d entries", len(report.Matched))
 	}
 
-	res = createPayment(ts, t, createPaymentBody(t, validPayment))
+	// Settled payments are neither edited nor deleted
+	res = updatePayment(ts, t, reconciled.Id, createPaymentBody(t, reconciled))
+	res.Body.Close()
+	if res.StatusCode != 409 {
+		t.Errorf("Status updating a reconciled payment should be 409: is %d", res.StatusCode)
+	}
+	res = deletePayment(ts, t, reconciled.Id)
 	res.Body.Close()
+	if res.StatusCode != 409 {
+		t.Errorf("Status deleting a reconciled payment should be 409: is %d", res.StatusCode)
+	}
 
-	res = getPayments(ts, t)
+	res, _ = http.Get(ts.URL + "/v1/api/reconciliations/" + report.Id)
+	res.Body.Close()
 	if res.StatusCode != 200 {
-		t.Errorf("Status should be 200: is %d", res.StatusCode)
+		t.Errorf("Report %s should be found: status %d", report.Id, res.StatusCode)
 	}
-	payments = parsePayments(res)
+
+	// Cancelled payments never settle and are not matched
+	cancelledReference := reference + "-cancelled"
+	payment.Attributes.EndToEndReference = cancelledReference
+	res = createPayment(ts, t, createPaymentBody(t, payment))
+	cancelled := parsePayment(res)
+	res.Body.Close()
+	res = requestAsUser(ts, t, "POST", "/v1/api/payments/"+cancelled.Id+"/cancellation", "operator", []byte(`{"reason_code": "CUST"}`))
 	res.Body.Close()
+	res, _ = http.Post(ts.URL+"/v1/api/reconciliations?organisation_id=test", "application/xml",
+		strings.NewReader(fmt.Sprintf(camt053Statement, cancelledReference)))
+	json.NewDecoder(res.Body).Decode(&report)
+	res.Body.Close()
+	if len(report.Matched) != 0 {
+		t.Errorf("Cancelled payment should not be matched: matched %d entries", len(report.Matched))
+	}
 
-	if len(payments.Data) != 1 {
-		t.Errorf("Payment list size should be 1: is %d", len(payments.Data))
+	// Payments are only settled by reconciliation, not created settled
+	payment.Attributes.EndToEndReference = reference + "-posted"
+	payment.Reconciliation = &types.PaymentReconciliation{StatementReference: "STMT-1"}
+	res = createPayment(ts, t, createPaymentBody(t, payment))
+	posted := parsePayment(res)
+	res.Body.Close()
+	payment.Reconciliation = nil
+	if posted.Reconciliation != nil {
+		t.Errorf("Reconciliation of a created payment should be ignored")
+	}
+
+	res, _ = http.Post(ts.URL+"/v1/api/reconciliations?organisation_id=test", "application/xml", strings.NewReader("not xml"))
+	res.Body.Close()
+	if res.StatusCode != 400 {
+		t.Errorf("Status code should be 400 for an invalid statement: is %d", res.StatusCode)
+	}
+}
+
+// Validate document against an XSD with xmllint, skipped without the XSD or
+// xmllint. The ISO 20022 schemas are not redistributed here, see
+// testdata/README.md for the files to add.
+func validateXml(t *testing.T, document []byte, xsd string) {
+	if _, err := os.Stat(xsd); err != nil {
+		t.Skipf("XSD %s not found, add it to validate exports", xsd)
+	}
+	xmllint, err := exec.LookPath("xmllint")
+	if err != nil {
+		t.Skip("xmllint not found, install it to validate exports")
+	}
+	cmd := exec.Command(xmllint, "--noout", "--schema", xsd, "-")
+	cmd.Stdin = bytes.NewReader(document)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("Document does not validate against %s: %s", xsd, output)
 	}
 }
 
@@ -265,6 +2431,15 @@ func getPayments(ts *httptest.Server, t *testing.T) *http.Response {
 	return res
 }
 
+func getPaymentsQuery(ts *httptest.Server, t *testing.T, query string) *http.Response {
+	res, err := http.Get(ts.URL + "/v1/api/payments" + query)
+	if err != nil {
+		log.Fatal(err)
+		t.Errorf("Failed to get payments: %s", err.Error())
+	}
+	return res
+}
+
 func getPayment(ts *httptest.Server, t *testing.T, id string) *http.Response {
 	res, err := http.Get(ts.URL + "/v1/api/payments/" + id)
 	if err != nil {
@@ -298,6 +2473,24 @@ func updatePayment(ts *httptest.Server, t *testing.T, id string, reqBody []byte)
 	return res
 }
 
+func requestAsUser(ts *httptest.Server, t *testing.T, method string, path string, userId string, reqBody []byte) *http.Response {
+	req, err := http.NewRequest(method, ts.URL+path, bytes.NewReader(reqBody))
+	if err != nil {
+		log.Fatal(err)
+		t.Errorf("Failed to %s %s: %s", method, path, err.Error())
+	}
+	if userId != "" {
+		req.Header.Set("X-User-Id", userId)
+	}
+	client := &http.Client{}
+	res, err := client.Do(req)
+	if err != nil {
+		log.Fatal(err)
+		t.Errorf("Failed to %s %s: %s", method, path, err.Error())
+	}
+	return res
+}
+
 func deletePayment(ts *httptest.Server, t *testing.T, id string) *http.Response {
 	req, err := http.NewRequest("DELETE", ts.URL+"/v1/api/payments/"+id, nil)
 	if err != nil {
@@ -321,8 +2514,26 @@ func deleteAllPayments(ts *httptest.Server, t *testing.T) {
 	payments := parsePayments(res)
 	res.Body.Close()
 
+	// Deleted through the store, the api keeps settled payments
+	paymentStore := getApplication(TestConfig).paymentStore
 	for _, payment := range payments.Data {
-		deletePayment(ts, t, payment.Id)
+		if _, err := paymentStore.DeletePayment(payment); err != nil {
+			t.Errorf("Failed to delete payment %s: %s", payment.Id, err.Error())
+		}
+	}
+}
+
+func drainEvents(t *testing.T) {
+	relay := getEventRelay(TestConfig, events.NewLogPublisher())
+	for {
+		published, err := relay.RelayPending()
+		if err != nil {
+			t.Errorf("Failed to drain events: %s", err.Error())
+			return
+		}
+		if published == 0 {
+			return
+		}
 	}
 }
 
@@ -347,6 +2558,18 @@ func parsePayment(res *http.Response) *types.Payment {
 	return &payment
 }
 
+func parseStandingOrder(res *http.Response) *types.StandingOrder {
+	var order types.StandingOrder
+	json.NewDecoder(res.Body).Decode(&order)
+	return &order
+}
+
+func parseBeneficiary(res *http.Response) *types.Beneficiary {
+	var beneficiary types.Beneficiary
+	json.NewDecoder(res.Body).Decode(&beneficiary)
+	return &beneficiary
+}
+
 func parsePaymentDelete(res *http.Response) *types.PaymentDelete {
 	var paymentDelete types.PaymentDelete
 	json.NewDecoder(res.Body).Decode(&paymentDelete)
@@ -358,3 +2581,11 @@ func parseHttpError(res *http.Response) *types.HttpError {
 	json.NewDecoder(res.Body).Decode(&httpError)
 	return &httpError
 }
+
+func errorFields(httpError *types.HttpError) map[string]bool {
+	fields := make(map[string]bool)
+	for _, err := range httpError.Errors {
+		fields[err.Field] = true
+	}
+	return fields
+}
@@ -0,0 +1,122 @@
+package scheduler
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/brunovale91/payment-api/services"
+	"github.com/brunovale91/payment-api/store"
+	"github.com/brunovale91/payment-api/types"
+	"github.com/google/uuid"
+)
+
+type SchedulerConfig struct {
+	Interval time.Duration
+
+	// How long a replica holds a due payment while releasing it
+	LeaseDuration time.Duration
+
+	// Most payments released per run
+	BatchSize int
+}
+
+type Scheduler interface {
+
+	// Start generating and releasing due payments in the background
+	Start()
+
+	// Stop the background scheduler, later calls do nothing
+	Stop()
+
+	// Release scheduled payments whose execution date is due and return how
+	// many were released
+	ReleaseDue() (int, error)
+
+	// Generate the payments of standing orders due and return how many were
+	// generated
+	GenerateDue() (int, error)
+}
+
+// Each scheduler leases a due payment before releasing it, so with several
+// replicas running only the lease holder releases each payment
+type SchedulerImpl struct {
+	config         *SchedulerConfig
+	store          store.PaymentStore
+	standingOrders services.StandingOrderService
+	clock          services.Clock
+	owner          string
+	stop           chan struct{}
+	stopOnce       *sync.Once
+}
+
+func NewScheduler(config *SchedulerConfig, paymentStore store.PaymentStore, standingOrders services.StandingOrderService, clock services.Clock) Scheduler {
+	return SchedulerImpl{
+		config:         config,
+		store:          paymentStore,
+		standingOrders: standingOrders,
+		clock:          clock,
+		owner:          uuid.New().String(),
+		stop:           make(chan struct{}),
+		stopOnce:       &sync.Once{},
+	}
+}
+
+func (s SchedulerImpl) Start() {
+	go func() {
+		ticker := time.NewTicker(s.config.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				if _, err := s.GenerateDue(); err != nil {
+					log.Printf("Error generating standing order payments: %s", err.Error())
+				}
+				if _, err := s.ReleaseDue(); err != nil {
+					log.Printf("Error releasing scheduled payments: %s", err.Error())
+				}
+			}
+		}
+	}()
+}
+
+func (s SchedulerImpl) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+func (s SchedulerImpl) GenerateDue() (int, error) {
+	return s.standingOrders.GenerateDue(s.clock.Now(), s.config.BatchSize)
+}
+
+// A payment changed while leased is skipped, its lease expires and it is
+// leased again on a later run if it is still due.
+func (s SchedulerImpl) ReleaseDue() (int, error) {
+	released := 0
+	for released < s.config.BatchSize {
+		now := s.clock.Now()
+		payment, err := s.store.LeaseDuePayment(now, s.owner, now.Add(s.config.LeaseDuration))
+		if err != nil {
+			return released, err
+		}
+		if payment == nil {
+			break
+		}
+		payment.Status = types.PaymentAccepted
+		updated, err := s.store.UpdatePayment(payment, types.PaymentReleased, nil)
+		if _, ok := err.(store.VersionConflictError); ok {
+			log.Printf("Payment %s changed while leased, not released", payment.Id)
+			continue
+		}
+		if err != nil {
+			return released, err
+		}
+		if updated != nil {
+			released++
+		}
+	}
+	return released, nil
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/brunovale91/payment-api/ledger"
+	"github.com/brunovale91/payment-api/store"
+	"github.com/brunovale91/payment-api/types"
+	"github.com/google/uuid"
+)
+
+type AccountService interface {
+
+	// Generate id, create account and return created account
+	CreateAccount(*types.Account) (*types.Account, error)
+
+	// Update account details and return updated account, its organisation
+	// cannot change. Payments keep the details they were created with.
+	UpdateAccount(string, *types.Account) (*types.Account, error)
+
+	// Delete account
+	DeleteAccount(string) (bool, error)
+
+	// Get account
+	GetAccount(string) (*types.Account, error)
+
+	// Get accounts, of one organisation when the id is not empty
+	GetAccounts(string) ([]*types.Account, error)
+
+	// Ledger account a saved account posts to, the same whatever its details
+	// become, empty if there is no saved account with the id
+	LedgerAccountId(string) (string, error)
+
+	// Set the parties of payment attributes of an organisation to the
+	// accounts they reference, replacing inline parties. Parties resolved
+	// for previous attributes are kept while they reference the same
+	// account.
+	ResolveParties(string, *types.PaymentAttributes, *types.PaymentAttributes) error
+}
+
+// Payment references an account that does not exist or belongs to another
+// organisation, Field names the reference
+type AccountError struct {
+	Field   string
+	Message string
+}
+
+func (e AccountError) Error() string {
+	return e.Message
+}
+
+type AccountServiceImpl struct {
+	store store.AccountStore
+}
+
+func NewAccountService(accountStore store.AccountStore) AccountService {
+	return AccountServiceImpl{
+		store: accountStore,
+	}
+}
+
+func (a AccountServiceImpl) CreateAccount(account *types.Account) (*types.Account, error) {
+	id, err := uuid.NewUUID()
+	if err != nil {
+		return nil, err
+	}
+	account.Id = id.String()
+	account.Type = "Account"
+	account.Version = 0
+	account.CreatedAt = time.Now().UTC()
+	return a.store.CreateAccount(account)
+}
+
+func (a AccountServiceImpl) UpdateAccount(id string, details *types.Account) (*types.Account, error) {
+	account, err := a.store.GetAccount(id)
+	if err != nil || account == nil {
+		return nil, err
+	}
+	account.BankId = details.BankId
+	account.BankIdCode = details.BankIdCode
+	account.AccountNumber = details.AccountNumber
+	account.AccountNumberCode = details.AccountNumberCode
+	account.Name = details.Name
+	updated, err := a.store.UpdateAccount(account)
+	if conflict, ok := err.(store.VersionConflictError); ok {
+		return nil, StateError{Message: conflict.Error()}
+	}
+	return updated, err
+}
+
+func (a AccountServiceImpl) DeleteAccount(id string) (bool, error) {
+	return a.store.DeleteAccount(id)
+}
+
+func (a AccountServiceImpl) GetAccount(id string) (*types.Account, error) {
+	return a.store.GetAccount(id)
+}
+
+func (a AccountServiceImpl) GetAccounts(organisationId string) ([]*types.Account, error) {
+	return a.store.GetAccounts(organisationId)
+}
+
+func (a AccountServiceImpl) LedgerAccountId(id string) (string, error) {
+	account, err := a.store.GetAccount(id)
+	if err != nil || account == nil {
+		return "", err
+	}
+	return ledger.AccountId(account.OrganisationId, account.Id, accountParty(account)), nil
+}
+
+func (a AccountServiceImpl) ResolveParties(organisationId string, attributes *types.PaymentAttributes, previous *types.PaymentAttributes) error {
+	if previous == nil {
+		previous = &types.PaymentAttributes{}
+	}
+	debtor, err := a.resolveParty(organisationId, "debtor_account_id", attributes.DebtorAccountId,
+		previous.DebtorAccountId, previous.DebtorParty)
+	if err != nil {
+		return err
+	}
+	beneficiary, err := a.resolveParty(organisationId, "beneficiary_account_id", attributes.BeneficiaryAccountId,
+		previous.BeneficiaryAccountId, previous.BeneficiaryParty)
+	if err != nil {
+		return err
+	}
+	if debtor != nil {
+		attributes.DebtorParty = debtor
+	}
+	if beneficiary != nil {
+		attributes.BeneficiaryParty = beneficiary
+	}
+	return nil
+}
+
+// Party of a referenced account, nil when there is no reference
+func (a AccountServiceImpl) resolveParty(organisationId string, field string, accountId string, previousId string, previousParty *types.PaymentParty) (*types.PaymentParty, error) {
+	if accountId == "" {
+		return nil, nil
+	}
+	if accountId == previousId && previousParty != nil {
+		return previousParty, nil
+	}
+	account, err := a.store.GetAccount(accountId)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil || account.OrganisationId != organisationId {
+		return nil, AccountError{Field: field, Message: fmt.Sprintf("Account %s not found", accountId)}
+	}
+	return accountParty(account), nil
+}
+
+func accountParty(account *types.Account) *types.PaymentParty {
+	return &types.PaymentParty{
+		BankId:            account.BankId,
+		BankIdCode:        account.BankIdCode,
+		Name:              account.Name,
+		AccountNumber:     account.AccountNumber,
+		AccountNumberCode: account.AccountNumberCode,
+		AccountName:       account.Name,
+	}
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/brunovale91/payment-api/store"
+	"github.com/brunovale91/payment-api/types"
+	"github.com/google/uuid"
+)
+
+type BeneficiaryService interface {
+
+	// Generate id, save unverified beneficiary as the given user and return it
+	CreateBeneficiary(*types.Beneficiary, string) (*types.Beneficiary, error)
+
+	// Update beneficiary name and party as the given user and return it,
+	// unverified until verified again. Its organisation cannot change.
+	UpdateBeneficiary(string, *types.Beneficiary, string) (*types.Beneficiary, error)
+
+	// Verify beneficiary as the given user, who cannot be the user that
+	// saved or last edited it
+	VerifyBeneficiary(string, string) (*types.Beneficiary, error)
+
+	// Delete beneficiary
+	DeleteBeneficiary(string) (bool, error)
+
+	// Get beneficiary
+	GetBeneficiary(string) (*types.Beneficiary, error)
+
+	// Get beneficiaries of an organisation, with names containing the given
+	// text when not empty, most used first
+	GetBeneficiaries(string, string) ([]*types.Beneficiary, error)
+
+	// Set the beneficiary party of payment attributes of an organisation to
+	// the verified beneficiary they reference. The party resolved for
+	// previous attributes is kept while they reference the same beneficiary.
+	ResolveBeneficiary(string, *types.PaymentAttributes, *types.PaymentAttributes) error
+
+	// Count a payment created to a beneficiary
+	RecordUsage(string) error
+}
+
+// Payment references a beneficiary that does not exist, belongs to another
+// organisation or is not verified, Field names the reference
+type BeneficiaryError struct {
+	Field   string
+	Message string
+}
+
+func (e BeneficiaryError) Error() string {
+	return e.Message
+}
+
+type BeneficiaryServiceImpl struct {
+	store store.BeneficiaryStore
+}
+
+func NewBeneficiaryService(beneficiaryStore store.BeneficiaryStore) BeneficiaryService {
+	return BeneficiaryServiceImpl{
+		store: beneficiaryStore,
+	}
+}
+
+func (b BeneficiaryServiceImpl) CreateBeneficiary(beneficiary *types.Beneficiary, userId string) (*types.Beneficiary, error) {
+	id, err := uuid.NewUUID()
+	if err != nil {
+		return nil, err
+	}
+	beneficiary.Id = id.String()
+	beneficiary.Type = "Beneficiary"
+	beneficiary.Version = 0
+	beneficiary.Status = types.BeneficiaryUnverified
+	beneficiary.CreatedBy = userId
+	beneficiary.CreatedAt = time.Now().UTC()
+	beneficiary.UpdatedBy = ""
+	beneficiary.VerifiedBy = ""
+	beneficiary.VerifiedAt = nil
+	beneficiary.UsageCount = 0
+	beneficiary.LastUsedAt = nil
+	return b.store.CreateBeneficiary(beneficiary)
+}
+
+func (b BeneficiaryServiceImpl) UpdateBeneficiary(id string, details *types.Beneficiary, userId string) (*types.Beneficiary, error) {
+	beneficiary, err := b.store.GetBeneficiary(id)
+	if err != nil || beneficiary == nil {
+		return nil, err
+	}
+	beneficiary.Name = details.Name
+	beneficiary.Party = details.Party
+	beneficiary.Status = types.BeneficiaryUnverified
+	beneficiary.UpdatedBy = userId
+	beneficiary.VerifiedBy = ""
+	beneficiary.VerifiedAt = nil
+	return returnBeneficiaryConflict(b.store.UpdateBeneficiary(beneficiary))
+}
+
+func (b BeneficiaryServiceImpl) VerifyBeneficiary(id string, userId string) (*types.Beneficiary, error) {
+	beneficiary, err := b.store.GetBeneficiary(id)
+	if err != nil || beneficiary == nil {
+		return nil, err
+	}
+	if beneficiary.Status == types.BeneficiaryVerified {
+		return nil, StateError{Message: fmt.Sprintf("Beneficiary %s is already verified", id)}
+	}
+	// Without a known author anyone could verify, so nobody can
+	if beneficiary.CreatedBy == "" {
+		return nil, ForbiddenError{Message: "Beneficiaries saved without a user cannot be verified"}
+	}
+	if userId == beneficiary.CreatedBy || userId == beneficiary.UpdatedBy {
+		return nil, ForbiddenError{Message: "Beneficiaries cannot be verified by the user that saved or last edited them"}
+	}
+	verifiedAt := time.Now().UTC()
+	beneficiary.Status = types.BeneficiaryVerified
+	beneficiary.VerifiedBy = userId
+	beneficiary.VerifiedAt = &verifiedAt
+	return returnBeneficiaryConflict(b.store.UpdateBeneficiary(beneficiary))
+}
+
+func (b BeneficiaryServiceImpl) DeleteBeneficiary(id string) (bool, error) {
+	return b.store.DeleteBeneficiary(id)
+}
+
+func (b BeneficiaryServiceImpl) GetBeneficiary(id string) (*types.Beneficiary, error) {
+	return b.store.GetBeneficiary(id)
+}
+
+func (b BeneficiaryServiceImpl) GetBeneficiaries(organisationId string, name string) ([]*types.Beneficiary, error) {
+	return b.store.GetBeneficiaries(organisationId, name)
+}
+
+func (b BeneficiaryServiceImpl) ResolveBeneficiary(organisationId string, attributes *types.PaymentAttributes, previous *types.PaymentAttributes) error {
+	if attributes.BeneficiaryId == "" {
+		return nil
+	}
+	if attributes.BeneficiaryAccountId != "" {
+		return BeneficiaryError{Field: "beneficiary_id", Message: "beneficiary_id cannot be given with beneficiary_account_id"}
+	}
+	if previous != nil && previous.BeneficiaryId == attributes.BeneficiaryId && previous.BeneficiaryParty != nil {
+		attributes.BeneficiaryParty = previous.BeneficiaryParty
+		return nil
+	}
+	beneficiary, err := b.store.GetBeneficiary(attributes.BeneficiaryId)
+	if err != nil {
+		return err
+	}
+	if beneficiary == nil || beneficiary.OrganisationId != organisationId {
+		return BeneficiaryError{Field: "beneficiary_id", Message: fmt.Sprintf("Beneficiary %s not found", attributes.BeneficiaryId)}
+	}
+	if beneficiary.Status != types.BeneficiaryVerified {
+		return BeneficiaryError{Field: "beneficiary_id", Message: fmt.Sprintf("Beneficiary %s is not verified", attributes.BeneficiaryId)}
+	}
+	party := *beneficiary.Party
+	attributes.BeneficiaryParty = &party
+	return nil
+}
+
+func (b BeneficiaryServiceImpl) RecordUsage(id string) error {
+	return b.store.RecordUsage(id, time.Now().UTC())
+}
+
+func returnBeneficiaryConflict(beneficiary *types.Beneficiary, err error) (*types.Beneficiary, error) {
+	if conflict, ok := err.(store.VersionConflictError); ok {
+		return nil, StateError{Message: conflict.Error()}
+	}
+	return beneficiary, err
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/brunovale91/payment-api/calendar"
+	"github.com/brunovale91/payment-api/types"
+)
+
+const processingDateLayout = "2006-01-02"
+
+type CalendarService interface {
+
+	// Get calendar by id, nil if there is none
+	GetCalendar(string) calendar.Calendar
+
+	// Calendar of payment attributes, that of their currency or else of
+	// their debtor country, weekends only without either
+	PaymentCalendar(*types.PaymentAttributes) calendar.Calendar
+
+	// Earliest processing date of payment attributes submitted at the given
+	// time, after the cut-off of their scheme it is the next business day
+	EarliestProcessingDate(*types.PaymentAttributes, time.Time) time.Time
+
+	// Set the earliest processing date of attributes submitted at the given
+	// time when they have none, or check the one they have
+	SetProcessingDate(*types.PaymentAttributes, time.Time) error
+}
+
+type CalendarConfig struct {
+	// Calendar id to calendar
+	Calendars map[string]calendar.Calendar
+
+	// Scheme to cut-off time of day in UTC
+	CutOffs map[string]time.Duration
+
+	// Schemes settling every day, e.g. FPS, processed on the day payments
+	// are submitted
+	ContinuousSchemes []string
+}
+
+// Processing date is not a business day or is earlier than the payment can
+// be processed
+type ProcessingDateError struct {
+	Message string
+}
+
+func (e ProcessingDateError) Error() string {
+	return e.Message
+}
+
+type CalendarServiceImpl struct {
+	config   *CalendarConfig
+	weekends calendar.Calendar
+}
+
+func NewCalendarService(config *CalendarConfig) CalendarService {
+	return CalendarServiceImpl{
+		config:   config,
+		weekends: calendar.NewWeekendCalendar(),
+	}
+}
+
+func (c CalendarServiceImpl) GetCalendar(id string) calendar.Calendar {
+	return c.config.Calendars[id]
+}
+
+func (c CalendarServiceImpl) PaymentCalendar(attributes *types.PaymentAttributes) calendar.Calendar {
+	if paymentCalendar, ok := c.config.Calendars[attributes.Currency]; ok {
+		return paymentCalendar
+	}
+	if attributes.DebtorParty != nil {
+		if paymentCalendar, ok := c.config.Calendars[attributes.DebtorParty.Country]; ok {
+			return paymentCalendar
+		}
+	}
+	return c.weekends
+}
+
+func (c CalendarServiceImpl) EarliestProcessingDate(attributes *types.PaymentAttributes, submitted time.Time) time.Time {
+	submitted = submitted.UTC()
+	date := time.Date(submitted.Year(), submitted.Month(), submitted.Day(), 0, 0, 0, 0, time.UTC)
+	if contains(c.config.ContinuousSchemes, attributes.PaymentScheme) {
+		return date
+	}
+	if cutOff, ok := c.config.CutOffs[attributes.PaymentScheme]; ok && submitted.Sub(date) >= cutOff {
+		date = date.AddDate(0, 0, 1)
+	}
+	return c.PaymentCalendar(attributes).RollForward(date)
+}
+
+func (c CalendarServiceImpl) SetProcessingDate(attributes *types.PaymentAttributes, submitted time.Time) error {
+	earliest := c.EarliestProcessingDate(attributes, submitted)
+	if attributes.ProcessingDate == "" {
+		attributes.ProcessingDate = earliest.Format(processingDateLayout)
+		return nil
+	}
+	date, err := time.Parse(processingDateLayout, attributes.ProcessingDate)
+	if err != nil {
+		return ProcessingDateError{Message: fmt.Sprintf("Processing date %s is not a date", attributes.ProcessingDate)}
+	}
+	if date.Before(earliest) {
+		return ProcessingDateError{Message: fmt.Sprintf("Processing date %s is before the earliest processing date %s",
+			attributes.ProcessingDate, earliest.Format(processingDateLayout))}
+	}
+	if !contains(c.config.ContinuousSchemes, attributes.PaymentScheme) && !c.PaymentCalendar(attributes).IsBusinessDay(date) {
+		return ProcessingDateError{Message: fmt.Sprintf("Processing date %s is not a business day", attributes.ProcessingDate)}
+	}
+	return nil
+}
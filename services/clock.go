@@ -0,0 +1,14 @@
+package services
+
+import "time"
+
+// Source of the current time of scheduled work, replaced in tests
+type Clock interface {
+	Now() time.Time
+}
+
+type SystemClock struct{}
+
+func (c SystemClock) Now() time.Time {
+	return time.Now().UTC()
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"context"
+
+	"github.com/brunovale91/payment-api/store"
+	"github.com/brunovale91/payment-api/types"
+)
+
+type EventService interface {
+
+	// Subscribe to payment events of an organisation written after lastEventId
+	SubscribeEvents(ctx context.Context, organisationId string, lastEventId string) (<-chan *types.PaymentEvent, error)
+}
+
+// Last event id to resume from is not a position of the event stream
+type CursorError struct {
+	Message string
+}
+
+func (e CursorError) Error() string {
+	return e.Message
+}
+
+type EventServiceImpl struct {
+	stream store.EventStream
+}
+
+func NewEventService(eventStream store.EventStream) EventService {
+	return EventServiceImpl{
+		stream: eventStream,
+	}
+}
+
+func (e EventServiceImpl) SubscribeEvents(ctx context.Context, organisationId string, lastEventId string) (<-chan *types.PaymentEvent, error) {
+	events, err := e.stream.Subscribe(ctx, organisationId, lastEventId)
+	if cursorErr, ok := err.(store.CursorError); ok {
+		return nil, CursorError{Message: cursorErr.Error()}
+	}
+	return events, err
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/brunovale91/payment-api/batchfile"
+	"github.com/brunovale91/payment-api/iso20022"
+	"github.com/brunovale91/payment-api/store"
+	"github.com/brunovale91/payment-api/swift"
+	"github.com/brunovale91/payment-api/types"
+)
+
+type ExportService interface {
+
+	// Render payments matching filter as an ISO 20022 pain.001 document
+	ExportPain001(*types.PaymentFilter) ([]byte, error)
+
+	// Render payments matching filter as consecutive SWIFT MT103 messages
+	ExportMT103(*types.PaymentFilter) ([]byte, error)
+
+	// Render payments matching filter as a Bacs Standard 18 file
+	ExportStandard18(*types.PaymentFilter) ([]byte, error)
+
+	// Render payments matching filter as a NACHA ACH file, effective on the
+	// next USD business day at the earliest
+	ExportNacha(*types.PaymentFilter) ([]byte, error)
+}
+
+// Settings of each export format
+type ExportConfig struct {
+	Pain001    *iso20022.Pain001Config
+	MT103      *swift.MT103Config
+	Standard18 *batchfile.Standard18Config
+	Nacha      *batchfile.NachaConfig
+}
+
+type ExportServiceImpl struct {
+	store     store.PaymentStore
+	calendars CalendarService
+	config    *ExportConfig
+}
+
+func NewExportService(paymentStore store.PaymentStore, calendars CalendarService, config *ExportConfig) ExportService {
+	return ExportServiceImpl{
+		store:     paymentStore,
+		calendars: calendars,
+		config:    config,
+	}
+}
+
+func (e ExportServiceImpl) ExportPain001(filter *types.PaymentFilter) ([]byte, error) {
+	payments, err := e.getPayments(filter)
+	if err != nil {
+		return nil, err
+	}
+	document, err := iso20022.NewPain001(e.config.Pain001, payments, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return iso20022.MarshalPain001(document)
+}
+
+func (e ExportServiceImpl) ExportMT103(filter *types.PaymentFilter) ([]byte, error) {
+	payments, err := e.getPayments(filter)
+	if err != nil {
+		return nil, err
+	}
+	messages := make([][]byte, 0, len(payments))
+	now := time.Now()
+	for _, payment := range payments {
+		message, err := swift.NewMT103(e.config.MT103, payment, now)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, swift.MarshalMT103(message))
+	}
+	return bytes.Join(messages, []byte("\r\n")), nil
+}
+
+func (e ExportServiceImpl) ExportStandard18(filter *types.PaymentFilter) ([]byte, error) {
+	payments, err := e.getPayments(filter)
+	if err != nil {
+		return nil, err
+	}
+	return batchfile.NewStandard18(e.config.Standard18, payments, time.Now())
+}
+
+func (e ExportServiceImpl) ExportNacha(filter *types.PaymentFilter) ([]byte, error) {
+	payments, err := e.getPayments(filter)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	usd := e.calendars.PaymentCalendar(&types.PaymentAttributes{Currency: "USD"})
+	return batchfile.NewNacha(e.config.Nacha, payments, now, usd.NextBusinessDay(now))
+}
+
+// Payments matching filter that can progress to a payment file, those
+// waiting for approval or their execution date are left out
+func (e ExportServiceImpl) getPayments(filter *types.PaymentFilter) ([]*types.Payment, error) {
+	payments, err := e.store.GetPayments(filter)
+	if err != nil {
+		return nil, err
+	}
+	exportable := make([]*types.Payment, 0, len(payments))
+	for _, payment := range payments {
+		if payment.Status == "" || payment.Status == types.PaymentAccepted {
+			exportable = append(exportable, payment)
+		}
+	}
+	return exportable, nil
+}
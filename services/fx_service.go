@@ -0,0 +1,238 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/brunovale91/payment-api/store"
+	"github.com/brunovale91/payment-api/types"
+	"github.com/google/uuid"
+)
+
+var currencyPattern = regexp.MustCompile("^[A-Z]{3}$")
+
+type FxService interface {
+
+	// Save rates, effective when saved unless they have an effective time
+	CreateRates([]*types.FxRate) ([]*types.FxRate, error)
+
+	// Get rates, of one base and quote currency when not empty
+	GetRates(string, string) ([]*types.FxRate, error)
+
+	// Quote the current rate of a currency pair for an amount, guaranteed
+	// until the quote expires
+	CreateQuote(*types.QuoteRequest) (*types.FxQuote, error)
+
+	// Get quote
+	GetQuote(string) (*types.FxQuote, error)
+
+	// Convert the amount of payment attributes settled in another currency
+	// at the rate of their quote, or else the rate in effect at the given
+	// time, and return the applied rate. Nil when there is no conversion.
+	// The rate applied to previous attributes is kept while the currencies
+	// and quote do not change.
+	ConvertPayment(*types.PaymentAttributes, time.Time, *types.PaymentAttributes, *types.AppliedRate) (*types.AppliedRate, error)
+}
+
+type FxConfig struct {
+	// How long quoted rates are guaranteed
+	QuoteTTL time.Duration
+}
+
+// Rates or quotes cannot be used for a payment or request, Field names the
+// offending field
+type FxError struct {
+	Field   string
+	Message string
+}
+
+func (e FxError) Error() string {
+	return e.Message
+}
+
+type FxServiceImpl struct {
+	store  store.FxStore
+	config *FxConfig
+}
+
+func NewFxService(fxStore store.FxStore, config *FxConfig) FxService {
+	return FxServiceImpl{
+		store:  fxStore,
+		config: config,
+	}
+}
+
+func (f FxServiceImpl) CreateRates(rates []*types.FxRate) ([]*types.FxRate, error) {
+	now := time.Now().UTC()
+	for i, rate := range rates {
+		field := fmt.Sprintf("data[%d].", i)
+		if !currencyPattern.MatchString(rate.BaseCurrency) {
+			return nil, FxError{Field: field + "base_currency", Message: "base_currency must be an ISO 4217 currency code"}
+		}
+		if !currencyPattern.MatchString(rate.QuoteCurrency) || rate.QuoteCurrency == rate.BaseCurrency {
+			return nil, FxError{Field: field + "quote_currency", Message: "quote_currency must be an ISO 4217 currency code other than base_currency"}
+		}
+		if rate.Rate <= 0 {
+			return nil, FxError{Field: field + "rate", Message: "rate must be positive"}
+		}
+	}
+	for _, rate := range rates {
+		id, err := uuid.NewUUID()
+		if err != nil {
+			return nil, err
+		}
+		rate.Id = id.String()
+		rate.CreatedAt = now
+		if rate.EffectiveAt.IsZero() {
+			rate.EffectiveAt = now
+		}
+		rate.EffectiveAt = rate.EffectiveAt.UTC()
+	}
+	return f.store.CreateRates(rates)
+}
+
+func (f FxServiceImpl) GetRates(base string, quote string) ([]*types.FxRate, error) {
+	return f.store.GetRates(base, quote)
+}
+
+func (f FxServiceImpl) CreateQuote(request *types.QuoteRequest) (*types.FxQuote, error) {
+	if !currencyPattern.MatchString(request.InstructedCurrency) {
+		return nil, FxError{Field: "instructed_currency", Message: "instructed_currency must be an ISO 4217 currency code"}
+	}
+	if !currencyPattern.MatchString(request.SettlementCurrency) || request.SettlementCurrency == request.InstructedCurrency {
+		return nil, FxError{Field: "settlement_currency", Message: "settlement_currency must be an ISO 4217 currency code other than instructed_currency"}
+	}
+	if request.InstructedAmount <= 0 {
+		return nil, FxError{Field: "instructed_amount", Message: "instructed_amount must be positive"}
+	}
+	now := time.Now().UTC()
+	rate, rateId, err := f.rate(request.InstructedCurrency, request.SettlementCurrency, now)
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.NewUUID()
+	if err != nil {
+		return nil, err
+	}
+	quote := &types.FxQuote{
+		Id:                 id.String(),
+		InstructedCurrency: request.InstructedCurrency,
+		InstructedAmount:   request.InstructedAmount,
+		SettlementCurrency: request.SettlementCurrency,
+		SettlementAmount:   convert(request.InstructedAmount, rate),
+		Rate:               rate,
+		RateId:             rateId,
+		CreatedAt:          now,
+		ExpiresAt:          now.Add(f.config.QuoteTTL),
+	}
+	return f.store.CreateQuote(quote)
+}
+
+func (f FxServiceImpl) GetQuote(id string) (*types.FxQuote, error) {
+	return f.store.GetQuote(id)
+}
+
+// Instructed fields default to the amount and currency of the payment and
+// must match them, the settlement amount is always converted
+func (f FxServiceImpl) ConvertPayment(attributes *types.PaymentAttributes, at time.Time, previous *types.PaymentAttributes, previousRate *types.AppliedRate) (*types.AppliedRate, error) {
+	if attributes.SettlementCurrency == "" {
+		if attributes.QuoteId != "" {
+			return nil, FxError{Field: "settlement_currency", Message: "settlement_currency is required with quote_id"}
+		}
+		attributes.InstructedAmount = 0
+		attributes.InstructedCurrency = ""
+		attributes.SettlementAmount = 0
+		return nil, nil
+	}
+	if attributes.Currency == "" {
+		return nil, FxError{Field: "currency", Message: "currency is required with settlement_currency"}
+	}
+	if attributes.InstructedCurrency == "" {
+		attributes.InstructedCurrency = attributes.Currency
+	}
+	if attributes.InstructedAmount == 0 {
+		attributes.InstructedAmount = attributes.Amount
+	}
+	if attributes.InstructedCurrency != attributes.Currency || math.Round(attributes.InstructedAmount*100) != math.Round(attributes.Amount*100) {
+		return nil, FxError{Field: "instructed_amount", Message: "instructed_amount and instructed_currency must match amount and currency"}
+	}
+	if attributes.SettlementCurrency == attributes.Currency && attributes.QuoteId == "" {
+		attributes.SettlementAmount = attributes.Amount
+		return nil, nil
+	}
+
+	if keepsRate(attributes, previous, previousRate) {
+		attributes.SettlementAmount = convert(attributes.Amount, previousRate.Rate)
+		return previousRate, nil
+	}
+
+	applied := &types.AppliedRate{AppliedAt: at}
+	if attributes.QuoteId != "" {
+		quote, err := f.store.GetQuote(attributes.QuoteId)
+		if err != nil {
+			return nil, err
+		}
+		if quote == nil {
+			return nil, FxError{Field: "quote_id", Message: fmt.Sprintf("Quote %s not found", attributes.QuoteId)}
+		}
+		if at.After(quote.ExpiresAt) {
+			return nil, FxError{Field: "quote_id", Message: fmt.Sprintf("Quote %s expired at %s", quote.Id, quote.ExpiresAt.Format(time.RFC3339))}
+		}
+		if quote.InstructedCurrency != attributes.Currency || quote.SettlementCurrency != attributes.SettlementCurrency {
+			return nil, FxError{Field: "quote_id", Message: fmt.Sprintf("Quote %s converts %s to %s", quote.Id, quote.InstructedCurrency, quote.SettlementCurrency)}
+		}
+		if math.Round(quote.InstructedAmount*100) != math.Round(attributes.Amount*100) {
+			return nil, FxError{Field: "quote_id", Message: fmt.Sprintf("Quote %s is for an amount of %s", quote.Id, strconv.FormatFloat(quote.InstructedAmount, 'f', -1, 64))}
+		}
+		applied.Rate, applied.RateId, applied.QuoteId = quote.Rate, quote.RateId, quote.Id
+	} else {
+		rate, rateId, err := f.rate(attributes.Currency, attributes.SettlementCurrency, at)
+		if err != nil {
+			return nil, err
+		}
+		applied.Rate, applied.RateId = rate, rateId
+	}
+	attributes.SettlementAmount = convert(attributes.Amount, applied.Rate)
+	return applied, nil
+}
+
+// Quoted rates are kept for the quoted amount only, which is checked again
+// when the amount changes
+func keepsRate(attributes *types.PaymentAttributes, previous *types.PaymentAttributes, previousRate *types.AppliedRate) bool {
+	if previous == nil || previousRate == nil {
+		return false
+	}
+	if attributes.Currency != previous.Currency || attributes.SettlementCurrency != previous.SettlementCurrency ||
+		attributes.QuoteId != previousRate.QuoteId {
+		return false
+	}
+	return attributes.QuoteId == "" || math.Round(attributes.Amount*100) == math.Round(previous.Amount*100)
+}
+
+// Rate converting from one currency to another in effect at the given time,
+// the inverse of the opposite pair when it took effect later
+func (f FxServiceImpl) rate(from string, to string, at time.Time) (float64, string, error) {
+	direct, err := f.store.GetRate(from, to, at)
+	if err != nil {
+		return 0, "", err
+	}
+	inverse, err := f.store.GetRate(to, from, at)
+	if err != nil {
+		return 0, "", err
+	}
+	if inverse != nil && (direct == nil || inverse.EffectiveAt.After(direct.EffectiveAt)) {
+		return 1 / inverse.Rate, inverse.Id, nil
+	}
+	if direct == nil {
+		return 0, "", FxError{Field: "settlement_currency", Message: fmt.Sprintf("No exchange rate from %s to %s", from, to)}
+	}
+	return direct.Rate, direct.Id, nil
+}
+
+// Converted amounts are rounded to cents
+func convert(amount float64, rate float64) float64 {
+	return math.Round(amount*rate*100) / 100
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"github.com/brunovale91/payment-api/ledger"
+	"github.com/brunovale91/payment-api/store"
+	"github.com/brunovale91/payment-api/types"
+)
+
+type LedgerService interface {
+
+	// Get balance of an account per currency, nil if it has no entries
+	GetBalance(string) (*types.AccountBalance, error)
+
+	// Get entries of an account, in one currency when not empty
+	GetEntries(string, string) ([]*types.LedgerEntry, error)
+
+	// Check that all entries of the ledger sum to zero in every currency,
+	// an UnbalancedError names the first currency that does not
+	CheckInvariant() error
+}
+
+type LedgerServiceImpl struct {
+	store store.LedgerStore
+}
+
+func NewLedgerService(ledgerStore store.LedgerStore) LedgerService {
+	return LedgerServiceImpl{
+		store: ledgerStore,
+	}
+}
+
+func (l LedgerServiceImpl) GetBalance(accountId string) (*types.AccountBalance, error) {
+	balance, err := l.store.GetBalance(accountId)
+	if err != nil || len(balance.Balances) == 0 {
+		return nil, err
+	}
+	return balance, nil
+}
+
+func (l LedgerServiceImpl) GetEntries(accountId string, currency string) ([]*types.LedgerEntry, error) {
+	return l.store.GetEntries(accountId, currency)
+}
+
+func (l LedgerServiceImpl) CheckInvariant() error {
+	totals, err := l.store.GetTotals()
+	if err != nil {
+		return err
+	}
+	return ledger.CheckBalanced(totals)
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/brunovale91/payment-api/types"
+)
+
+// Payments above the threshold need RequiredApprovals approvals from users
+// other than their creator and last editor. When Approvers is set only its
+// users can approve.
+type ApprovalPolicy struct {
+	Threshold         float64
+	RequiredApprovals int
+	Approvers         []string
+}
+
+type ApprovalConfig struct {
+	// Policy of organisations without their own, nil for none
+	DefaultPolicy *ApprovalPolicy
+
+	// Organisation id to policy
+	OrganisationPolicies map[string]*ApprovalPolicy
+}
+
+// User is not allowed to approve the payment
+type ForbiddenError struct {
+	Message string
+}
+
+func (e ForbiddenError) Error() string {
+	return e.Message
+}
+
+// Payment needs approving but was written without a user, so approvals by
+// its creator or editor could not be refused
+type UserRequiredError struct{}
+
+func (e UserRequiredError) Error() string {
+	return "A user is required to write payments that need approving"
+}
+
+func (p PaymentServiceImpl) ApprovePayment(id string, userId string) (*types.Payment, error) {
+	payment, err := p.store.GetPayment(id)
+	if err != nil || payment == nil {
+		return nil, err
+	}
+	if payment.Status != types.PaymentPendingApproval {
+		return nil, StateError{Message: fmt.Sprintf("Payment %s is not pending approval", id)}
+	}
+	if payment.CreatedBy == "" {
+		return nil, ForbiddenError{Message: "Payments created without a user cannot be approved"}
+	}
+	if userId == payment.CreatedBy || userId == payment.UpdatedBy {
+		return nil, ForbiddenError{Message: "Payments cannot be approved by their creator or last editor"}
+	}
+	required := 1
+	if policy := p.approvalPolicy(payment.OrganisationId); policy != nil {
+		if len(policy.Approvers) > 0 && !contains(policy.Approvers, userId) {
+			return nil, ForbiddenError{Message: fmt.Sprintf("User %s cannot approve payments of organisation %s", userId, payment.OrganisationId)}
+		}
+		required = policy.RequiredApprovals
+	}
+	for _, approval := range payment.Approvals {
+		if approval.ApprovedBy == userId {
+			return nil, StateError{Message: fmt.Sprintf("Payment %s is already approved by %s", id, userId)}
+		}
+	}
+
+	payment.Approvals = append(payment.Approvals, &types.PaymentApproval{
+		ApprovedBy: userId,
+		ApprovedAt: time.Now().UTC(),
+	})
+	if len(payment.Approvals) >= required {
+		payment.Status = readyStatus(payment)
+	}
+	return returnConflict(p.store.UpdatePayment(payment, types.PaymentApproved, nil))
+}
+
+// Status of a payment before any approval
+func (p PaymentServiceImpl) approvalStatus(payment *types.Payment) string {
+	policy := p.approvalPolicy(payment.OrganisationId)
+	if policy != nil && policy.RequiredApprovals > 0 && payment.Attributes != nil && payment.Attributes.Amount > policy.Threshold {
+		return types.PaymentPendingApproval
+	}
+	return readyStatus(payment)
+}
+
+func (p PaymentServiceImpl) approvalPolicy(organisationId string) *ApprovalPolicy {
+	if policy, ok := p.approvalConfig.OrganisationPolicies[organisationId]; ok {
+		return policy
+	}
+	return p.approvalConfig.DefaultPolicy
+}
+
+func contains(values []string, value string) bool {
+	for _, item := range values {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
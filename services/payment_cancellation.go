@@ -0,0 +1,48 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/brunovale91/payment-api/types"
+)
+
+// Payments can be cancelled until they are settled, i.e. reconciled against
+// a bank statement, or refunded
+func (p PaymentServiceImpl) CancelPayment(id string, reasonCode string, userId string) (*types.Payment, error) {
+	payment, err := p.store.GetPayment(id)
+	if err != nil || payment == nil {
+		return nil, err
+	}
+	return p.cancel(payment, reasonCode, userId)
+}
+
+// Record who cancelled the payment and why, unless it is already cancelled
+// or settled
+func (p PaymentServiceImpl) cancel(payment *types.Payment, reasonCode string, userId string) (*types.Payment, error) {
+	if payment.Status == types.PaymentCancelled {
+		return nil, StateError{Message: fmt.Sprintf("Payment %s is already cancelled", payment.Id)}
+	}
+	if err := checkUnsettled(payment, "cancelled"); err != nil {
+		return nil, err
+	}
+	payment.Status = types.PaymentCancelled
+	payment.Cancellation = &types.Cancellation{
+		ReasonCode:  reasonCode,
+		CancelledBy: userId,
+		CancelledAt: time.Now().UTC(),
+	}
+	return returnConflict(p.store.UpdatePayment(payment, types.PaymentCancellation, nil))
+}
+
+// Payments reconciled against a bank statement or refunded are settled, they
+// can no longer be changed
+func checkUnsettled(payment *types.Payment, action string) error {
+	switch {
+	case payment.Reconciliation != nil:
+		return StateError{Message: fmt.Sprintf("Payment %s is settled and cannot be %s", payment.Id, action)}
+	case len(payment.Refunds) > 0:
+		return StateError{Message: fmt.Sprintf("Payment %s is refunded and cannot be %s", payment.Id, action)}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"math"
+
+	"github.com/brunovale91/payment-api/types"
+)
+
+// Fee of a payment: Flat plus Percentage of its amount plus the fee of the
+// first tier its amount is within, bounded by Min and Max when set. Fees are
+// charged in the payment currency.
+type PricingRule struct {
+	Flat       float64
+	Percentage float64
+	Tiers      []*FeeTier
+	Min        float64
+	Max        float64
+
+	// Bearer of payments that do not name one, SHAR when empty
+	BearerCode string
+}
+
+// Tier of amounts up to UpTo, unbounded when zero, in increasing order
+type FeeTier struct {
+	UpTo       float64
+	Flat       float64
+	Percentage float64
+}
+
+// Scheme to pricing rule, the empty scheme prices payments of schemes
+// without their own rule
+type Pricing map[string]*PricingRule
+
+type FeeConfig struct {
+	// Pricing of organisations without their own, nil for no fees
+	DefaultPricing Pricing
+
+	// Organisation id to pricing
+	OrganisationPricing map[string]Pricing
+}
+
+func (p PaymentServiceImpl) EstimateCharges(payment *types.Payment) *types.ChargesInformation {
+	if payment.Attributes == nil {
+		return &types.ChargesInformation{}
+	}
+	charges := p.charges(payment.OrganisationId, payment.Attributes)
+	if charges == nil {
+		return &types.ChargesInformation{}
+	}
+	return charges
+}
+
+// Charges of payment attributes by the pricing rule of their organisation
+// and scheme, keeping the bearer code they name
+func (p PaymentServiceImpl) charges(organisationId string, attributes *types.PaymentAttributes) *types.ChargesInformation {
+	bearer := ""
+	if attributes.ChargesInformation != nil {
+		bearer = attributes.ChargesInformation.BearerCode
+	}
+	rule := p.pricingRule(organisationId, attributes.PaymentScheme)
+	if rule == nil {
+		if bearer == "" {
+			return nil
+		}
+		return &types.ChargesInformation{BearerCode: bearer}
+	}
+	if bearer == "" {
+		bearer = rule.BearerCode
+	}
+	if bearer == "" {
+		bearer = types.BearerShared
+	}
+
+	charges := &types.ChargesInformation{BearerCode: bearer}
+	fee := rule.fee(attributes.Amount)
+	if fee == 0 {
+		return charges
+	}
+	// Creditors bear the charges out of the amount they receive, otherwise
+	// the debtor pays the sending institution on top of it
+	if bearer == types.BearerCreditor {
+		charges.ReceiverChargesAmount = fee
+		charges.ReceiverChargesCurrency = attributes.Currency
+	} else {
+		charges.SenderCharges = []*types.Charge{{Amount: fee, Currency: attributes.Currency}}
+	}
+	return charges
+}
+
+func (p PaymentServiceImpl) pricingRule(organisationId string, scheme string) *PricingRule {
+	pricing, ok := p.feeConfig.OrganisationPricing[organisationId]
+	if !ok {
+		pricing = p.feeConfig.DefaultPricing
+	}
+	if rule, ok := pricing[scheme]; ok {
+		return rule
+	}
+	return pricing[""]
+}
+
+// Fees are rounded to cents
+func (r *PricingRule) fee(amount float64) float64 {
+	fee := r.Flat + amount*r.Percentage/100
+	for _, tier := range r.Tiers {
+		if tier.UpTo == 0 || amount <= tier.UpTo {
+			fee += tier.Flat + amount*tier.Percentage/100
+			break
+		}
+	}
+	if r.Min > 0 && fee < r.Min {
+		fee = r.Min
+	}
+	if r.Max > 0 && fee > r.Max {
+		fee = r.Max
+	}
+	return math.Round(fee*100) / 100
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/brunovale91/payment-api/store"
+	"github.com/brunovale91/payment-api/types"
+	"github.com/google/uuid"
+)
+
+// Refund amount is more than what is left to refund of the payment
+type RefundAmountError struct {
+	Refundable float64
+}
+
+func (e RefundAmountError) Error() string {
+	return fmt.Sprintf("Refund exceeds the refundable amount of %s", strconv.FormatFloat(e.Refundable, 'f', -1, 64))
+}
+
+// Refunds are payments from the beneficiary back to the debtor, they go
+// through the same limits and approvals as new payments
+func (p PaymentServiceImpl) RefundPayment(id string, request *types.RefundRequest, userId string) (*types.Payment, error) {
+	payment, err := p.store.GetPayment(id)
+	if err != nil || payment == nil {
+		return nil, err
+	}
+	if payment.RelatedPaymentId != "" {
+		return nil, StateError{Message: fmt.Sprintf("Payment %s is a refund and cannot be refunded", id)}
+	}
+	if payment.Status != "" && payment.Status != types.PaymentAccepted {
+		return nil, StateError{Message: fmt.Sprintf("Payment %s is %s and cannot be refunded", id, payment.Status)}
+	}
+	if payment.Attributes == nil {
+		return nil, StateError{Message: fmt.Sprintf("Payment %s has no attributes and cannot be refunded", id)}
+	}
+	refunded, err := p.refundedAmount(payment)
+	if err != nil {
+		return nil, err
+	}
+	refundable := payment.Attributes.Amount - refunded
+	// Amounts are compared in cents so float rounding cannot block a refund
+	// of the exact remaining amount
+	refundable = math.Round(refundable*100) / 100
+	amount := request.Amount
+	if amount == 0 {
+		amount = refundable
+	}
+	if amount <= 0 || math.Round(amount*100) > math.Round(refundable*100) {
+		return nil, RefundAmountError{Refundable: refundable}
+	}
+
+	refundId, err := uuid.NewUUID()
+	if err != nil {
+		return nil, err
+	}
+	attributes := *payment.Attributes
+	attributes.Amount = amount
+	attributes.DebtorParty, attributes.BeneficiaryParty = payment.Attributes.BeneficiaryParty, payment.Attributes.DebtorParty
+	attributes.DebtorAccountId, attributes.BeneficiaryAccountId = payment.Attributes.BeneficiaryAccountId, payment.Attributes.DebtorAccountId
+	attributes.BeneficiaryId = ""
+	// Refunds are returned in the currency of the payment
+	attributes.InstructedAmount = 0
+	attributes.InstructedCurrency = ""
+	attributes.SettlementAmount = 0
+	attributes.SettlementCurrency = ""
+	attributes.QuoteId = ""
+	attributes.EndToEndReference = request.EndToEndReference
+	if attributes.EndToEndReference == "" {
+		attributes.EndToEndReference = fmt.Sprintf("%s-R%d", payment.Attributes.EndToEndReference, len(payment.Refunds)+1)
+	}
+	refund := &types.Payment{
+		Type:             payment.Type,
+		Id:               refundId.String(),
+		OrganisationId:   payment.OrganisationId,
+		Attributes:       &attributes,
+		CreatedAt:        time.Now().UTC(),
+		CreatedBy:        userId,
+		RelatedPaymentId: payment.Id,
+		ReturnReason:     request.ReasonCode,
+	}
+	refund.Status = p.approvalStatus(refund)
+	if refund.Status == types.PaymentPendingApproval && userId == "" {
+		return nil, UserRequiredError{}
+	}
+	attributes.ProcessingDate = ""
+	if err := p.calendars.SetProcessingDate(&attributes, refund.CreatedAt); err != nil {
+		return nil, err
+	}
+	attributes.ChargesInformation = p.charges(refund.OrganisationId, &attributes)
+	payment.Refunds = append(payment.Refunds, &types.PaymentRefund{
+		PaymentId:    refund.Id,
+		Amount:       amount,
+		ReturnReason: request.ReasonCode,
+		CreatedAt:    refund.CreatedAt,
+	})
+	return returnConflict(p.store.CreateRefund(refund, payment, p.checkRefund))
+}
+
+// Amount of the refunds of a payment that still stand, cancelled or deleted
+// refunds return nothing
+func (p PaymentServiceImpl) refundedAmount(payment *types.Payment) (float64, error) {
+	if len(payment.Refunds) == 0 {
+		return 0, nil
+	}
+	ids := make([]string, 0, len(payment.Refunds))
+	for _, refund := range payment.Refunds {
+		ids = append(ids, refund.PaymentId)
+	}
+	refunds, err := p.store.GetPayments(&types.PaymentFilter{Ids: ids})
+	if err != nil {
+		return 0, err
+	}
+	refunded := 0.0
+	for _, refund := range refunds {
+		if refund.Status != types.PaymentCancelled && refund.Attributes != nil {
+			refunded += refund.Attributes.Amount
+		}
+	}
+	return refunded, nil
+}
+
+// Limits of a refund, counted as a new payment
+func (p PaymentServiceImpl) checkRefund(refund *types.Payment, reader store.PaymentReader) error {
+	return p.checkLimits(reader, refund, true)
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/brunovale91/payment-api/types"
+)
+
+func (p PaymentServiceImpl) ReschedulePayment(id string, executionDate time.Time) (*types.Payment, error) {
+	payment, err := p.store.GetPayment(id)
+	if err != nil || payment == nil {
+		return nil, err
+	}
+	if payment.Status != types.PaymentScheduled && payment.Status != types.PaymentPendingApproval {
+		return nil, StateError{Message: fmt.Sprintf("Payment %s is already released", id)}
+	}
+	executionDate = executionDate.UTC()
+	payment.ExecutionDate = &executionDate
+	if payment.Attributes != nil {
+		payment.Attributes.ProcessingDate = ""
+		if err := p.calendars.SetProcessingDate(payment.Attributes, submittedAt(payment)); err != nil {
+			return nil, err
+		}
+	}
+	return returnConflict(p.store.UpdatePayment(payment, types.PaymentRescheduled, nil))
+}
+
+func (p PaymentServiceImpl) CancelScheduledPayment(id string, reasonCode string, userId string) (*types.Payment, error) {
+	payment, err := p.store.GetPayment(id)
+	if err != nil || payment == nil {
+		return nil, err
+	}
+	scheduled := payment.Status == types.PaymentScheduled ||
+		payment.Status == types.PaymentPendingApproval && payment.ExecutionDate != nil
+	if !scheduled {
+		return nil, StateError{Message: fmt.Sprintf("Payment %s is not scheduled", id)}
+	}
+	return p.cancel(payment, reasonCode, userId)
+}
+
+// Status of a payment that needs no more approvals, the scheduler releases
+// scheduled payments when their execution date is due
+func readyStatus(payment *types.Payment) string {
+	if payment.ExecutionDate != nil {
+		return types.PaymentScheduled
+	}
+	return types.PaymentAccepted
+}
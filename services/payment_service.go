@@ -1,6 +1,11 @@
 package services
 
 import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
 	"github.com/brunovale91/payment-api/store"
 	"github.com/brunovale91/payment-api/types"
 	"github.com/google/uuid"
@@ -8,53 +13,546 @@ import (
 
 type PaymentService interface {
 
-	// Generate id, creates payment and returns created payment
+	// Generate id, creates payment and returns created payment. Payments
+	// duplicating a recent one are rejected or flagged by organisation policy,
+	// payments exceeding organisation limits are rejected and payments that
+	// need approving without a creator fail with a UserRequiredError.
+	// Payments settled in another currency record the rate they are converted
+	// at, charges are calculated by the organisation pricing. Referenced
+	// accounts and beneficiaries are copied into the parties.
 	CreatePayment(*types.Payment) (*types.Payment, error)
 
-	// Update payment attributes and return updated payment
-	UpdatePayment(string, *types.PaymentAttributes) (*types.Payment, error)
+	// Create payments as CreatePayment, all of them or none. A BatchError
+	// gives the index of the payment that could not be created.
+	CreatePayments([]*types.Payment) ([]*types.Payment, error)
+
+	// Create payments read from bank messages as CreatePayments. The charges
+	// the messages carry are kept unless the organisation prices them.
+	ImportPayments([]*types.Payment) ([]*types.Payment, error)
+
+	// Set the parties of payment attributes of an organisation to the saved
+	// accounts and beneficiary they reference, as writing them does, so the
+	// payment can be validated as it would be written. Parties resolved for
+	// previous attributes are kept while they reference the same ones.
+	ResolveReferences(string, *types.PaymentAttributes, *types.PaymentAttributes) error
+
+	// Update payment attributes as the given user and return updated payment,
+	// rejected when the new amount exceeds organisation limits. Approvals are
+	// cleared and required again by the organisation policy, which needs a
+	// user as CreatePayment.
+	UpdatePayment(string, *types.PaymentAttributes, string) (*types.Payment, error)
+
+	// Record approval of payment by the given user and return the payment,
+	// accepted or scheduled once the approvals of its organisation policy
+	// are met
+	ApprovePayment(string, string) (*types.Payment, error)
+
+	// Cancel payment as the given user for an ISO cancellation reason code,
+	// keeping its record
+	CancelPayment(string, string, string) (*types.Payment, error)
+
+	// Refund an accepted payment as the given user and return the refund,
+	// fully or partially up to the amount not refunded yet
+	RefundPayment(string, *types.RefundRequest, string) (*types.Payment, error)
+
+	// Move the execution date of a payment not released yet
+	ReschedulePayment(string, time.Time) (*types.Payment, error)
+
+	// Cancel a payment scheduled for execution as the given user for an ISO
+	// cancellation reason code, keeping its record
+	CancelScheduledPayment(string, string, string) (*types.Payment, error)
 
-	// Delete payment
+	// Delete payment unless it is settled
 	DeletePayment(string) (bool, error)
 
+	// Create the payment of an occurrence of the given standing order.
+	// Occurrences repeat the amount and accounts of the order, so only the
+	// reuse of an end to end reference makes them duplicates.
+	CreateStandingOrderPayment(*types.Payment, string) (*types.Payment, error)
+
 	// Get payment
 	GetPayment(string) (*types.Payment, error)
 
-	// Get slice of payments
-	GetPayments() ([]*types.Payment, error)
+	// Get slice of payments matching filter
+	GetPayments(*types.PaymentFilter) ([]*types.Payment, error)
+
+	// Get payment of an organisation by its end to end reference
+	GetPaymentByReference(string, string) (*types.Payment, error)
+
+	// Get limits of an organisation and their current usage
+	GetLimitUsage(string) (*types.LimitUsage, error)
+
+	// Charges a draft payment would have by its organisation pricing, without
+	// creating it
+	EstimateCharges(*types.Payment) *types.ChargesInformation
+}
+
+// Duplicate payment policies
+const (
+	DuplicateReject = "reject"
+	DuplicateFlag   = "flag"
+)
+
+type DuplicateConfig struct {
+	// How far back payments are compared, no check when zero
+	Window time.Duration
+
+	// Policy of organisations without their own
+	DefaultPolicy string
+
+	// Organisation id to policy
+	OrganisationPolicies map[string]string
+}
+
+// Payment duplicates an existing payment of a rejecting organisation, or
+// reuses the end to end reference of another payment of its organisation
+// when SameReference is set
+type DuplicatePaymentError struct {
+	ExistingId    string
+	SameReference bool
+}
+
+func (e DuplicatePaymentError) Error() string {
+	return fmt.Sprintf("Payment duplicates payment %s", e.ExistingId)
+}
+
+type LimitConfig struct {
+	// Limits of organisations without their own, nil for none
+	DefaultLimits *types.Limits
+
+	// Organisation id to limits
+	OrganisationLimits map[string]*types.Limits
+}
+
+// Payment would exceed a limit of its organisation
+type LimitExceededError struct {
+	Limit string
+	Value float64
+}
+
+func (e LimitExceededError) Error() string {
+	return fmt.Sprintf("Payment exceeds the %s limit of %s", e.Limit, strconv.FormatFloat(e.Value, 'f', -1, 64))
+}
+
+// Payment at Index of a batch could not be created for Err, so no payment of
+// the batch was
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e BatchError) Error() string {
+	return fmt.Sprintf("Payment %d of the batch: %s", e.Index, e.Err.Error())
+}
+
+// Payment is not in a state that allows the request
+type StateError struct {
+	Message string
+}
+
+func (e StateError) Error() string {
+	return e.Message
+}
+
+// Policies the payment service enforces, the pricing of their charges, the
+// calendars processing dates are set by, the rates settlement amounts are
+// converted at and the saved accounts and beneficiaries that parties are
+// resolved from
+type PaymentConfig struct {
+	Duplicates    *DuplicateConfig
+	Limits        *LimitConfig
+	Approvals     *ApprovalConfig
+	Fees          *FeeConfig
+	Calendars     CalendarService
+	Fx            FxService
+	Accounts      AccountService
+	Beneficiaries BeneficiaryService
 }
 
 type PaymentServiceImpl struct {
-	store store.PaymentStore
+	store           store.PaymentStore
+	duplicateConfig *DuplicateConfig
+	limitConfig     *LimitConfig
+	approvalConfig  *ApprovalConfig
+	feeConfig       *FeeConfig
+	calendars       CalendarService
+	fx              FxService
+	accounts        AccountService
+	beneficiaries   BeneficiaryService
 }
 
-func NewPaymentService(paymentStore store.PaymentStore) PaymentService {
+func NewPaymentService(paymentStore store.PaymentStore, config *PaymentConfig) PaymentService {
 	return PaymentServiceImpl{
-		store: paymentStore,
+		store:           paymentStore,
+		duplicateConfig: config.Duplicates,
+		limitConfig:     config.Limits,
+		approvalConfig:  config.Approvals,
+		feeConfig:       config.Fees,
+		calendars:       config.Calendars,
+		fx:              config.Fx,
+		accounts:        config.Accounts,
+		beneficiaries:   config.Beneficiaries,
 	}
 }
 
 func (p PaymentServiceImpl) CreatePayment(payment *types.Payment) (*types.Payment, error) {
-	id, err := uuid.NewUUID()
+	created, err := p.CreatePayments([]*types.Payment{payment})
+	if batchErr, ok := err.(BatchError); ok {
+		return nil, batchErr.Err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return created[0], nil
+}
+
+func (p PaymentServiceImpl) CreatePayments(payments []*types.Payment) ([]*types.Payment, error) {
+	for i, payment := range payments {
+		if err := p.prepareCreate(payment, false); err != nil {
+			return nil, BatchError{Index: i, Err: err}
+		}
+	}
+	return p.writePayments(payments)
+}
+
+func (p PaymentServiceImpl) ImportPayments(payments []*types.Payment) ([]*types.Payment, error) {
+	for i, payment := range payments {
+		if err := p.prepareCreate(payment, true); err != nil {
+			return nil, BatchError{Index: i, Err: err}
+		}
+	}
+	return p.writePayments(payments)
+}
+
+func (p PaymentServiceImpl) CreateStandingOrderPayment(payment *types.Payment, standingOrderId string) (*types.Payment, error) {
+	if err := p.prepareCreate(payment, false); err != nil {
+		return nil, err
+	}
+	payment.StandingOrderId = standingOrderId
+	created, err := p.writePayments([]*types.Payment{payment})
+	if batchErr, ok := err.(BatchError); ok {
+		return nil, batchErr.Err
+	}
 	if err != nil {
 		return nil, err
 	}
+	return created[0], nil
+}
+
+// Write prepared payments, failing with a BatchError for the first payment
+// that cannot be written
+func (p PaymentServiceImpl) writePayments(payments []*types.Payment) ([]*types.Payment, error) {
+	created, err := p.store.CreatePayments(payments, p.checkCreate)
+	if batchErr, ok := err.(store.BatchWriteError); ok {
+		_, err = returnConflict(nil, batchErr.Err)
+		return nil, BatchError{Index: batchErr.Index, Err: err}
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, payment := range created {
+		if payment.Attributes != nil && payment.Attributes.BeneficiaryId != "" {
+			p.beneficiaries.RecordUsage(payment.Attributes.BeneficiaryId)
+		}
+	}
+	return created, nil
+}
+
+// Set the generated and resolved fields of a new payment, keeping the charges
+// of imported payments that their organisation does not price
+func (p PaymentServiceImpl) prepareCreate(payment *types.Payment, imported bool) error {
+	id, err := uuid.NewUUID()
+	if err != nil {
+		return err
+	}
 	payment.Id = id.String()
-	return p.store.CreatePayment(payment)
+	payment.CreatedAt = time.Now().UTC()
+	payment.PossibleDuplicate = false
+	payment.StandingOrderId = ""
+	payment.UpdatedBy = ""
+	payment.Approvals = nil
+	payment.RelatedPaymentId = ""
+	payment.ReturnReason = ""
+	payment.Refunds = nil
+	payment.Cancellation = nil
+	payment.Reconciliation = nil
+	payment.Fx = nil
+	if payment.ExecutionDate != nil {
+		executionDate := payment.ExecutionDate.UTC()
+		payment.ExecutionDate = &executionDate
+	}
+	payment.Status = p.approvalStatus(payment)
+	if payment.Status == types.PaymentPendingApproval && payment.CreatedBy == "" {
+		return UserRequiredError{}
+	}
+	if payment.Attributes == nil {
+		return nil
+	}
+	if err := p.ResolveReferences(payment.OrganisationId, payment.Attributes, nil); err != nil {
+		return err
+	}
+	if err := p.calendars.SetProcessingDate(payment.Attributes, submittedAt(payment)); err != nil {
+		return err
+	}
+	if payment.Fx, err = p.fx.ConvertPayment(payment.Attributes, payment.CreatedAt, nil, nil); err != nil {
+		return err
+	}
+	if !imported || p.pricingRule(payment.OrganisationId, payment.Attributes.PaymentScheme) != nil {
+		payment.Attributes.ChargesInformation = p.charges(payment.OrganisationId, payment.Attributes)
+	}
+	return nil
+}
+
+// Limits and duplicates of a new payment, checked against the payments
+// written before it including those of its batch. Standing order payments
+// are not compared by amount.
+func (p PaymentServiceImpl) checkCreate(payment *types.Payment, reader store.PaymentReader) error {
+	if payment.Attributes != nil {
+		if err := p.checkLimits(reader, payment, true); err != nil {
+			return err
+		}
+	}
+	// Transactions retried after a conflict check the payment again
+	payment.PossibleDuplicate = false
+	if p.duplicateConfig.Window > 0 && payment.StandingOrderId == "" {
+		duplicate, err := reader.FindDuplicate(payment, payment.CreatedAt.Add(-p.duplicateConfig.Window))
+		if err != nil {
+			return err
+		}
+		if duplicate != nil {
+			if p.duplicatePolicy(payment.OrganisationId) == DuplicateReject {
+				return DuplicatePaymentError{ExistingId: duplicate.Id}
+			}
+			payment.PossibleDuplicate = true
+		}
+	}
+	return nil
+}
+
+func (p PaymentServiceImpl) ResolveReferences(organisationId string, attributes *types.PaymentAttributes, previous *types.PaymentAttributes) error {
+	if attributes == nil {
+		return nil
+	}
+	if err := p.accounts.ResolveParties(organisationId, attributes, previous); err != nil {
+		return err
+	}
+	return p.beneficiaries.ResolveBeneficiary(organisationId, attributes, previous)
+}
+
+func (p PaymentServiceImpl) duplicatePolicy(organisationId string) string {
+	if policy, ok := p.duplicateConfig.OrganisationPolicies[organisationId]; ok {
+		return policy
+	}
+	return p.duplicateConfig.DefaultPolicy
+}
+
+func (p PaymentServiceImpl) UpdatePayment(id string, attributes *types.PaymentAttributes, userId string) (*types.Payment, error) {
+	payment, err := p.store.GetPayment(id)
+	if err != nil || payment == nil {
+		return nil, err
+	}
+	if payment.Status == types.PaymentCancelled {
+		return nil, StateError{Message: fmt.Sprintf("Payment %s is cancelled", id)}
+	}
+	if err := checkUnsettled(payment, "updated"); err != nil {
+		return nil, err
+	}
+	if err := p.ResolveReferences(payment.OrganisationId, attributes, payment.Attributes); err != nil {
+		return nil, err
+	}
+	previous, previousRate := payment.Attributes, payment.Fx
+	payment.Attributes = attributes
+	payment.Fx = nil
+	if attributes != nil {
+		// The stored processing date is kept when left out or unchanged, it
+		// was checked when set and may have passed since
+		if keepsProcessingDate(attributes, previous) {
+			attributes.ProcessingDate = previous.ProcessingDate
+		} else if err := p.calendars.SetProcessingDate(attributes, submittedAt(payment)); err != nil {
+			return nil, err
+		}
+		if payment.Fx, err = p.fx.ConvertPayment(attributes, time.Now().UTC(), previous, previousRate); err != nil {
+			return nil, err
+		}
+		attributes.ChargesInformation = p.charges(payment.OrganisationId, attributes)
+	}
+	payment.UpdatedBy = userId
+	payment.Approvals = nil
+	// Released payments are not scheduled again unless they need approving
+	if status := p.approvalStatus(payment); status == types.PaymentPendingApproval || payment.Status == types.PaymentPendingApproval {
+		payment.Status = status
+	}
+	if payment.Status == types.PaymentPendingApproval && userId == "" {
+		return nil, UserRequiredError{}
+	}
+	return returnConflict(p.store.UpdatePayment(payment, types.PaymentUpdated, p.checkUpdate))
+}
+
+func keepsProcessingDate(attributes *types.PaymentAttributes, previous *types.PaymentAttributes) bool {
+	if previous == nil || previous.ProcessingDate == "" {
+		return false
+	}
+	return attributes.ProcessingDate == "" || attributes.ProcessingDate == previous.ProcessingDate
 }
 
-func (p PaymentServiceImpl) UpdatePayment(id string, attributes *types.PaymentAttributes) (*types.Payment, error) {
-	return p.store.UpdatePayment(id, attributes)
+// Limits of updated payment attributes
+func (p PaymentServiceImpl) checkUpdate(payment *types.Payment, reader store.PaymentReader) error {
+	if payment.Attributes == nil {
+		return nil
+	}
+	return p.checkLimits(reader, payment, false)
 }
 
+// Settled payments are kept, deletes are made at the version read so a
+// payment settled meanwhile is not deleted
 func (p PaymentServiceImpl) DeletePayment(id string) (bool, error) {
-	return p.store.DeletePayment(id)
+	payment, err := p.store.GetPayment(id)
+	if err != nil || payment == nil {
+		return false, err
+	}
+	if err := checkUnsettled(payment, "deleted"); err != nil {
+		return false, err
+	}
+	deleted, err := p.store.DeletePayment(payment)
+	if conflictErr, ok := err.(store.VersionConflictError); ok {
+		return false, StateError{Message: conflictErr.Error()}
+	}
+	return deleted, err
 }
 
 func (p PaymentServiceImpl) GetPayment(id string) (*types.Payment, error) {
 	return p.store.GetPayment(id)
 }
 
-func (p PaymentServiceImpl) GetPayments() ([]*types.Payment, error) {
-	return p.store.GetPayments()
+func (p PaymentServiceImpl) GetPayments(filter *types.PaymentFilter) ([]*types.Payment, error) {
+	return p.store.GetPayments(filter)
+}
+
+func (p PaymentServiceImpl) GetPaymentByReference(organisationId string, reference string) (*types.Payment, error) {
+	payments, err := p.store.GetPayments(&types.PaymentFilter{
+		OrganisationId:    organisationId,
+		EndToEndReference: reference,
+	})
+	if err != nil || len(payments) == 0 {
+		return nil, err
+	}
+	return payments[0], nil
+}
+
+func (p PaymentServiceImpl) GetLimitUsage(organisationId string) (*types.LimitUsage, error) {
+	usage := &types.LimitUsage{
+		OrganisationId: organisationId,
+		Limits:         &types.Limits{},
+		Currencies:     make([]*types.CurrencyUsage, 0),
+	}
+	if limits := p.organisationLimits(organisationId); limits != nil {
+		usage.Limits = limits
+	}
+	now := time.Now().UTC()
+	daily, _, err := p.store.SumPayments(organisationId, startOfDay(now), "")
+	if err != nil {
+		return nil, err
+	}
+	monthly, _, err := p.store.SumPayments(organisationId, startOfMonth(now), "")
+	if err != nil {
+		return nil, err
+	}
+	if _, usage.HourlyCount, err = p.store.SumPayments(organisationId, now.Add(-time.Hour), ""); err != nil {
+		return nil, err
+	}
+	// Payments of the day are also payments of the month
+	currencies := make([]string, 0, len(monthly))
+	for currency := range monthly {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	for _, currency := range currencies {
+		usage.Currencies = append(usage.Currencies, &types.CurrencyUsage{
+			Currency:      currency,
+			DailyAmount:   daily[currency],
+			MonthlyAmount: monthly[currency],
+		})
+	}
+	return usage, nil
+}
+
+// Check the amount of a payment against the organisation limits, together
+// with the other payments of the organisation in its currency. The hourly
+// count covers every currency and only applies to new payments.
+func (p PaymentServiceImpl) checkLimits(reader store.PaymentReader, payment *types.Payment, created bool) error {
+	limits := p.organisationLimits(payment.OrganisationId)
+	if limits == nil {
+		return nil
+	}
+	amount := payment.Attributes.Amount
+	currency := payment.Attributes.Currency
+	if limits.MaxAmount > 0 && amount > limits.MaxAmount {
+		return LimitExceededError{Limit: "max_amount", Value: limits.MaxAmount}
+	}
+	now := time.Now().UTC()
+	if limits.DailyAmount > 0 {
+		used, _, err := reader.SumPayments(payment.OrganisationId, startOfDay(now), payment.Id)
+		if err != nil {
+			return err
+		}
+		if used[currency]+amount > limits.DailyAmount {
+			return LimitExceededError{Limit: "daily_amount", Value: limits.DailyAmount}
+		}
+	}
+	if limits.MonthlyAmount > 0 {
+		used, _, err := reader.SumPayments(payment.OrganisationId, startOfMonth(now), payment.Id)
+		if err != nil {
+			return err
+		}
+		if used[currency]+amount > limits.MonthlyAmount {
+			return LimitExceededError{Limit: "monthly_amount", Value: limits.MonthlyAmount}
+		}
+	}
+	if created && limits.HourlyCount > 0 {
+		_, count, err := reader.SumPayments(payment.OrganisationId, now.Add(-time.Hour), payment.Id)
+		if err != nil {
+			return err
+		}
+		if count+1 > limits.HourlyCount {
+			return LimitExceededError{Limit: "hourly_count", Value: float64(limits.HourlyCount)}
+		}
+	}
+	return nil
+}
+
+func (p PaymentServiceImpl) organisationLimits(organisationId string) *types.Limits {
+	if limits, ok := p.limitConfig.OrganisationLimits[organisationId]; ok {
+		return limits
+	}
+	return p.limitConfig.DefaultLimits
+}
+
+// Payments are submitted when written, scheduled payments on their
+// execution date
+func submittedAt(payment *types.Payment) time.Time {
+	now := time.Now().UTC()
+	if payment.ExecutionDate != nil && payment.ExecutionDate.After(now) {
+		return *payment.ExecutionDate
+	}
+	return now
+}
+
+func startOfDay(now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func startOfMonth(now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func returnConflict(payment *types.Payment, err error) (*types.Payment, error) {
+	switch typed := err.(type) {
+	case store.DuplicateReferenceError:
+		return nil, DuplicatePaymentError{ExistingId: typed.ExistingId, SameReference: true}
+	case store.VersionConflictError:
+		return nil, StateError{Message: typed.Error()}
+	case store.QuoteUsedError:
+		return nil, FxError{Field: "quote_id", Message: typed.Error()}
+	}
+	return payment, err
 }
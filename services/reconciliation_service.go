@@ -0,0 +1,195 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/brunovale91/payment-api/iso20022"
+	"github.com/brunovale91/payment-api/store"
+	"github.com/brunovale91/payment-api/types"
+	"github.com/google/uuid"
+)
+
+type ReconciliationService interface {
+
+	// Match camt.053 statement entries against payments of an organisation
+	// made from or to the statement account, mark matched payments reconciled
+	// and return the saved report. Fails with a StateError if a matched
+	// payment changed before the report was saved, nothing is saved.
+	ReconcileStatement(string, []byte) (*types.ReconciliationReport, error)
+
+	// Get reconciliation report
+	GetReport(string) (*types.ReconciliationReport, error)
+
+	// Get slice of reconciliation reports
+	GetReports() ([]*types.ReconciliationReport, error)
+}
+
+type ReconciliationServiceImpl struct {
+	payments        store.PaymentStore
+	reconciliations store.ReconciliationStore
+}
+
+func NewReconciliationService(paymentStore store.PaymentStore, reconciliationStore store.ReconciliationStore) ReconciliationService {
+	return ReconciliationServiceImpl{
+		payments:        paymentStore,
+		reconciliations: reconciliationStore,
+	}
+}
+
+func (r ReconciliationServiceImpl) ReconcileStatement(organisationId string, statement []byte) (*types.ReconciliationReport, error) {
+	entries, err := iso20022.ParseCamt053(statement)
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.NewUUID()
+	if err != nil {
+		return nil, err
+	}
+	report := &types.ReconciliationReport{
+		Id:             id.String(),
+		OrganisationId: organisationId,
+		StatementId:    statementIds(entries),
+		CreatedAt:      time.Now().UTC(),
+		Matched:        make([]*types.ReconciliationMatch, 0),
+		Unmatched:      make([]*types.StatementEntry, 0),
+		Ambiguous:      make([]*types.AmbiguousEntry, 0),
+	}
+
+	// A payment settles once, entries of the same statement cannot share it
+	reconciled := make(map[string]bool)
+	for _, entry := range entries {
+		candidates, err := r.findCandidates(organisationId, entry, reconciled)
+		if err != nil {
+			return nil, err
+		}
+		switch len(candidates) {
+		case 0:
+			report.Unmatched = append(report.Unmatched, entry)
+		case 1:
+			reconciled[candidates[0].Id] = true
+			report.Matched = append(report.Matched, &types.ReconciliationMatch{
+				Entry:     entry,
+				PaymentId: candidates[0].Id,
+			})
+		default:
+			ids := make([]string, 0, len(candidates))
+			for _, candidate := range candidates {
+				ids = append(ids, candidate.Id)
+			}
+			report.Ambiguous = append(report.Ambiguous, &types.AmbiguousEntry{
+				Entry:      entry,
+				PaymentIds: ids,
+			})
+		}
+	}
+	saved, err := r.payments.ReconcilePayments(report)
+	if conflict, ok := err.(store.VersionConflictError); ok {
+		return nil, StateError{Message: fmt.Sprintf("Payment %s was reconciled or changed while reconciling the statement", conflict.Id)}
+	}
+	return saved, err
+}
+
+func (r ReconciliationServiceImpl) GetReport(id string) (*types.ReconciliationReport, error) {
+	return r.reconciliations.GetReport(id)
+}
+
+func (r ReconciliationServiceImpl) GetReports() ([]*types.ReconciliationReport, error) {
+	return r.reconciliations.GetReports()
+}
+
+// Settled, unreconciled payments of the organisation from or to the statement
+// account with the entry end to end reference, amount and currency, and a
+// matching counterparty when the entry names one
+func (r ReconciliationServiceImpl) findCandidates(organisationId string, entry *types.StatementEntry, reconciled map[string]bool) ([]*types.Payment, error) {
+	if entry.EndToEndReference == "" || entry.EndToEndReference == "NOTPROVIDED" || entry.Account == "" {
+		return nil, nil
+	}
+	payments, err := r.payments.GetPayments(&types.PaymentFilter{
+		OrganisationId:    organisationId,
+		EndToEndReference: entry.EndToEndReference,
+	})
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]*types.Payment, 0)
+	for _, payment := range payments {
+		if payment.Reconciliation != nil || reconciled[payment.Id] || payment.Attributes == nil {
+			continue
+		}
+		if payment.Status == types.PaymentPendingApproval || payment.Status == types.PaymentScheduled ||
+			payment.Status == types.PaymentCancelled {
+			continue
+		}
+		if !sameAccount(payment.Attributes, entry) || !sameAmount(payment.Attributes, entry) ||
+			!sameCounterparty(payment.Attributes, entry) {
+			continue
+		}
+		candidates = append(candidates, payment)
+	}
+	return candidates, nil
+}
+
+// Money sent leaves from the payment debtor account, money received arrives
+// in the beneficiary account. Account numbers alone are only compared with the
+// bank when the party is identified by BIC.
+func sameAccount(attributes *types.PaymentAttributes, entry *types.StatementEntry) bool {
+	party := attributes.DebtorParty
+	if entry.CreditDebit == "CRDT" {
+		party = attributes.BeneficiaryParty
+	}
+	if party == nil || (entry.Account != party.AccountNumber && entry.Account != party.BankId) {
+		return false
+	}
+	if entry.AccountBankId == "" || party.BankIdCode != "SWBIC" {
+		return true
+	}
+	return bankCode(entry.AccountBankId) == bankCode(party.BankId)
+}
+
+// Institution part of a BIC, the same for all of its branches
+func bankCode(bic string) string {
+	if len(bic) > 8 {
+		return strings.ToUpper(bic[:8])
+	}
+	return strings.ToUpper(bic)
+}
+
+func sameAmount(attributes *types.PaymentAttributes, entry *types.StatementEntry) bool {
+	if attributes.Currency != "" && entry.Currency != "" && attributes.Currency != entry.Currency {
+		return false
+	}
+	return math.Round(attributes.Amount*100) == math.Round(entry.Amount*100)
+}
+
+// Money received is matched on the payment debtor, money sent on the beneficiary
+func sameCounterparty(attributes *types.PaymentAttributes, entry *types.StatementEntry) bool {
+	party := attributes.BeneficiaryParty
+	if entry.CreditDebit == "CRDT" {
+		party = attributes.DebtorParty
+	}
+	if party == nil {
+		return false
+	}
+	if entry.CounterpartyAccount != "" {
+		return entry.CounterpartyAccount == party.AccountNumber || entry.CounterpartyAccount == party.BankId
+	}
+	if entry.CounterpartyName != "" {
+		return strings.EqualFold(strings.TrimSpace(entry.CounterpartyName), strings.TrimSpace(party.Name))
+	}
+	return true
+}
+
+func statementIds(entries []*types.StatementEntry) string {
+	ids := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		if !seen[entry.StatementId] {
+			seen[entry.StatementId] = true
+			ids = append(ids, entry.StatementId)
+		}
+	}
+	return strings.Join(ids, ",")
+}
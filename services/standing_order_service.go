@@ -0,0 +1,257 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/brunovale91/payment-api/store"
+	"github.com/brunovale91/payment-api/types"
+	"github.com/google/uuid"
+)
+
+type StandingOrderService interface {
+
+	// Generate id and create standing order, active from the first
+	// occurrence of its recurrence
+	CreateStandingOrder(*types.StandingOrder) (*types.StandingOrder, error)
+
+	// Get standing order
+	GetStandingOrder(string) (*types.StandingOrder, error)
+
+	// Get standing orders, of one organisation when the id is not empty
+	GetStandingOrders(string) ([]*types.StandingOrder, error)
+
+	// Stop generating payments of an active standing order
+	PauseStandingOrder(string) (*types.StandingOrder, error)
+
+	// Resume a paused standing order, occurrences due while it was paused
+	// are skipped
+	ResumeStandingOrder(string) (*types.StandingOrder, error)
+
+	// Generate payments of up to limit standing orders due at the given time
+	// and return how many were generated. Orders failing to generate are
+	// logged and left to the next run.
+	GenerateDue(time.Time, int) (int, error)
+}
+
+// The clock is the one of the scheduler generating payments, so resumed
+// orders skip the occurrences it would otherwise generate
+type StandingOrderServiceImpl struct {
+	store     store.StandingOrderStore
+	payments  PaymentService
+	calendars CalendarService
+	clock     Clock
+}
+
+func NewStandingOrderService(standingOrderStore store.StandingOrderStore, paymentService PaymentService, calendars CalendarService, clock Clock) StandingOrderService {
+	return StandingOrderServiceImpl{
+		store:     standingOrderStore,
+		payments:  paymentService,
+		calendars: calendars,
+		clock:     clock,
+	}
+}
+
+func (s StandingOrderServiceImpl) CreateStandingOrder(order *types.StandingOrder) (*types.StandingOrder, error) {
+	id, err := uuid.NewUUID()
+	if err != nil {
+		return nil, err
+	}
+	order.Id = id.String()
+	order.Version = 0
+	order.Status = types.StandingOrderActive
+	order.CreatedAt = s.clock.Now()
+	order.Occurrences = 0
+	order.Generated = nil
+	recurrence := order.Recurrence
+	if recurrence.Interval == 0 {
+		recurrence.Interval = 1
+	}
+	startDate := recurrence.StartDate.UTC()
+	recurrence.StartDate = &startDate
+	if recurrence.EndDate != nil {
+		endDate := recurrence.EndDate.UTC()
+		recurrence.EndDate = &endDate
+	}
+	s.scheduleNext(order)
+	return s.store.CreateStandingOrder(order)
+}
+
+func (s StandingOrderServiceImpl) GetStandingOrder(id string) (*types.StandingOrder, error) {
+	return s.store.GetStandingOrder(id)
+}
+
+func (s StandingOrderServiceImpl) GetStandingOrders(organisationId string) ([]*types.StandingOrder, error) {
+	return s.store.GetStandingOrders(organisationId)
+}
+
+func (s StandingOrderServiceImpl) PauseStandingOrder(id string) (*types.StandingOrder, error) {
+	order, err := s.store.GetStandingOrder(id)
+	if err != nil || order == nil {
+		return nil, err
+	}
+	if order.Status != types.StandingOrderActive {
+		return nil, StateError{Message: fmt.Sprintf("Standing order %s is not active", id)}
+	}
+	order.Status = types.StandingOrderPaused
+	return returnOrderConflict(s.store.UpdateStandingOrder(order))
+}
+
+func (s StandingOrderServiceImpl) ResumeStandingOrder(id string) (*types.StandingOrder, error) {
+	order, err := s.store.GetStandingOrder(id)
+	if err != nil || order == nil {
+		return nil, err
+	}
+	if order.Status != types.StandingOrderPaused {
+		return nil, StateError{Message: fmt.Sprintf("Standing order %s is not paused", id)}
+	}
+	order.Status = types.StandingOrderActive
+	now := s.clock.Now()
+	for order.Status == types.StandingOrderActive && order.NextExecutionDate.Before(now) {
+		order.Occurrences++
+		s.scheduleNext(order)
+	}
+	return returnOrderConflict(s.store.UpdateStandingOrder(order))
+}
+
+func (s StandingOrderServiceImpl) GenerateDue(now time.Time, limit int) (int, error) {
+	orders, err := s.store.GetDueStandingOrders(now, int64(limit))
+	if err != nil {
+		return 0, err
+	}
+	generated := 0
+	for _, order := range orders {
+		count, err := s.generateOrder(order, now)
+		generated += count
+		if err != nil {
+			log.Printf("Error generating payments of standing order %s: %s", order.Id, err.Error())
+		}
+	}
+	return generated, nil
+}
+
+// Generate every occurrence of a standing order due at the given time,
+// saving it after each one. A standing order changed meanwhile, e.g. by
+// another replica, is left to the next run.
+func (s StandingOrderServiceImpl) generateOrder(order *types.StandingOrder, now time.Time) (int, error) {
+	generated := 0
+	for order.Status == types.StandingOrderActive && !order.NextExecutionDate.After(now) {
+		payment, err := s.generatePayment(order, *order.NextExecutionDate)
+		if err != nil {
+			return generated, err
+		}
+		if payment.PaymentId != "" {
+			generated++
+		}
+		order.Generated = append(order.Generated, payment)
+		order.Occurrences++
+		s.scheduleNext(order)
+		id := order.Id
+		order, err = s.store.UpdateStandingOrder(order)
+		if _, ok := err.(store.VersionConflictError); ok {
+			log.Printf("Standing order %s changed while generating payments, left to the next run", id)
+			return generated, nil
+		}
+		if err != nil || order == nil {
+			return generated, err
+		}
+	}
+	return generated, nil
+}
+
+// Create the payment of the next occurrence of a standing order. Its end to
+// end reference is derived from the occurrence, so a payment created by an
+// earlier attempt is found instead of created twice, and occurrences caught
+// up in one run are not duplicates of each other. Quotes expire, so payments
+// settled in another currency convert at the rate of the day. Payments
+// rejected by organisation limits, for want of a rate or of a referenced
+// account or verified beneficiary are recorded with the reason.
+func (s StandingOrderServiceImpl) generatePayment(order *types.StandingOrder, executionDate time.Time) (*types.GeneratedPayment, error) {
+	occurrence := order.Occurrences + 1
+	attributes := *order.Template
+	attributes.EndToEndReference = fmt.Sprintf("%s-%d", order.Template.EndToEndReference, occurrence)
+	attributes.ProcessingDate = ""
+	attributes.QuoteId = ""
+	created, err := s.payments.CreateStandingOrderPayment(&types.Payment{
+		Type:           "Payment",
+		OrganisationId: order.OrganisationId,
+		Attributes:     &attributes,
+		ExecutionDate:  &executionDate,
+		CreatedBy:      order.CreatedBy,
+	}, order.Id)
+	generated := &types.GeneratedPayment{
+		Occurrence:    occurrence,
+		ExecutionDate: executionDate,
+		GeneratedAt:   s.clock.Now(),
+	}
+	switch typed := err.(type) {
+	case nil:
+		generated.PaymentId = created.Id
+	case DuplicatePaymentError:
+		if typed.SameReference {
+			generated.PaymentId = typed.ExistingId
+		} else {
+			generated.Error = typed.Error()
+		}
+	case LimitExceededError:
+		generated.Error = typed.Error()
+	case FxError:
+		generated.Error = typed.Error()
+	case AccountError:
+		generated.Error = typed.Error()
+	case BeneficiaryError:
+		generated.Error = typed.Error()
+	case UserRequiredError:
+		generated.Error = typed.Error()
+	default:
+		return nil, err
+	}
+	return generated, nil
+}
+
+// Set the execution date of the next occurrence, rolled forward to a
+// business day of the template calendar, completing the standing order after
+// its last one
+func (s StandingOrderServiceImpl) scheduleNext(order *types.StandingOrder) {
+	recurrence := order.Recurrence
+	if recurrence.Count > 0 && order.Occurrences >= recurrence.Count {
+		order.Status = types.StandingOrderCompleted
+		order.NextExecutionDate = nil
+		return
+	}
+	date := occurrenceDate(recurrence, order.Occurrences)
+	if recurrence.EndDate != nil && date.After(*recurrence.EndDate) {
+		order.Status = types.StandingOrderCompleted
+		order.NextExecutionDate = nil
+		return
+	}
+	executionDate := s.calendars.PaymentCalendar(order.Template).RollForward(date)
+	order.NextExecutionDate = &executionDate
+}
+
+// Date of the nth occurrence counting from 0, monthly occurrences fall on
+// the last day of months shorter than the start day
+func occurrenceDate(recurrence *types.Recurrence, n int) time.Time {
+	start := *recurrence.StartDate
+	switch recurrence.Frequency {
+	case types.Weekly:
+		return start.AddDate(0, 0, 7*n*recurrence.Interval)
+	case types.Monthly:
+		month := time.Date(start.Year(), start.Month()+time.Month(n*recurrence.Interval), 1,
+			start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+		day := start.Day()
+		if lastDay := month.AddDate(0, 1, -1).Day(); day > lastDay {
+			day = lastDay
+		}
+		return month.AddDate(0, 0, day-1)
+	}
+	return start.AddDate(0, 0, n*recurrence.Interval)
+}
+
+func returnOrderConflict(order *types.StandingOrder, err error) (*types.StandingOrder, error) {
+	if conflict, ok := err.(store.VersionConflictError); ok {
+		return nil, StateError{Message: conflict.Error()}
+	}
+	return order, err
+}
@@ -0,0 +1,37 @@
+package store
+
+import (
+	"github.com/brunovale91/payment-api/types"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func accountToDoc(account *types.Account) bson.M {
+	return bson.M{
+		"_id":               account.Id,
+		"Type":              account.Type,
+		"Version":           account.Version,
+		"OrganisationId":    account.OrganisationId,
+		"BankId":            account.BankId,
+		"BankIdCode":        account.BankIdCode,
+		"AccountNumber":     account.AccountNumber,
+		"AccountNumberCode": account.AccountNumberCode,
+		"Name":              account.Name,
+		"CreatedAt":         account.CreatedAt,
+	}
+}
+
+func docToAccount(account bson.D) *types.Account {
+	accountBson := account.Map()
+	return &types.Account{
+		Id:                accountBson["_id"].(string),
+		Type:              docToString(accountBson["Type"]),
+		Version:           accountBson["Version"].(int64),
+		OrganisationId:    docToString(accountBson["OrganisationId"]),
+		BankId:            docToString(accountBson["BankId"]),
+		BankIdCode:        docToString(accountBson["BankIdCode"]),
+		AccountNumber:     docToString(accountBson["AccountNumber"]),
+		AccountNumberCode: docToString(accountBson["AccountNumberCode"]),
+		Name:              docToString(accountBson["Name"]),
+		CreatedAt:         docToTime(accountBson["CreatedAt"]),
+	}
+}
@@ -0,0 +1,142 @@
+package store
+
+import (
+	"context"
+	"log"
+
+	"github.com/brunovale91/payment-api/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type AccountStore interface {
+
+	// Save account
+	CreateAccount(*types.Account) (*types.Account, error)
+
+	// Update account details and return it. Fails with a
+	// VersionConflictError if its version changed since it was read.
+	UpdateAccount(*types.Account) (*types.Account, error)
+
+	// Delete account
+	DeleteAccount(string) (bool, error)
+
+	// Get account
+	GetAccount(string) (*types.Account, error)
+
+	// Get accounts, of one organisation when the id is not empty
+	GetAccounts(string) ([]*types.Account, error)
+}
+
+type AccountStoreImpl struct {
+	collection *mongo.Collection
+}
+
+func NewAccountStore(config *PaymentStoreConfig) (AccountStore, error) {
+	client, err := connect(config)
+	if err != nil {
+		return nil, err
+	}
+	collection := client.Database(config.Database).Collection(config.AccountCollection)
+	_, err = collection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{{Key: "OrganisationId", Value: 1}, {Key: "CreatedAt", Value: 1}},
+	})
+	if err != nil {
+		log.Printf("Error creating account index: %s", err.Error())
+		return nil, err
+	}
+	return AccountStoreImpl{
+		collection: collection,
+	}, nil
+}
+
+func (s AccountStoreImpl) CreateAccount(account *types.Account) (*types.Account, error) {
+	_, err := s.collection.InsertOne(context.Background(), accountToDoc(account))
+	if err != nil {
+		log.Printf("Error creating account with id %s: %s", account.Id, err.Error())
+		return nil, err
+	}
+	return account, nil
+}
+
+func (s AccountStoreImpl) UpdateAccount(account *types.Account) (*types.Account, error) {
+	updateDoc := bson.M{
+		"$inc": bson.M{
+			"Version": 1,
+		},
+		"$set": bson.M{
+			"BankId":            account.BankId,
+			"BankIdCode":        account.BankIdCode,
+			"AccountNumber":     account.AccountNumber,
+			"AccountNumberCode": account.AccountNumberCode,
+			"Name":              account.Name,
+		},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	elem := &bson.D{}
+	filter := bson.M{"_id": account.Id, "Version": account.Version}
+	err := s.collection.FindOneAndUpdate(context.Background(), filter, updateDoc, opts).Decode(elem)
+	if err != nil {
+		if isNoDocuments(err.Error()) {
+			existing, err := s.GetAccount(account.Id)
+			if err != nil || existing == nil {
+				return nil, err
+			}
+			return nil, VersionConflictError{Id: account.Id}
+		}
+		log.Printf("Error updating account with id %s: %s", account.Id, err.Error())
+		return nil, err
+	}
+	return docToAccount(*elem), nil
+}
+
+func (s AccountStoreImpl) DeleteAccount(id string) (bool, error) {
+	result, err := s.collection.DeleteOne(context.Background(), bson.M{"_id": id})
+	if err != nil {
+		log.Printf("Error deleting account with id %s: %s", id, err.Error())
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+func (s AccountStoreImpl) GetAccount(id string) (*types.Account, error) {
+	elem := &bson.D{}
+	err := s.collection.FindOne(context.Background(), bson.M{"_id": id}).Decode(elem)
+	if err != nil {
+		if isNoDocuments(err.Error()) {
+			return nil, nil
+		}
+		log.Printf("Error fetching account with id %s: %s", id, err.Error())
+		return nil, err
+	}
+	return docToAccount(*elem), nil
+}
+
+func (s AccountStoreImpl) GetAccounts(organisationId string) ([]*types.Account, error) {
+	filter := bson.M{}
+	if organisationId != "" {
+		filter["OrganisationId"] = organisationId
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "CreatedAt", Value: 1}})
+	cursor, err := s.collection.Find(context.Background(), filter, opts)
+	if err != nil {
+		log.Printf("Error fetching accounts: %s", err)
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	accounts := make([]*types.Account, 0)
+	for cursor.Next(context.Background()) {
+		elem := &bson.D{}
+		if err := cursor.Decode(elem); err != nil {
+			log.Printf("Error parsing account: %s", err)
+			return nil, err
+		}
+		accounts = append(accounts, docToAccount(*elem))
+	}
+	if err := cursor.Err(); err != nil {
+		log.Printf("Error fetching accounts: %s", err)
+		return nil, err
+	}
+	return accounts, nil
+}
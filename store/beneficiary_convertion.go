@@ -0,0 +1,45 @@
+package store
+
+import (
+	"github.com/brunovale91/payment-api/types"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func beneficiaryToDoc(beneficiary *types.Beneficiary) bson.M {
+	return bson.M{
+		"_id":            beneficiary.Id,
+		"Type":           beneficiary.Type,
+		"Version":        beneficiary.Version,
+		"OrganisationId": beneficiary.OrganisationId,
+		"Name":           beneficiary.Name,
+		"Party":          partyToDoc(beneficiary.Party),
+		"Status":         beneficiary.Status,
+		"CreatedBy":      beneficiary.CreatedBy,
+		"CreatedAt":      beneficiary.CreatedAt,
+		"UpdatedBy":      beneficiary.UpdatedBy,
+		"VerifiedBy":     beneficiary.VerifiedBy,
+		"VerifiedAt":     beneficiary.VerifiedAt,
+		"UsageCount":     beneficiary.UsageCount,
+		"LastUsedAt":     beneficiary.LastUsedAt,
+	}
+}
+
+func docToBeneficiary(beneficiary bson.D) *types.Beneficiary {
+	beneficiaryBson := beneficiary.Map()
+	return &types.Beneficiary{
+		Id:             beneficiaryBson["_id"].(string),
+		Type:           docToString(beneficiaryBson["Type"]),
+		Version:        beneficiaryBson["Version"].(int64),
+		OrganisationId: docToString(beneficiaryBson["OrganisationId"]),
+		Name:           docToString(beneficiaryBson["Name"]),
+		Party:          docToParty(beneficiaryBson["Party"]),
+		Status:         docToString(beneficiaryBson["Status"]),
+		CreatedBy:      docToString(beneficiaryBson["CreatedBy"]),
+		CreatedAt:      docToTime(beneficiaryBson["CreatedAt"]),
+		UpdatedBy:      docToString(beneficiaryBson["UpdatedBy"]),
+		VerifiedBy:     docToString(beneficiaryBson["VerifiedBy"]),
+		VerifiedAt:     docToTimePtr(beneficiaryBson["VerifiedAt"]),
+		UsageCount:     docToInt(beneficiaryBson["UsageCount"]),
+		LastUsedAt:     docToTimePtr(beneficiaryBson["LastUsedAt"]),
+	}
+}
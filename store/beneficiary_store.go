@@ -0,0 +1,167 @@
+package store
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/brunovale91/payment-api/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type BeneficiaryStore interface {
+
+	// Save beneficiary
+	CreateBeneficiary(*types.Beneficiary) (*types.Beneficiary, error)
+
+	// Update beneficiary details and verification and return it. Fails with
+	// a VersionConflictError if its version changed since it was read.
+	UpdateBeneficiary(*types.Beneficiary) (*types.Beneficiary, error)
+
+	// Count a payment created to the beneficiary at the given time
+	RecordUsage(string, time.Time) error
+
+	// Delete beneficiary
+	DeleteBeneficiary(string) (bool, error)
+
+	// Get beneficiary
+	GetBeneficiary(string) (*types.Beneficiary, error)
+
+	// Get beneficiaries of an organisation with names containing the given
+	// text, ignoring case, most used first
+	GetBeneficiaries(string, string) ([]*types.Beneficiary, error)
+}
+
+type BeneficiaryStoreImpl struct {
+	collection *mongo.Collection
+}
+
+func NewBeneficiaryStore(config *PaymentStoreConfig) (BeneficiaryStore, error) {
+	client, err := connect(config)
+	if err != nil {
+		return nil, err
+	}
+	collection := client.Database(config.Database).Collection(config.BeneficiaryCollection)
+	_, err = collection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{{Key: "OrganisationId", Value: 1}, {Key: "UsageCount", Value: -1}},
+	})
+	if err != nil {
+		log.Printf("Error creating beneficiary index: %s", err.Error())
+		return nil, err
+	}
+	return BeneficiaryStoreImpl{
+		collection: collection,
+	}, nil
+}
+
+func (s BeneficiaryStoreImpl) CreateBeneficiary(beneficiary *types.Beneficiary) (*types.Beneficiary, error) {
+	_, err := s.collection.InsertOne(context.Background(), beneficiaryToDoc(beneficiary))
+	if err != nil {
+		log.Printf("Error creating beneficiary with id %s: %s", beneficiary.Id, err.Error())
+		return nil, err
+	}
+	return beneficiary, nil
+}
+
+func (s BeneficiaryStoreImpl) UpdateBeneficiary(beneficiary *types.Beneficiary) (*types.Beneficiary, error) {
+	updateDoc := bson.M{
+		"$inc": bson.M{
+			"Version": 1,
+		},
+		"$set": bson.M{
+			"Name":       beneficiary.Name,
+			"Party":      partyToDoc(beneficiary.Party),
+			"Status":     beneficiary.Status,
+			"UpdatedBy":  beneficiary.UpdatedBy,
+			"VerifiedBy": beneficiary.VerifiedBy,
+			"VerifiedAt": beneficiary.VerifiedAt,
+		},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	elem := &bson.D{}
+	filter := bson.M{"_id": beneficiary.Id, "Version": beneficiary.Version}
+	err := s.collection.FindOneAndUpdate(context.Background(), filter, updateDoc, opts).Decode(elem)
+	if err != nil {
+		if isNoDocuments(err.Error()) {
+			existing, err := s.GetBeneficiary(beneficiary.Id)
+			if err != nil || existing == nil {
+				return nil, err
+			}
+			return nil, VersionConflictError{Id: beneficiary.Id}
+		}
+		log.Printf("Error updating beneficiary with id %s: %s", beneficiary.Id, err.Error())
+		return nil, err
+	}
+	return docToBeneficiary(*elem), nil
+}
+
+// Usage does not change the version, so it does not conflict with edits
+func (s BeneficiaryStoreImpl) RecordUsage(id string, at time.Time) error {
+	updateDoc := bson.M{
+		"$inc": bson.M{
+			"UsageCount": 1,
+		},
+		"$set": bson.M{
+			"LastUsedAt": at,
+		},
+	}
+	if _, err := s.collection.UpdateOne(context.Background(), bson.M{"_id": id}, updateDoc); err != nil {
+		log.Printf("Error recording usage of beneficiary with id %s: %s", id, err.Error())
+		return err
+	}
+	return nil
+}
+
+func (s BeneficiaryStoreImpl) DeleteBeneficiary(id string) (bool, error) {
+	result, err := s.collection.DeleteOne(context.Background(), bson.M{"_id": id})
+	if err != nil {
+		log.Printf("Error deleting beneficiary with id %s: %s", id, err.Error())
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+func (s BeneficiaryStoreImpl) GetBeneficiary(id string) (*types.Beneficiary, error) {
+	elem := &bson.D{}
+	err := s.collection.FindOne(context.Background(), bson.M{"_id": id}).Decode(elem)
+	if err != nil {
+		if isNoDocuments(err.Error()) {
+			return nil, nil
+		}
+		log.Printf("Error fetching beneficiary with id %s: %s", id, err.Error())
+		return nil, err
+	}
+	return docToBeneficiary(*elem), nil
+}
+
+func (s BeneficiaryStoreImpl) GetBeneficiaries(organisationId string, name string) ([]*types.Beneficiary, error) {
+	filter := bson.M{"OrganisationId": organisationId}
+	if name != "" {
+		filter["Name"] = primitive.Regex{Pattern: regexp.QuoteMeta(name), Options: "i"}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "UsageCount", Value: -1}, {Key: "Name", Value: 1}})
+	cursor, err := s.collection.Find(context.Background(), filter, opts)
+	if err != nil {
+		log.Printf("Error fetching beneficiaries: %s", err)
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	beneficiaries := make([]*types.Beneficiary, 0)
+	for cursor.Next(context.Background()) {
+		elem := &bson.D{}
+		if err := cursor.Decode(elem); err != nil {
+			log.Printf("Error parsing beneficiary: %s", err)
+			return nil, err
+		}
+		beneficiaries = append(beneficiaries, docToBeneficiary(*elem))
+	}
+	if err := cursor.Err(); err != nil {
+		log.Printf("Error fetching beneficiaries: %s", err)
+		return nil, err
+	}
+	return beneficiaries, nil
+}
@@ -0,0 +1,200 @@
+package store
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/brunovale91/payment-api/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type EventStream interface {
+
+	// Stream events written after the lastEventId position until ctx is done,
+	// optionally restricted to one organisation. An empty lastEventId streams
+	// events written from now on.
+	Subscribe(ctx context.Context, organisationId string, lastEventId string) (<-chan *types.PaymentEvent, error)
+}
+
+// Position to resume a stream from is not one the stream gave
+type CursorError struct {
+	Cursor string
+}
+
+func (e CursorError) Error() string {
+	return fmt.Sprintf("Invalid event stream position %s", e.Cursor)
+}
+
+// Stream backed by a Mongo change stream on the outbox collection, positions
+// are change stream resume tokens
+type ChangeStreamImpl struct {
+	outbox *mongo.Collection
+}
+
+func NewChangeStream(config *PaymentStoreConfig) (EventStream, error) {
+	client, err := connect(config)
+	if err != nil {
+		return nil, err
+	}
+	return ChangeStreamImpl{
+		outbox: client.Database(config.Database).Collection(config.OutboxCollection),
+	}, nil
+}
+
+func (s ChangeStreamImpl) Subscribe(ctx context.Context, organisationId string, lastEventId string) (<-chan *types.PaymentEvent, error) {
+	match := bson.D{{Key: "operationType", Value: "insert"}}
+	if organisationId != "" {
+		match = append(match, bson.E{Key: "fullDocument.OrganisationId", Value: organisationId})
+	}
+	opts := options.ChangeStream()
+	if _, err := hex.DecodeString(lastEventId); err != nil {
+		return nil, CursorError{Cursor: lastEventId}
+	}
+	if lastEventId != "" {
+		opts.SetResumeAfter(bson.D{{Key: "_data", Value: lastEventId}})
+	}
+	stream, err := s.outbox.Watch(ctx, mongo.Pipeline{{{Key: "$match", Value: match}}}, opts)
+	if err != nil {
+		log.Printf("Error watching events: %s", err.Error())
+		return nil, err
+	}
+
+	events := make(chan *types.PaymentEvent)
+	go func() {
+		defer close(events)
+		defer stream.Close(context.Background())
+		for stream.Next(ctx) {
+			change := &bson.D{}
+			if err := stream.Decode(change); err != nil {
+				log.Printf("Error parsing change: %s", err.Error())
+				return
+			}
+			changeBson := change.Map()
+			event := docToEvent(changeBson["fullDocument"].(bson.D))
+			event.Cursor = docToResumeToken(changeBson["_id"])
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err := stream.Err(); err != nil && ctx.Err() == nil {
+			log.Printf("Error watching events: %s", err.Error())
+		}
+	}()
+	return events, nil
+}
+
+func docToResumeToken(token interface{}) string {
+	if tokenBson, ok := token.(bson.D); ok {
+		if data, ok := tokenBson.Map()["_data"].(string); ok {
+			return data
+		}
+	}
+	return ""
+}
+
+// Fallback stream that polls an outbox store, for deployments without
+// change streams. Positions are event sequences when streaming one
+// organisation and outbox event ids otherwise.
+type PollingStreamImpl struct {
+	outbox    OutboxStore
+	interval  time.Duration
+	batchSize int64
+}
+
+func NewPollingStream(outbox OutboxStore, interval time.Duration, batchSize int64) EventStream {
+	return PollingStreamImpl{
+		outbox:    outbox,
+		interval:  interval,
+		batchSize: batchSize,
+	}
+}
+
+func (s PollingStreamImpl) Subscribe(ctx context.Context, organisationId string, lastEventId string) (<-chan *types.PaymentEvent, error) {
+	poll, err := s.poller(organisationId, lastEventId)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan *types.PaymentEvent)
+	go func() {
+		defer close(events)
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			// Failed polls are retried on the next tick from the same position
+			batch, err := poll()
+			if err != nil {
+				log.Printf("Error polling events: %s", err.Error())
+			}
+			for _, event := range batch {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// Function returning the next events after lastEventId each time it is
+// called, setting their cursors
+func (s PollingStreamImpl) poller(organisationId string, lastEventId string) (func() ([]*types.PaymentEvent, error), error) {
+	if organisationId == "" {
+		if lastEventId == "" {
+			lastEventId = objectIdAt(time.Now().Add(-eventHoldBack)).Hex()
+		} else if _, err := primitive.ObjectIDFromHex(lastEventId); err != nil {
+			return nil, CursorError{Cursor: lastEventId}
+		}
+		return func() ([]*types.PaymentEvent, error) {
+			batch, err := s.outbox.GetAllEventsAfter(lastEventId, s.batchSize)
+			for _, event := range batch {
+				event.Cursor = event.Id
+				lastEventId = event.Id
+			}
+			return batch, err
+		}, nil
+	}
+
+	var sequence int64
+	var err error
+	if lastEventId == "" {
+		sequence, err = s.outbox.GetLastSequence(organisationId)
+	} else if sequence, err = strconv.ParseInt(lastEventId, 10, 64); err != nil || sequence < 0 {
+		return nil, CursorError{Cursor: lastEventId}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return func() ([]*types.PaymentEvent, error) {
+		batch, err := s.outbox.GetEventsAfter(organisationId, sequence, s.batchSize)
+		for _, event := range batch {
+			event.Cursor = strconv.FormatInt(event.Sequence, 10)
+			sequence = event.Sequence
+		}
+		return batch, err
+	}, nil
+}
+
+// Smallest object id generated at the given time
+func objectIdAt(t time.Time) primitive.ObjectID {
+	var oid primitive.ObjectID
+	binary.BigEndian.PutUint32(oid[0:4], uint32(t.Unix()))
+	return oid
+}
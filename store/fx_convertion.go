@@ -0,0 +1,60 @@
+package store
+
+import (
+	"github.com/brunovale91/payment-api/types"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func rateToDoc(rate *types.FxRate) bson.M {
+	return bson.M{
+		"_id":           rate.Id,
+		"BaseCurrency":  rate.BaseCurrency,
+		"QuoteCurrency": rate.QuoteCurrency,
+		"Rate":          rate.Rate,
+		"EffectiveAt":   rate.EffectiveAt,
+		"CreatedAt":     rate.CreatedAt,
+	}
+}
+
+func docToRate(rate bson.D) *types.FxRate {
+	rateBson := rate.Map()
+	return &types.FxRate{
+		Id:            rateBson["_id"].(string),
+		BaseCurrency:  docToString(rateBson["BaseCurrency"]),
+		QuoteCurrency: docToString(rateBson["QuoteCurrency"]),
+		Rate:          docToFloat(rateBson["Rate"]),
+		EffectiveAt:   docToTime(rateBson["EffectiveAt"]),
+		CreatedAt:     docToTime(rateBson["CreatedAt"]),
+	}
+}
+
+func quoteToDoc(quote *types.FxQuote) bson.M {
+	return bson.M{
+		"_id":                quote.Id,
+		"InstructedCurrency": quote.InstructedCurrency,
+		"InstructedAmount":   quote.InstructedAmount,
+		"SettlementCurrency": quote.SettlementCurrency,
+		"SettlementAmount":   quote.SettlementAmount,
+		"Rate":               quote.Rate,
+		"RateId":             quote.RateId,
+		"CreatedAt":          quote.CreatedAt,
+		"ExpiresAt":          quote.ExpiresAt,
+		"PaymentId":          quote.PaymentId,
+	}
+}
+
+func docToQuote(quote bson.D) *types.FxQuote {
+	quoteBson := quote.Map()
+	return &types.FxQuote{
+		Id:                 quoteBson["_id"].(string),
+		InstructedCurrency: docToString(quoteBson["InstructedCurrency"]),
+		InstructedAmount:   docToFloat(quoteBson["InstructedAmount"]),
+		SettlementCurrency: docToString(quoteBson["SettlementCurrency"]),
+		SettlementAmount:   docToFloat(quoteBson["SettlementAmount"]),
+		Rate:               docToFloat(quoteBson["Rate"]),
+		RateId:             docToString(quoteBson["RateId"]),
+		CreatedAt:          docToTime(quoteBson["CreatedAt"]),
+		ExpiresAt:          docToTime(quoteBson["ExpiresAt"]),
+		PaymentId:          docToString(quoteBson["PaymentId"]),
+	}
+}
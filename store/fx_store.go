@@ -0,0 +1,145 @@
+package store
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/brunovale91/payment-api/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type FxStore interface {
+
+	// Save exchange rates
+	CreateRates([]*types.FxRate) ([]*types.FxRate, error)
+
+	// Get the rate of a currency pair in effect at the given time, the latest
+	// uploaded when several took effect together, nil if there is none
+	GetRate(string, string, time.Time) (*types.FxRate, error)
+
+	// Get rates, of one base and quote currency when not empty, latest
+	// effective first
+	GetRates(string, string) ([]*types.FxRate, error)
+
+	// Save quote
+	CreateQuote(*types.FxQuote) (*types.FxQuote, error)
+
+	// Get quote
+	GetQuote(string) (*types.FxQuote, error)
+}
+
+type FxStoreImpl struct {
+	rates  *mongo.Collection
+	quotes *mongo.Collection
+}
+
+func NewFxStore(config *PaymentStoreConfig) (FxStore, error) {
+	client, err := connect(config)
+	if err != nil {
+		return nil, err
+	}
+	database := client.Database(config.Database)
+	rates := database.Collection(config.FxRateCollection)
+	_, err = rates.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{{Key: "BaseCurrency", Value: 1}, {Key: "QuoteCurrency", Value: 1}, {Key: "EffectiveAt", Value: -1}},
+	})
+	if err != nil {
+		log.Printf("Error creating fx rate index: %s", err.Error())
+		return nil, err
+	}
+	return FxStoreImpl{
+		rates:  rates,
+		quotes: database.Collection(config.FxQuoteCollection),
+	}, nil
+}
+
+func (s FxStoreImpl) CreateRates(rates []*types.FxRate) ([]*types.FxRate, error) {
+	if len(rates) == 0 {
+		return rates, nil
+	}
+	docs := make([]interface{}, 0, len(rates))
+	for _, rate := range rates {
+		docs = append(docs, rateToDoc(rate))
+	}
+	_, err := s.rates.InsertMany(context.Background(), docs)
+	if err != nil {
+		log.Printf("Error creating fx rates: %s", err.Error())
+		return nil, err
+	}
+	return rates, nil
+}
+
+func (s FxStoreImpl) GetRate(base string, quote string, at time.Time) (*types.FxRate, error) {
+	filter := bson.M{
+		"BaseCurrency":  base,
+		"QuoteCurrency": quote,
+		"EffectiveAt":   bson.M{"$lte": at},
+	}
+	opts := options.FindOne().SetSort(bson.D{{Key: "EffectiveAt", Value: -1}, {Key: "CreatedAt", Value: -1}})
+	elem := &bson.D{}
+	err := s.rates.FindOne(context.Background(), filter, opts).Decode(elem)
+	if err != nil {
+		if isNoDocuments(err.Error()) {
+			return nil, nil
+		}
+		log.Printf("Error fetching fx rate %s/%s: %s", base, quote, err.Error())
+		return nil, err
+	}
+	return docToRate(*elem), nil
+}
+
+func (s FxStoreImpl) GetRates(base string, quote string) ([]*types.FxRate, error) {
+	filter := bson.M{}
+	if base != "" {
+		filter["BaseCurrency"] = base
+	}
+	if quote != "" {
+		filter["QuoteCurrency"] = quote
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "EffectiveAt", Value: -1}, {Key: "CreatedAt", Value: -1}})
+	cursor, err := s.rates.Find(context.Background(), filter, opts)
+	if err != nil {
+		log.Printf("Error fetching fx rates: %s", err)
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	rates := make([]*types.FxRate, 0)
+	for cursor.Next(context.Background()) {
+		elem := &bson.D{}
+		if err := cursor.Decode(elem); err != nil {
+			log.Printf("Error parsing fx rate: %s", err)
+			return nil, err
+		}
+		rates = append(rates, docToRate(*elem))
+	}
+	if err := cursor.Err(); err != nil {
+		log.Printf("Error fetching fx rates: %s", err)
+		return nil, err
+	}
+	return rates, nil
+}
+
+func (s FxStoreImpl) CreateQuote(quote *types.FxQuote) (*types.FxQuote, error) {
+	_, err := s.quotes.InsertOne(context.Background(), quoteToDoc(quote))
+	if err != nil {
+		log.Printf("Error creating fx quote with id %s: %s", quote.Id, err.Error())
+		return nil, err
+	}
+	return quote, nil
+}
+
+func (s FxStoreImpl) GetQuote(id string) (*types.FxQuote, error) {
+	elem := &bson.D{}
+	err := s.quotes.FindOne(context.Background(), bson.M{"_id": id}).Decode(elem)
+	if err != nil {
+		if isNoDocuments(err.Error()) {
+			return nil, nil
+		}
+		log.Printf("Error fetching fx quote with id %s: %s", id, err.Error())
+		return nil, err
+	}
+	return docToQuote(*elem), nil
+}
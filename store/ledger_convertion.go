@@ -0,0 +1,55 @@
+package store
+
+import (
+	"math"
+
+	"github.com/brunovale91/payment-api/types"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Named apart from the camt.053 statement entry conversion
+func entryToLedgerDoc(entry *types.LedgerEntry) bson.M {
+	return bson.M{
+		"_id":           entry.Id,
+		"TransactionId": entry.TransactionId,
+		"PaymentId":     entry.PaymentId,
+		"EventType":     entry.EventType,
+		"AccountId":     entry.AccountId,
+		"Currency":      entry.Currency,
+		"Direction":     entry.Direction,
+		"Amount":        entry.Amount,
+		"CreatedAt":     entry.CreatedAt,
+	}
+}
+
+func docToLedgerEntry(entry bson.D) *types.LedgerEntry {
+	entryBson := entry.Map()
+	return &types.LedgerEntry{
+		Id:            entryBson["_id"].(string),
+		TransactionId: docToString(entryBson["TransactionId"]),
+		PaymentId:     docToString(entryBson["PaymentId"]),
+		EventType:     docToString(entryBson["EventType"]),
+		AccountId:     docToString(entryBson["AccountId"]),
+		Currency:      docToString(entryBson["Currency"]),
+		Direction:     docToString(entryBson["Direction"]),
+		Amount:        docToFloat(entryBson["Amount"]),
+		CreatedAt:     docToTime(entryBson["CreatedAt"]),
+	}
+}
+
+// Amounts are summed as floats, so they are rounded to cents
+func docToCurrencyBalance(balance bson.D) *types.CurrencyBalance {
+	balanceBson := balance.Map()
+	debits := roundCents(docToFloat(balanceBson["Debits"]))
+	credits := roundCents(docToFloat(balanceBson["Credits"]))
+	return &types.CurrencyBalance{
+		Currency: docToString(balanceBson["_id"]),
+		Debits:   debits,
+		Credits:  credits,
+		Balance:  roundCents(credits - debits),
+	}
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,173 @@
+package store
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/brunovale91/payment-api/ledger"
+	"github.com/brunovale91/payment-api/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type LedgerStore interface {
+
+	// Get entries of an account, in one currency when not empty, latest
+	// first
+	GetEntries(string, string) ([]*types.LedgerEntry, error)
+
+	// Get debits and credits of an account per currency
+	GetBalance(string) (*types.AccountBalance, error)
+
+	// Get the sum of all entries per currency, credits positive
+	GetTotals() (map[string]float64, error)
+}
+
+type LedgerStoreImpl struct {
+	collection *mongo.Collection
+}
+
+func NewLedgerStore(config *PaymentStoreConfig) (LedgerStore, error) {
+	client, err := connect(config)
+	if err != nil {
+		return nil, err
+	}
+	collection := client.Database(config.Database).Collection(config.LedgerCollection)
+	for _, keys := range []bson.D{
+		{{Key: "AccountId", Value: 1}, {Key: "CreatedAt", Value: -1}},
+		{{Key: "PaymentId", Value: 1}},
+	} {
+		if _, err := collection.Indexes().CreateOne(context.Background(), mongo.IndexModel{Keys: keys}); err != nil {
+			log.Printf("Error creating ledger index: %s", err.Error())
+			return nil, err
+		}
+	}
+	return LedgerStoreImpl{
+		collection: collection,
+	}, nil
+}
+
+func (s LedgerStoreImpl) GetEntries(accountId string, currency string) ([]*types.LedgerEntry, error) {
+	filter := bson.M{"AccountId": accountId}
+	if currency != "" {
+		filter["Currency"] = currency
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "CreatedAt", Value: -1}})
+	entries, err := findEntries(context.Background(), s.collection, filter, opts)
+	if err != nil {
+		log.Printf("Error fetching ledger entries of account %s: %s", accountId, err.Error())
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (s LedgerStoreImpl) GetBalance(accountId string) (*types.AccountBalance, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"AccountId": accountId}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":     "$Currency",
+			"Debits":  bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$Direction", types.Debit}}, "$Amount", 0}}},
+			"Credits": bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$Direction", types.Credit}}, "$Amount", 0}}},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	}
+	cursor, err := s.collection.Aggregate(context.Background(), pipeline)
+	if err != nil {
+		log.Printf("Error fetching balance of account %s: %s", accountId, err.Error())
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	balance := &types.AccountBalance{
+		AccountId: accountId,
+		Balances:  make([]*types.CurrencyBalance, 0),
+	}
+	for cursor.Next(context.Background()) {
+		elem := &bson.D{}
+		if err := cursor.Decode(elem); err != nil {
+			log.Printf("Error parsing balance of account %s: %s", accountId, err.Error())
+			return nil, err
+		}
+		balance.Balances = append(balance.Balances, docToCurrencyBalance(*elem))
+	}
+	if err := cursor.Err(); err != nil {
+		log.Printf("Error fetching balance of account %s: %s", accountId, err.Error())
+		return nil, err
+	}
+	return balance, nil
+}
+
+func (s LedgerStoreImpl) GetTotals() (map[string]float64, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id": "$Currency",
+			"Total": bson.M{"$sum": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{"$Direction", types.Debit}},
+				bson.M{"$multiply": bson.A{"$Amount", -1}},
+				"$Amount",
+			}}},
+		}}},
+	}
+	cursor, err := s.collection.Aggregate(context.Background(), pipeline)
+	if err != nil {
+		log.Printf("Error fetching ledger totals: %s", err.Error())
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	totals := make(map[string]float64)
+	for cursor.Next(context.Background()) {
+		elem := &bson.D{}
+		if err := cursor.Decode(elem); err != nil {
+			log.Printf("Error parsing ledger totals: %s", err.Error())
+			return nil, err
+		}
+		totalBson := elem.Map()
+		totals[docToString(totalBson["_id"])] = docToFloat(totalBson["Total"])
+	}
+	if err := cursor.Err(); err != nil {
+		log.Printf("Error fetching ledger totals: %s", err.Error())
+		return nil, err
+	}
+	return totals, nil
+}
+
+// Post the entries bringing the ledger of a payment to its new state, a
+// deleted payment has all its entries reversed
+func (s PaymentStoreImpl) postEntries(ctx mongo.SessionContext, eventType string, payment *types.Payment) error {
+	posted, err := findEntries(ctx, s.ledger, bson.M{"PaymentId": payment.Id}, options.Find())
+	if err != nil {
+		return err
+	}
+	current := payment
+	if eventType == types.PaymentDeleted {
+		current = nil
+	}
+	entries, err := ledger.Post(payment.Id, current, eventType, posted, time.Now().UTC())
+	if err != nil || len(entries) == 0 {
+		return err
+	}
+	docs := make([]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		docs = append(docs, entryToLedgerDoc(entry))
+	}
+	_, err = s.ledger.InsertMany(ctx, docs)
+	return err
+}
+
+func findEntries(ctx context.Context, collection *mongo.Collection, filter bson.M, opts *options.FindOptions) ([]*types.LedgerEntry, error) {
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	entries := make([]*types.LedgerEntry, 0)
+	for cursor.Next(ctx) {
+		elem := &bson.D{}
+		if err := cursor.Decode(elem); err != nil {
+			return nil, err
+		}
+		entries = append(entries, docToLedgerEntry(*elem))
+	}
+	return entries, cursor.Err()
+}
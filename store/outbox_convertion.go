@@ -0,0 +1,54 @@
+package store
+
+import (
+	"time"
+
+	"github.com/brunovale91/payment-api/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func eventToDoc(event *types.PaymentEvent) bson.M {
+	oid, _ := primitive.ObjectIDFromHex(event.Id)
+	return bson.M{
+		"_id":            oid,
+		"Type":           event.Type,
+		"PaymentId":      event.PaymentId,
+		"OrganisationId": event.OrganisationId,
+		"Payment":        paymentToDoc(event.Payment),
+		"CreatedAt":      event.CreatedAt,
+		"Sequence":       event.Sequence,
+		"Published":      false,
+	}
+}
+
+func docToEvent(event bson.D) *types.PaymentEvent {
+	eventBson := event.Map()
+	return &types.PaymentEvent{
+		Id:             eventBson["_id"].(primitive.ObjectID).Hex(),
+		Type:           eventBson["Type"].(string),
+		PaymentId:      eventBson["PaymentId"].(string),
+		OrganisationId: eventBson["OrganisationId"].(string),
+		Payment:        docToPayment(eventBson["Payment"]),
+		CreatedAt:      docToTime(eventBson["CreatedAt"]),
+		Sequence:       docToInt64(eventBson["Sequence"]),
+	}
+}
+
+// Events written before sequences were assigned have none
+func docToInt64(value interface{}) int64 {
+	switch typed := value.(type) {
+	case int64:
+		return typed
+	case int32:
+		return int64(typed)
+	}
+	return 0
+}
+
+func docToTime(value interface{}) time.Time {
+	if dateTime, ok := value.(primitive.DateTime); ok {
+		return time.Unix(0, int64(dateTime)*int64(time.Millisecond)).UTC()
+	}
+	return time.Time{}
+}
@@ -0,0 +1,145 @@
+package store
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/brunovale91/payment-api/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Events are only read back after the write transaction that would precede
+// them commits by at least this long, as that is the longest a transaction
+// can stay open. Event ids are made before the write, so an event can commit
+// after events with greater ids.
+const eventHoldBack = time.Minute
+
+type OutboxStore interface {
+
+	// Get up to limit unpublished events, in sequence order within each
+	// organisation
+	GetPendingEvents(int64) ([]*types.PaymentEvent, error)
+
+	// Mark event as published so it is not relayed again
+	MarkEventPublished(string) error
+
+	// Get up to limit events of an organisation with a sequence greater than
+	// the given one, in sequence order
+	GetEventsAfter(string, int64, int64) ([]*types.PaymentEvent, error)
+
+	// Get up to limit events of all organisations written after the event
+	// with the given id and at least eventHoldBack ago, in id order
+	GetAllEventsAfter(string, int64) ([]*types.PaymentEvent, error)
+
+	// Get the sequence of the last event of an organisation, 0 when it has
+	// none
+	GetLastSequence(string) (int64, error)
+}
+
+type OutboxStoreImpl struct {
+	collection *mongo.Collection
+}
+
+func NewOutboxStore(config *PaymentStoreConfig) (OutboxStore, error) {
+	client, err := connect(config)
+	if err != nil {
+		return nil, err
+	}
+	collection := client.Database(config.Database).Collection(config.OutboxCollection)
+	_, err = collection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{{
+		Keys: bson.D{{Key: "Published", Value: 1}, {Key: "OrganisationId", Value: 1}, {Key: "Sequence", Value: 1}},
+	}, {
+		Keys: bson.D{{Key: "OrganisationId", Value: 1}, {Key: "Sequence", Value: 1}},
+	}})
+	if err != nil {
+		log.Printf("Error creating outbox index: %s", err.Error())
+		return nil, err
+	}
+	return OutboxStoreImpl{
+		collection: collection,
+	}, nil
+}
+
+func (s OutboxStoreImpl) GetPendingEvents(limit int64) ([]*types.PaymentEvent, error) {
+	sort := bson.D{{Key: "OrganisationId", Value: 1}, {Key: "Sequence", Value: 1}}
+	return s.findEvents(bson.M{"Published": false}, sort, limit)
+}
+
+func (s OutboxStoreImpl) MarkEventPublished(id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = s.collection.UpdateOne(context.Background(), bson.M{"_id": oid}, bson.M{
+		"$set": bson.M{
+			"Published":   true,
+			"PublishedAt": time.Now().UTC(),
+		},
+	})
+	if err != nil {
+		log.Printf("Error marking event %s as published: %s", id, err.Error())
+		return err
+	}
+	return nil
+}
+
+func (s OutboxStoreImpl) GetEventsAfter(organisationId string, sequence int64, limit int64) ([]*types.PaymentEvent, error) {
+	filter := bson.M{"OrganisationId": organisationId, "Sequence": bson.M{"$gt": sequence}}
+	return s.findEvents(filter, bson.D{{Key: "Sequence", Value: 1}}, limit)
+}
+
+func (s OutboxStoreImpl) GetAllEventsAfter(id string, limit int64) ([]*types.PaymentEvent, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	filter := bson.M{"_id": bson.M{"$gt": oid, "$lt": objectIdAt(time.Now().Add(-eventHoldBack))}}
+	return s.findEvents(filter, bson.D{{Key: "_id", Value: 1}}, limit)
+}
+
+func (s OutboxStoreImpl) GetLastSequence(organisationId string) (int64, error) {
+	events, err := s.findEvents(bson.M{"OrganisationId": organisationId}, bson.D{{Key: "Sequence", Value: -1}}, 1)
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
+	return events[0].Sequence, nil
+}
+
+func (s OutboxStoreImpl) findEvents(filter bson.M, sort bson.D, limit int64) ([]*types.PaymentEvent, error) {
+	opts := options.Find().SetSort(sort).SetLimit(limit)
+	cursor, err := s.collection.Find(context.Background(), filter, opts)
+	if err != nil {
+		log.Printf("Error fetching events: %s", err)
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	events := make([]*types.PaymentEvent, 0)
+	for cursor.Next(context.Background()) {
+		elem := &bson.D{}
+		if err := cursor.Decode(elem); err != nil {
+			log.Printf("Error parsing event: %s", err)
+			return nil, err
+		}
+		events = append(events, docToEvent(*elem))
+	}
+	if err := cursor.Err(); err != nil {
+		log.Printf("Error fetching events: %s", err)
+		return nil, err
+	}
+	return events, nil
+}
+
+func newEvent(eventType string, payment *types.Payment) *types.PaymentEvent {
+	return &types.PaymentEvent{
+		Id:             primitive.NewObjectID().Hex(),
+		Type:           eventType,
+		PaymentId:      payment.Id,
+		OrganisationId: payment.OrganisationId,
+		Payment:        payment,
+		CreatedAt:      time.Now().UTC(),
+	}
+}
@@ -1,8 +1,11 @@
 package store
 
 import (
+	"time"
+
 	"github.com/brunovale91/payment-api/types"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func docToPayment(payment interface{}) *types.Payment {
@@ -14,6 +17,21 @@ func docToPayment(payment interface{}) *types.Payment {
 			OrganisationId: paymentBson["OrganisationId"].(string),
 			Type:           paymentBson["Type"].(string),
 			Attributes:     docToAttributes(paymentBson["Attributes"]),
+			CreatedAt:      docToTime(paymentBson["CreatedAt"]),
+			Status:         docToString(paymentBson["Status"]),
+			ExecutionDate:  docToTimePtr(paymentBson["ExecutionDate"]),
+			CreatedBy:      docToString(paymentBson["CreatedBy"]),
+			UpdatedBy:      docToString(paymentBson["UpdatedBy"]),
+			Approvals:      docToApprovals(paymentBson["Approvals"]),
+			Reconciliation: docToReconciliation(paymentBson["Reconciliation"]),
+
+			PossibleDuplicate: docToBool(paymentBson["PossibleDuplicate"]),
+			StandingOrderId:   docToString(paymentBson["StandingOrderId"]),
+			RelatedPaymentId:  docToString(paymentBson["RelatedPaymentId"]),
+			ReturnReason:      docToString(paymentBson["ReturnReason"]),
+			Refunds:           docToRefunds(paymentBson["Refunds"]),
+			Cancellation:      docToCancellation(paymentBson["Cancellation"]),
+			Fx:                docToAppliedRate(paymentBson["Fx"]),
 		}
 	}
 	return nil
@@ -24,9 +42,27 @@ func docToAttributes(attributes interface{}) *types.PaymentAttributes {
 		attBson := attributes.(bson.D).Map()
 		return &types.PaymentAttributes{
 			Amount:            attBson["Amount"].(float64),
+			Currency:          docToString(attBson["Currency"]),
 			EndToEndReference: attBson["EndToEndReference"].(string),
 			BeneficiaryParty:  docToParty(attBson["BeneficiaryParty"]),
 			DebtorParty:       docToParty(attBson["DebtorParty"]),
+			PaymentScheme:     docToString(attBson["PaymentScheme"]),
+			SchemePaymentType: docToString(attBson["SchemePaymentType"]),
+			ProcessingDate:    docToString(attBson["ProcessingDate"]),
+			PaymentPurpose:    docToString(attBson["PaymentPurpose"]),
+			Reference:         docToString(attBson["Reference"]),
+			NumericReference:  docToString(attBson["NumericReference"]),
+
+			DebtorAccountId:      docToString(attBson["DebtorAccountId"]),
+			BeneficiaryAccountId: docToString(attBson["BeneficiaryAccountId"]),
+			BeneficiaryId:        docToString(attBson["BeneficiaryId"]),
+
+			InstructedAmount:   docToFloat(attBson["InstructedAmount"]),
+			InstructedCurrency: docToString(attBson["InstructedCurrency"]),
+			SettlementAmount:   docToFloat(attBson["SettlementAmount"]),
+			SettlementCurrency: docToString(attBson["SettlementCurrency"]),
+			QuoteId:            docToString(attBson["QuoteId"]),
+			ChargesInformation: docToCharges(attBson["ChargesInformation"]),
 		}
 	}
 	return nil
@@ -36,9 +72,16 @@ func docToParty(party interface{}) *types.PaymentParty {
 	if party != nil {
 		partyBson := party.(bson.D).Map()
 		return &types.PaymentParty{
-			BankId:     partyBson["BankId"].(string),
-			BankIdCode: partyBson["BankIdCode"].(string),
-			Name:       partyBson["Name"].(string),
+			BankId:            partyBson["BankId"].(string),
+			BankIdCode:        partyBson["BankIdCode"].(string),
+			Name:              partyBson["Name"].(string),
+			AccountNumber:     docToString(partyBson["AccountNumber"]),
+			AccountNumberCode: docToString(partyBson["AccountNumberCode"]),
+			AccountName:       docToString(partyBson["AccountName"]),
+			AccountType:       docToIntPtr(partyBson["AccountType"]),
+			Address:           docToStrings(partyBson["Address"]),
+			Country:           docToString(partyBson["Country"]),
+			BankAddress:       docToStrings(partyBson["BankAddress"]),
 		}
 	}
 	return nil
@@ -53,6 +96,166 @@ func paymentToDoc(payment *types.Payment) bson.M {
 			"Type":           payment.Type,
 			"Version":        payment.Version,
 			"Attributes":     attributesToDoc(payment.Attributes),
+			"CreatedAt":      payment.CreatedAt,
+			"Status":         payment.Status,
+			"ExecutionDate":  payment.ExecutionDate,
+			"CreatedBy":      payment.CreatedBy,
+			"UpdatedBy":      payment.UpdatedBy,
+			"Approvals":      approvalsToDoc(payment.Approvals),
+			"Reconciliation": reconciliationToDoc(payment.Reconciliation),
+
+			"PossibleDuplicate": payment.PossibleDuplicate,
+			"StandingOrderId":   payment.StandingOrderId,
+			"RelatedPaymentId":  payment.RelatedPaymentId,
+			"ReturnReason":      payment.ReturnReason,
+			"Refunds":           refundsToDoc(payment.Refunds),
+			"Cancellation":      cancellationToDoc(payment.Cancellation),
+			"Fx":                appliedRateToDoc(payment.Fx),
+		}
+	}
+	return nil
+}
+
+func docToApprovals(value interface{}) []*types.PaymentApproval {
+	array := docToArray(value)
+	if len(array) == 0 {
+		return nil
+	}
+	approvals := make([]*types.PaymentApproval, 0, len(array))
+	for _, item := range array {
+		approvalBson := item.(bson.D).Map()
+		approvals = append(approvals, &types.PaymentApproval{
+			ApprovedBy: docToString(approvalBson["ApprovedBy"]),
+			ApprovedAt: docToTime(approvalBson["ApprovedAt"]),
+		})
+	}
+	return approvals
+}
+
+func approvalsToDoc(approvals []*types.PaymentApproval) bson.A {
+	array := bson.A{}
+	for _, approval := range approvals {
+		array = append(array, bson.M{
+			"ApprovedBy": approval.ApprovedBy,
+			"ApprovedAt": approval.ApprovedAt,
+		})
+	}
+	return array
+}
+
+func docToRefunds(value interface{}) []*types.PaymentRefund {
+	array := docToArray(value)
+	if len(array) == 0 {
+		return nil
+	}
+	refunds := make([]*types.PaymentRefund, 0, len(array))
+	for _, item := range array {
+		refundBson := item.(bson.D).Map()
+		refunds = append(refunds, &types.PaymentRefund{
+			PaymentId:    docToString(refundBson["PaymentId"]),
+			Amount:       docToFloat(refundBson["Amount"]),
+			ReturnReason: docToString(refundBson["ReturnReason"]),
+			CreatedAt:    docToTime(refundBson["CreatedAt"]),
+		})
+	}
+	return refunds
+}
+
+func refundsToDoc(refunds []*types.PaymentRefund) bson.A {
+	array := bson.A{}
+	for _, refund := range refunds {
+		array = append(array, bson.M{
+			"PaymentId":    refund.PaymentId,
+			"Amount":       refund.Amount,
+			"ReturnReason": refund.ReturnReason,
+			"CreatedAt":    refund.CreatedAt,
+		})
+	}
+	return array
+}
+
+func docToCancellation(cancellation interface{}) *types.Cancellation {
+	if cancellation != nil {
+		cancelBson := cancellation.(bson.D).Map()
+		return &types.Cancellation{
+			ReasonCode:  docToString(cancelBson["ReasonCode"]),
+			CancelledBy: docToString(cancelBson["CancelledBy"]),
+			CancelledAt: docToTime(cancelBson["CancelledAt"]),
+		}
+	}
+	return nil
+}
+
+func cancellationToDoc(cancellation *types.Cancellation) bson.M {
+	if cancellation != nil {
+		return bson.M{
+			"ReasonCode":  cancellation.ReasonCode,
+			"CancelledBy": cancellation.CancelledBy,
+			"CancelledAt": cancellation.CancelledAt,
+		}
+	}
+	return nil
+}
+
+func docToAppliedRate(rate interface{}) *types.AppliedRate {
+	if rate != nil {
+		rateBson := rate.(bson.D).Map()
+		return &types.AppliedRate{
+			RateId:    docToString(rateBson["RateId"]),
+			Rate:      docToFloat(rateBson["Rate"]),
+			QuoteId:   docToString(rateBson["QuoteId"]),
+			AppliedAt: docToTime(rateBson["AppliedAt"]),
+		}
+	}
+	return nil
+}
+
+func appliedRateToDoc(rate *types.AppliedRate) bson.M {
+	if rate != nil {
+		return bson.M{
+			"RateId":    rate.RateId,
+			"Rate":      rate.Rate,
+			"QuoteId":   rate.QuoteId,
+			"AppliedAt": rate.AppliedAt,
+		}
+	}
+	return nil
+}
+
+func docToCharges(charges interface{}) *types.ChargesInformation {
+	if charges != nil {
+		chargesBson := charges.(bson.D).Map()
+		result := &types.ChargesInformation{
+			BearerCode:              docToString(chargesBson["BearerCode"]),
+			ReceiverChargesAmount:   docToFloat(chargesBson["ReceiverChargesAmount"]),
+			ReceiverChargesCurrency: docToString(chargesBson["ReceiverChargesCurrency"]),
+		}
+		for _, item := range docToArray(chargesBson["SenderCharges"]) {
+			chargeBson := item.(bson.D).Map()
+			result.SenderCharges = append(result.SenderCharges, &types.Charge{
+				Amount:   docToFloat(chargeBson["Amount"]),
+				Currency: docToString(chargeBson["Currency"]),
+			})
+		}
+		return result
+	}
+	return nil
+}
+
+func chargesToDoc(charges *types.ChargesInformation) bson.M {
+	if charges != nil {
+		senderCharges := bson.A{}
+		for _, charge := range charges.SenderCharges {
+			senderCharges = append(senderCharges, bson.M{
+				"Amount":   charge.Amount,
+				"Currency": charge.Currency,
+			})
+		}
+		return bson.M{
+			"BearerCode":              charges.BearerCode,
+			"SenderCharges":           senderCharges,
+			"ReceiverChargesAmount":   charges.ReceiverChargesAmount,
+			"ReceiverChargesCurrency": charges.ReceiverChargesCurrency,
 		}
 	}
 	return nil
@@ -62,9 +265,27 @@ func attributesToDoc(attributes *types.PaymentAttributes) bson.M {
 	if attributes != nil {
 		return bson.M{
 			"Amount":            attributes.Amount,
+			"Currency":          attributes.Currency,
 			"BeneficiaryParty":  partyToDoc(attributes.BeneficiaryParty),
 			"DebtorParty":       partyToDoc(attributes.DebtorParty),
 			"EndToEndReference": attributes.EndToEndReference,
+			"PaymentScheme":     attributes.PaymentScheme,
+			"SchemePaymentType": attributes.SchemePaymentType,
+			"ProcessingDate":    attributes.ProcessingDate,
+			"PaymentPurpose":    attributes.PaymentPurpose,
+			"Reference":         attributes.Reference,
+			"NumericReference":  attributes.NumericReference,
+
+			"DebtorAccountId":      attributes.DebtorAccountId,
+			"BeneficiaryAccountId": attributes.BeneficiaryAccountId,
+			"BeneficiaryId":        attributes.BeneficiaryId,
+
+			"InstructedAmount":   attributes.InstructedAmount,
+			"InstructedCurrency": attributes.InstructedCurrency,
+			"SettlementAmount":   attributes.SettlementAmount,
+			"SettlementCurrency": attributes.SettlementCurrency,
+			"QuoteId":            attributes.QuoteId,
+			"ChargesInformation": chargesToDoc(attributes.ChargesInformation),
 		}
 	}
 	return nil
@@ -73,10 +294,183 @@ func attributesToDoc(attributes *types.PaymentAttributes) bson.M {
 func partyToDoc(party *types.PaymentParty) bson.M {
 	if party != nil {
 		return bson.M{
-			"BankId":     party.BankId,
-			"BankIdCode": party.BankIdCode,
-			"Name":       party.Name,
+			"BankId":            party.BankId,
+			"BankIdCode":        party.BankIdCode,
+			"Name":              party.Name,
+			"AccountNumber":     party.AccountNumber,
+			"AccountNumberCode": party.AccountNumberCode,
+			"AccountName":       party.AccountName,
+			"AccountType":       party.AccountType,
+			"Address":           party.Address,
+			"Country":           party.Country,
+			"BankAddress":       party.BankAddress,
 		}
 	}
 	return nil
 }
+
+func docToReconciliation(reconciliation interface{}) *types.PaymentReconciliation {
+	if reconciliation != nil {
+		recBson := reconciliation.(bson.D).Map()
+		return &types.PaymentReconciliation{
+			StatementReference: recBson["StatementReference"].(string),
+			EntryReference:     recBson["EntryReference"].(string),
+			ReconciledAt:       docToTime(recBson["ReconciledAt"]),
+		}
+	}
+	return nil
+}
+
+func reconciliationToDoc(reconciliation *types.PaymentReconciliation) bson.M {
+	if reconciliation != nil {
+		return bson.M{
+			"StatementReference": reconciliation.StatementReference,
+			"EntryReference":     reconciliation.EntryReference,
+			"ReconciledAt":       reconciliation.ReconciledAt,
+		}
+	}
+	return nil
+}
+
+func filterToDoc(filter *types.PaymentFilter) bson.M {
+	doc := bson.M{}
+	if filter == nil {
+		return doc
+	}
+	if len(filter.Ids) > 0 {
+		doc["_id"] = bson.M{"$in": filter.Ids}
+	}
+	if filter.OrganisationId != "" {
+		doc["OrganisationId"] = filter.OrganisationId
+	}
+	if filter.EndToEndReference != "" {
+		doc["Attributes.EndToEndReference"] = filter.EndToEndReference
+	}
+	partyFilterToDoc(doc, "Attributes.DebtorParty.", filter.DebtorParty)
+	partyFilterToDoc(doc, "Attributes.BeneficiaryParty.", filter.BeneficiaryParty)
+	return doc
+}
+
+// Payments of the same organisation, amount, currency and accounts created
+// since the given time. The end to end reference is left out, payments of an
+// organisation cannot share it.
+func duplicateFilterToDoc(payment *types.Payment, since time.Time) bson.M {
+	doc := bson.M{
+		"OrganisationId":      payment.OrganisationId,
+		"Attributes.Amount":   payment.Attributes.Amount,
+		"Attributes.Currency": payment.Attributes.Currency,
+		"CreatedAt":           bson.M{"$gte": since},
+	}
+	for prefix, party := range map[string]*types.PaymentParty{
+		"Attributes.DebtorParty.":      payment.Attributes.DebtorParty,
+		"Attributes.BeneficiaryParty.": payment.Attributes.BeneficiaryParty,
+	} {
+		if party != nil {
+			doc[prefix+"BankId"] = party.BankId
+			doc[prefix+"AccountNumber"] = party.AccountNumber
+		}
+	}
+	return doc
+}
+
+func partyFilterToDoc(doc bson.M, prefix string, filter *types.PartyFilter) {
+	if filter == nil {
+		return
+	}
+	fields := map[string]string{
+		"BankId":        filter.BankId,
+		"AccountNumber": filter.AccountNumber,
+		"AccountName":   filter.AccountName,
+		"Country":       filter.Country,
+	}
+	for field, value := range fields {
+		if value != "" {
+			doc[prefix+field] = value
+		}
+	}
+}
+
+// Fields added after payments were first stored are read leniently, older
+// documents do not have them
+
+func docToString(value interface{}) string {
+	if str, ok := value.(string); ok {
+		return str
+	}
+	return ""
+}
+
+func docToBool(value interface{}) bool {
+	if b, ok := value.(bool); ok {
+		return b
+	}
+	return false
+}
+
+func docToFloat(value interface{}) float64 {
+	switch number := value.(type) {
+	case float64:
+		return number
+	case int32:
+		return float64(number)
+	case int64:
+		return float64(number)
+	}
+	return 0
+}
+
+func docToInt(value interface{}) int {
+	switch number := value.(type) {
+	case int32:
+		return int(number)
+	case int64:
+		return int(number)
+	}
+	return 0
+}
+
+func docToIntPtr(value interface{}) *int {
+	switch value.(type) {
+	case int32, int64:
+		number := docToInt(value)
+		return &number
+	}
+	return nil
+}
+
+func docToStrings(value interface{}) []string {
+	array := docToArray(value)
+	if array == nil {
+		return nil
+	}
+	strs := make([]string, 0, len(array))
+	for _, item := range array {
+		strs = append(strs, docToString(item))
+	}
+	return strs
+}
+
+func docToTimePtr(value interface{}) *time.Time {
+	if _, ok := value.(primitive.DateTime); !ok {
+		return nil
+	}
+	dateTime := docToTime(value)
+	return &dateTime
+}
+
+func docToArray(value interface{}) bson.A {
+	if array, ok := value.(bson.A); ok {
+		return array
+	}
+	return nil
+}
+
+func docToDuplicateReference(duplicate bson.D) *types.DuplicateReference {
+	duplicateBson := duplicate.Map()
+	key := duplicateBson["_id"].(bson.D).Map()
+	return &types.DuplicateReference{
+		OrganisationId:    docToString(key["OrganisationId"]),
+		EndToEndReference: docToString(key["EndToEndReference"]),
+		PaymentIds:        docToStrings(duplicateBson["PaymentIds"]),
+	}
+}
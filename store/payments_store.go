@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"time"
 
@@ -12,91 +13,438 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// Error labels of transaction failures that can be retried
+const transientTransactionError = "TransientTransactionError"
+const unknownTransactionCommitResult = "UnknownTransactionCommitResult"
+
+const maxTransactionAttempts = 5
+
 type PaymentStoreConfig struct {
-	URL        string
-	Database   string
-	Collection string
+	URL                      string
+	Database                 string
+	Collection               string
+	OutboxCollection         string
+	ReconciliationCollection string
+	StandingOrderCollection  string
+	FxRateCollection         string
+	FxQuoteCollection        string
+	LedgerCollection         string
+	AccountCollection        string
+	BeneficiaryCollection    string
+	CounterCollection        string
+
+	// Client the stores created with the config share, each store connects
+	// its own when nil
+	Client *mongo.Client
+}
+
+// Payment write conflicts with the end to end reference of another payment
+// of its organisation
+type DuplicateReferenceError struct {
+	ExistingId string
+}
+
+func (e DuplicateReferenceError) Error() string {
+	return fmt.Sprintf("End to end reference is used by payment %s", e.ExistingId)
+}
+
+// Payment write uses a quote that another payment used
+type QuoteUsedError struct {
+	QuoteId string
+}
+
+func (e QuoteUsedError) Error() string {
+	return fmt.Sprintf("Quote %s is used by another payment", e.QuoteId)
+}
+
+// Write of the payment at Index of a batch failed with Err, no payment of the
+// batch was written
+type BatchWriteError struct {
+	Index int
+	Err   error
 }
 
+func (e BatchWriteError) Error() string {
+	return fmt.Sprintf("Payment %d of the batch was not written: %s", e.Index, e.Err.Error())
+}
+
+// Payment or standing order was changed by another request since it was read
+type VersionConflictError struct {
+	Id string
+}
+
+func (e VersionConflictError) Error() string {
+	return fmt.Sprintf("%s was changed by another request", e.Id)
+}
+
+// Payment reads that write checks make. Inside a write transaction they see
+// the payments written before in the same transaction.
+type PaymentReader interface {
+
+	// Get the latest payment created since the given time with the same
+	// organisation, amount and accounts as payment
+	FindDuplicate(*types.Payment, time.Time) (*types.Payment, error)
+
+	// Get total amount per currency and count of the payments of an
+	// organisation created since the given time, leaving out cancelled
+	// payments and the payment with the given id
+	SumPayments(string, time.Time, string) (map[string]float64, int, error)
+}
+
+// Check of a payment run in its write transaction before it is written, an
+// error aborts the transaction. Checked writes of an organisation are
+// serialised, so a check sees the payments of every write committed before.
+type WriteCheck func(*types.Payment, PaymentReader) error
+
 type PaymentStore interface {
+	PaymentReader
+
+	// Create payments in one transaction, running the check before writing
+	// each, and return them. Payments converted at a quote use it up. Nothing
+	// is created if a check or write fails, the BatchWriteError returned
+	// wraps the check error, a QuoteUsedError if another payment used the
+	// quote or a DuplicateReferenceError if an organisation used an end to
+	// end reference.
+	CreatePayments([]*types.Payment, WriteCheck) ([]*types.Payment, error)
 
-	// Create payment in data store and return the created payment
-	CreatePayment(*types.Payment) (*types.Payment, error)
+	// Update payment attributes and workflow state in data store, recording
+	// an event of the given type, and return the updated payment. The check,
+	// if any, runs before the write and its error is returned as is. Fails
+	// with a VersionConflictError if the payment version changed since it
+	// was read and with a QuoteUsedError or DuplicateReferenceError as
+	// CreatePayments.
+	UpdatePayment(*types.Payment, string, WriteCheck) (*types.Payment, error)
 
-	// Update payment attributes in data store and return the update payment
-	UpdatePayment(string, *types.PaymentAttributes) (*types.Payment, error)
+	// Create a refund, running the check on it first, and record it on the
+	// refunded payment, returning the refund. Fails as UpdatePayment if the
+	// refunded payment changed since it was read or the check fails, or with
+	// a DuplicateReferenceError as CreatePayments.
+	CreateRefund(*types.Payment, *types.Payment, WriteCheck) (*types.Payment, error)
 
-	// Delete payment in data store
-	DeletePayment(string) (bool, error)
+	// Delete payment in data store at the version it was read, failing with
+	// a VersionConflictError if it changed since
+	DeletePayment(*types.Payment) (bool, error)
 
 	// Get payment from data store
 	GetPayment(string) (*types.Payment, error)
 
-	// Get slice of payments from data store
-	GetPayments() ([]*types.Payment, error)
+	// Get slice of payments matching filter from data store
+	GetPayments(*types.PaymentFilter) ([]*types.Payment, error)
+
+	// Mark the matched payments of a reconciliation report as reconciled
+	// against their statement entries and save the report, all in one
+	// transaction. Fails with a VersionConflictError if a matched payment was
+	// reconciled by another request or is no longer settled.
+	ReconcilePayments(*types.ReconciliationReport) (*types.ReconciliationReport, error)
+
+	// Lease the scheduled payment due at the given time with the earliest
+	// execution date to an owner until the lease expiry, skipping payments
+	// leased to others. The lease bumps the payment version, so updates based
+	// on an earlier read fail. Returns nil when no payment is due.
+	LeaseDuePayment(time.Time, string, time.Time) (*types.Payment, error)
+
+	// Get the end to end references shared by payments of an organisation,
+	// which keep the reference index from being created
+	GetDuplicateReferences() ([]*types.DuplicateReference, error)
 }
 
+// Every payment write also inserts a row in the outbox collection and posts
+// its ledger entries inside the same transaction, so an event is recorded and
+// balances move if and only if the write commits.
 type PaymentStoreImpl struct {
-	client     *mongo.Client
-	collection *mongo.Collection
+	client          *mongo.Client
+	collection      *mongo.Collection
+	outbox          *mongo.Collection
+	ledger          *mongo.Collection
+	reconciliations *mongo.Collection
+	counters        *mongo.Collection
+	quotes          *mongo.Collection
 }
 
 func NewPaymentStore(config *PaymentStoreConfig) (PaymentStore, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(config.URL))
+	client, err := connect(config)
+	if err != nil {
+		return nil, err
+	}
+	database := client.Database(config.Database)
+	for _, name := range []string{config.Collection, config.OutboxCollection, config.LedgerCollection,
+		config.ReconciliationCollection, config.CounterCollection, config.FxQuoteCollection} {
+		if err := ensureCollection(database, name); err != nil {
+			return nil, err
+		}
+	}
+	// End to end references are unique per organisation. Payments written
+	// before that may share them, the store starts without the index until
+	// they are resolved.
+	collection := database.Collection(config.Collection)
+	_, err = collection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "OrganisationId", Value: 1},
+			{Key: "Attributes.EndToEndReference", Value: 1},
+		},
+		Options: options.Index().SetUnique(true),
+	})
+	if isDuplicateKey(err) {
+		log.Printf("End to end references are not unique, list the payments sharing them with the "+
+			"find-duplicate-references command and change or delete them: %s", err.Error())
+	} else if err != nil {
+		log.Printf("Error creating end to end reference index: %s", err.Error())
+		return nil, err
+	}
+	_, err = collection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "OrganisationId", Value: 1},
+			{Key: "Attributes.Amount", Value: 1},
+			{Key: "Attributes.Currency", Value: 1},
+			{Key: "CreatedAt", Value: -1},
+		},
+	})
+	if err != nil {
+		log.Printf("Error creating duplicate payment index: %s", err.Error())
+		return nil, err
+	}
+	_, err = collection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{{Key: "Status", Value: 1}, {Key: "ExecutionDate", Value: 1}},
+	})
 	if err != nil {
+		log.Printf("Error creating execution date index: %s", err.Error())
 		return nil, err
 	}
-	collection := client.Database(config.Database).Collection(config.Collection)
 	return PaymentStoreImpl{
-		client:     client,
-		collection: collection,
+		client:          client,
+		collection:      collection,
+		outbox:          database.Collection(config.OutboxCollection),
+		ledger:          database.Collection(config.LedgerCollection),
+		reconciliations: database.Collection(config.ReconciliationCollection),
+		counters:        database.Collection(config.CounterCollection),
+		quotes:          database.Collection(config.FxQuoteCollection),
 	}, nil
 }
 
-func (s PaymentStoreImpl) CreatePayment(payment *types.Payment) (*types.Payment, error) {
-	_, err := s.collection.InsertOne(context.Background(), paymentToDoc(payment))
+// Connect a client to the database of the config, for its stores to share
+func NewClient(config *PaymentStoreConfig) (*mongo.Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return mongo.Connect(ctx, options.Client().ApplyURI(config.URL))
+}
+
+func connect(config *PaymentStoreConfig) (*mongo.Client, error) {
+	if config.Client != nil {
+		return config.Client, nil
+	}
+	return NewClient(config)
+}
+
+// Collections cannot be created implicitly inside a transaction
+func ensureCollection(database *mongo.Database, name string) error {
+	err := database.RunCommand(context.Background(), bson.D{{Key: "create", Value: name}}).Err()
+	if err != nil && !isNamespaceExists(err) {
+		log.Printf("Error creating collection %s: %s", name, err.Error())
+		return err
+	}
+	return nil
+}
+
+func (s PaymentStoreImpl) CreatePayments(payments []*types.Payment, check WriteCheck) ([]*types.Payment, error) {
+	// Errors keep their labels inside the transaction so transient ones are
+	// retried, the failed payment and check are recorded aside
+	var index int
+	var checkErr error
+	err := s.withTransaction(func(ctx mongo.SessionContext) error {
+		for i, payment := range payments {
+			index = i
+			if err := s.runCheck(ctx, payment, check, &checkErr); err != nil {
+				return err
+			}
+			if err := s.useQuote(ctx, payment, &checkErr); err != nil {
+				return err
+			}
+			if _, err := s.collection.InsertOne(ctx, paymentToDoc(payment)); err != nil {
+				return err
+			}
+			if err := s.recordChange(ctx, types.PaymentCreated, payment); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil && checkErr != nil {
+		return nil, BatchWriteError{Index: index, Err: checkErr}
+	}
+	if isDuplicateKey(err) && payments[index].Attributes != nil {
+		payment := payments[index]
+		return nil, BatchWriteError{Index: index, Err: s.referenceConflict(payment.OrganisationId, payment.Attributes.EndToEndReference)}
+	}
 	if err != nil {
-		log.Printf("Error creating payment with id %s: %s", payment.Id, err.Error())
+		log.Printf("Error creating payment with id %s: %s", payments[index].Id, err.Error())
 		return nil, err
 	}
-	return payment, nil
+	return payments, nil
 }
 
-func (s PaymentStoreImpl) UpdatePayment(id string, attributes *types.PaymentAttributes) (*types.Payment, error) {
+func (s PaymentStoreImpl) UpdatePayment(payment *types.Payment, eventType string, check WriteCheck) (*types.Payment, error) {
 	updateDoc := bson.M{
 		"$inc": bson.M{
 			"Version": 1,
 		},
 		"$set": bson.M{
-			"Attributes": attributesToDoc(attributes),
+			"Attributes":    attributesToDoc(payment.Attributes),
+			"Status":        payment.Status,
+			"ExecutionDate": payment.ExecutionDate,
+			"UpdatedBy":     payment.UpdatedBy,
+			"Approvals":     approvalsToDoc(payment.Approvals),
+			"Cancellation":  cancellationToDoc(payment.Cancellation),
+			"Fx":            appliedRateToDoc(payment.Fx),
 		},
 	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
 
-	elem := &bson.D{}
-	err := s.collection.FindOneAndUpdate(context.Background(), bson.M{"_id": id}, updateDoc).Decode(elem)
+	var updated *types.Payment
+	var checkErr error
+	err := s.withTransaction(func(ctx mongo.SessionContext) error {
+		if err := s.runCheck(ctx, payment, check, &checkErr); err != nil {
+			return err
+		}
+		if err := s.useQuote(ctx, payment, &checkErr); err != nil {
+			return err
+		}
+		elem := &bson.D{}
+		filter := bson.M{"_id": payment.Id, "Version": payment.Version}
+		err := s.collection.FindOneAndUpdate(ctx, filter, updateDoc, opts).Decode(elem)
+		if err != nil {
+			return err
+		}
+		updated = docToPayment(*elem)
+		return s.recordChange(ctx, eventType, updated)
+	})
+	if err != nil && checkErr != nil {
+		return nil, checkErr
+	}
+	if isDuplicateKey(err) && payment.Attributes != nil {
+		return nil, s.referenceConflict(payment.OrganisationId, payment.Attributes.EndToEndReference)
+	}
 	if err != nil {
 		if isNoDocuments(err.Error()) {
-			return nil, nil
+			return s.versionConflict(payment.Id)
+		}
+		log.Printf("Error updating payment with id %s: %s", payment.Id, err.Error())
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (s PaymentStoreImpl) CreateRefund(refund *types.Payment, payment *types.Payment, check WriteCheck) (*types.Payment, error) {
+	updateDoc := bson.M{
+		"$inc": bson.M{
+			"Version": 1,
+		},
+		"$set": bson.M{
+			"Refunds": refundsToDoc(payment.Refunds),
+		},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var checkErr error
+	err := s.withTransaction(func(ctx mongo.SessionContext) error {
+		if err := s.runCheck(ctx, refund, check, &checkErr); err != nil {
+			return err
 		}
-		log.Printf("Error updating payment with id %s: %s", id, err.Error())
+		elem := &bson.D{}
+		filter := bson.M{"_id": payment.Id, "Version": payment.Version}
+		if err := s.collection.FindOneAndUpdate(ctx, filter, updateDoc, opts).Decode(elem); err != nil {
+			return err
+		}
+		if err := s.recordChange(ctx, types.PaymentRefunded, docToPayment(*elem)); err != nil {
+			return err
+		}
+		if _, err := s.collection.InsertOne(ctx, paymentToDoc(refund)); err != nil {
+			return err
+		}
+		return s.recordChange(ctx, types.PaymentCreated, refund)
+	})
+	if err != nil && checkErr != nil {
+		return nil, checkErr
+	}
+	if isDuplicateKey(err) {
+		return nil, s.referenceConflict(refund.OrganisationId, refund.Attributes.EndToEndReference)
+	}
+	if err != nil {
+		if isNoDocuments(err.Error()) {
+			return s.versionConflict(payment.Id)
+		}
+		log.Printf("Error creating refund of payment with id %s: %s", payment.Id, err.Error())
 		return nil, err
 	}
+	return refund, nil
+}
+
+// Run the check of a payment written in a transaction, keeping its error
+// aside in failed. The check is preceded by a write to the counter of the
+// organisation, so concurrent checked writes of the organisation conflict
+// and the one retried sees the payments of the other.
+func (s PaymentStoreImpl) runCheck(ctx mongo.SessionContext, payment *types.Payment, check WriteCheck, failed *error) error {
+	*failed = nil
+	if check == nil {
+		return nil
+	}
+	updateDoc := bson.M{"$inc": bson.M{"Writes": 1}}
+	opts := options.Update().SetUpsert(true)
+	if _, err := s.counters.UpdateOne(ctx, bson.M{"_id": payment.OrganisationId}, updateDoc, opts); err != nil {
+		return err
+	}
+	*failed = check(payment, transactionReader{store: s, ctx: ctx})
+	return *failed
+}
+
+// Bind the quote a payment was converted at to the payment, unless another
+// payment used it, keeping a QuoteUsedError aside in failed
+func (s PaymentStoreImpl) useQuote(ctx mongo.SessionContext, payment *types.Payment, failed *error) error {
+	if payment.Fx == nil || payment.Fx.QuoteId == "" {
+		return nil
+	}
+	filter := bson.M{
+		"_id":       payment.Fx.QuoteId,
+		"PaymentId": bson.M{"$in": []interface{}{nil, "", payment.Id}},
+	}
+	result, err := s.quotes.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"PaymentId": payment.Id}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		*failed = QuoteUsedError{QuoteId: payment.Fx.QuoteId}
+		return *failed
+	}
+	return nil
+}
 
-	payment := docToPayment(*elem)
-	payment.Attributes = attributes
-	payment.Version += 1
-	return payment, nil
+// Payment not found at the expected version, either deleted or changed
+func (s PaymentStoreImpl) versionConflict(id string) (*types.Payment, error) {
+	existing, err := s.GetPayment(id)
+	if err != nil || existing == nil {
+		return nil, err
+	}
+	return nil, VersionConflictError{Id: id}
 }
 
-func (s PaymentStoreImpl) DeletePayment(id string) (bool, error) {
-	result, err := s.collection.DeleteOne(context.Background(), bson.M{"_id": id})
+func (s PaymentStoreImpl) DeletePayment(payment *types.Payment) (bool, error) {
+	id := payment.Id
+	err := s.withTransaction(func(ctx mongo.SessionContext) error {
+		elem := &bson.D{}
+		err := s.collection.FindOneAndDelete(ctx, bson.M{"_id": id, "Version": payment.Version}).Decode(elem)
+		if err != nil {
+			return err
+		}
+		return s.recordChange(ctx, types.PaymentDeleted, docToPayment(*elem))
+	})
 	if err != nil {
+		if isNoDocuments(err.Error()) {
+			_, err = s.versionConflict(id)
+			return false, err
+		}
 		log.Printf("Error deleting payment with id %s: %s", id, err.Error())
 		return false, err
 	}
-	return result.DeletedCount == 1, nil
+	return true, nil
 }
 
 func (s PaymentStoreImpl) GetPayment(id string) (*types.Payment, error) {
@@ -112,8 +460,57 @@ func (s PaymentStoreImpl) GetPayment(id string) (*types.Payment, error) {
 	return docToPayment(*elem), nil
 }
 
-func (s PaymentStoreImpl) GetPayments() ([]*types.Payment, error) {
-	cursor, err := s.collection.Find(context.Background(), bson.D{})
+// Payments awaiting approval or execution, or cancelled, have not settled
+// and cannot be reconciled
+var unsettledStatuses = []string{types.PaymentPendingApproval, types.PaymentScheduled, types.PaymentCancelled}
+
+func (s PaymentStoreImpl) ReconcilePayments(report *types.ReconciliationReport) (*types.ReconciliationReport, error) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := s.withTransaction(func(ctx mongo.SessionContext) error {
+		for _, match := range report.Matched {
+			updateDoc := bson.M{
+				"$inc": bson.M{
+					"Version": 1,
+				},
+				"$set": bson.M{
+					"Reconciliation": reconciliationToDoc(&types.PaymentReconciliation{
+						StatementReference: match.Entry.StatementId,
+						EntryReference:     match.Entry.EntryReference,
+						ReconciledAt:       report.CreatedAt,
+					}),
+				},
+			}
+			filter := bson.M{
+				"_id":            match.PaymentId,
+				"Reconciliation": nil,
+				"Status":         bson.M{"$nin": unsettledStatuses},
+			}
+			elem := &bson.D{}
+			if err := s.collection.FindOneAndUpdate(ctx, filter, updateDoc, opts).Decode(elem); err != nil {
+				if isNoDocuments(err.Error()) {
+					return VersionConflictError{Id: match.PaymentId}
+				}
+				return err
+			}
+			if err := s.recordChange(ctx, types.PaymentReconciled, docToPayment(*elem)); err != nil {
+				return err
+			}
+		}
+		_, err := s.reconciliations.InsertOne(ctx, reportToDoc(report))
+		return err
+	})
+	if err != nil {
+		if _, ok := err.(VersionConflictError); ok {
+			return nil, err
+		}
+		log.Printf("Error saving reconciliation report with id %s: %s", report.Id, err.Error())
+		return nil, err
+	}
+	return report, nil
+}
+
+func (s PaymentStoreImpl) GetPayments(filter *types.PaymentFilter) ([]*types.Payment, error) {
+	cursor, err := s.collection.Find(context.Background(), filterToDoc(filter))
 	if err != nil {
 		log.Printf("Error fetching payments: %s", err)
 		return nil, err
@@ -126,6 +523,167 @@ func (s PaymentStoreImpl) GetPayments() ([]*types.Payment, error) {
 	return payments, nil
 }
 
+func (s PaymentStoreImpl) FindDuplicate(payment *types.Payment, since time.Time) (*types.Payment, error) {
+	return s.findDuplicate(context.Background(), payment, since)
+}
+
+func (s PaymentStoreImpl) findDuplicate(ctx context.Context, payment *types.Payment, since time.Time) (*types.Payment, error) {
+	if payment.Attributes == nil {
+		return nil, nil
+	}
+	elem := &bson.D{}
+	opts := options.FindOne().SetSort(bson.D{{Key: "CreatedAt", Value: -1}})
+	err := s.collection.FindOne(ctx, duplicateFilterToDoc(payment, since), opts).Decode(elem)
+	if err != nil {
+		if isNoDocuments(err.Error()) {
+			return nil, nil
+		}
+		log.Printf("Error fetching duplicates of payment with id %s: %s", payment.Id, err.Error())
+		return nil, err
+	}
+	return docToPayment(*elem), nil
+}
+
+func (s PaymentStoreImpl) SumPayments(organisationId string, since time.Time, excludeId string) (map[string]float64, int, error) {
+	return s.sumPayments(context.Background(), organisationId, since, excludeId)
+}
+
+func (s PaymentStoreImpl) sumPayments(ctx context.Context, organisationId string, since time.Time, excludeId string) (map[string]float64, int, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"OrganisationId": organisationId,
+			"CreatedAt":      bson.M{"$gte": since},
+			"_id":            bson.M{"$ne": excludeId},
+			"Status":         bson.M{"$ne": types.PaymentCancelled},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":    "$Attributes.Currency",
+			"Amount": bson.M{"$sum": "$Attributes.Amount"},
+			"Count":  bson.M{"$sum": 1},
+		}}},
+	}
+	cursor, err := s.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		log.Printf("Error summing payments of organisation %s: %s", organisationId, err.Error())
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+	amounts := make(map[string]float64)
+	count := 0
+	for cursor.Next(ctx) {
+		elem := &bson.D{}
+		if err := cursor.Decode(elem); err != nil {
+			log.Printf("Error parsing payment sums: %s", err.Error())
+			return nil, 0, err
+		}
+		sums := elem.Map()
+		amounts[docToString(sums["_id"])] += docToFloat(sums["Amount"])
+		count += docToInt(sums["Count"])
+	}
+	if err := cursor.Err(); err != nil {
+		log.Printf("Error summing payments of organisation %s: %s", organisationId, err.Error())
+		return nil, 0, err
+	}
+	return amounts, count, nil
+}
+
+func (s PaymentStoreImpl) GetDuplicateReferences() ([]*types.DuplicateReference, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{
+				"OrganisationId":    "$OrganisationId",
+				"EndToEndReference": "$Attributes.EndToEndReference",
+			},
+			"PaymentIds": bson.M{"$push": "$_id"},
+			"Count":      bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{"Count": bson.M{"$gt": 1}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id.OrganisationId", Value: 1}, {Key: "_id.EndToEndReference", Value: 1}}}},
+	}
+	cursor, err := s.collection.Aggregate(context.Background(), pipeline)
+	if err != nil {
+		log.Printf("Error fetching duplicate references: %s", err.Error())
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	duplicates := make([]*types.DuplicateReference, 0)
+	for cursor.Next(context.Background()) {
+		elem := &bson.D{}
+		if err := cursor.Decode(elem); err != nil {
+			log.Printf("Error parsing duplicate references: %s", err.Error())
+			return nil, err
+		}
+		duplicates = append(duplicates, docToDuplicateReference(*elem))
+	}
+	if err := cursor.Err(); err != nil {
+		log.Printf("Error fetching duplicate references: %s", err.Error())
+		return nil, err
+	}
+	return duplicates, nil
+}
+
+// Reads made inside a write transaction
+type transactionReader struct {
+	store PaymentStoreImpl
+	ctx   mongo.SessionContext
+}
+
+func (r transactionReader) FindDuplicate(payment *types.Payment, since time.Time) (*types.Payment, error) {
+	return r.store.findDuplicate(r.ctx, payment, since)
+}
+
+func (r transactionReader) SumPayments(organisationId string, since time.Time, excludeId string) (map[string]float64, int, error) {
+	return r.store.sumPayments(r.ctx, organisationId, since, excludeId)
+}
+
+func (s PaymentStoreImpl) LeaseDuePayment(now time.Time, owner string, expiry time.Time) (*types.Payment, error) {
+	filter := bson.M{
+		"Status":        types.PaymentScheduled,
+		"ExecutionDate": bson.M{"$lte": now},
+		"$or": bson.A{
+			bson.M{"LeaseExpiry": bson.M{"$exists": false}},
+			bson.M{"LeaseExpiry": bson.M{"$lte": now}},
+		},
+	}
+	updateDoc := bson.M{
+		"$inc": bson.M{
+			"Version": 1,
+		},
+		"$set": bson.M{
+			"LeaseOwner":  owner,
+			"LeaseExpiry": expiry,
+		},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "ExecutionDate", Value: 1}}).
+		SetReturnDocument(options.After)
+	elem := &bson.D{}
+	err := s.collection.FindOneAndUpdate(context.Background(), filter, updateDoc, opts).Decode(elem)
+	if err != nil {
+		if isNoDocuments(err.Error()) {
+			return nil, nil
+		}
+		log.Printf("Error leasing due payment: %s", err.Error())
+		return nil, err
+	}
+	return docToPayment(*elem), nil
+}
+
+// Error pointing at the payment holding an end to end reference
+func (s PaymentStoreImpl) referenceConflict(organisationId string, reference string) error {
+	payments, err := s.GetPayments(&types.PaymentFilter{
+		OrganisationId:    organisationId,
+		EndToEndReference: reference,
+	})
+	if err != nil {
+		return err
+	}
+	if len(payments) == 0 {
+		return fmt.Errorf("payment with end to end reference %s not found after duplicate key error", reference)
+	}
+	return DuplicateReferenceError{ExistingId: payments[0].Id}
+}
+
 func decodePayments(cursor *mongo.Cursor) ([]*types.Payment, error) {
 	payments := make([]*types.Payment, 0)
 	for cursor.Next(context.Background()) {
@@ -152,6 +710,89 @@ func decodePayment(cursor *mongo.Cursor) (*types.Payment, error) {
 	return docToPayment(*elem), nil
 }
 
+// Run fn in a transaction, aborting it if fn returns an error
+// Transactions failing with a transient error, such as a write conflict with
+// a concurrent transaction, are run again from the start. Commits with an
+// unknown result are retried on their own, committing twice is safe.
+func (s PaymentStoreImpl) withTransaction(fn func(mongo.SessionContext) error) error {
+	return s.client.UseSession(context.Background(), func(ctx mongo.SessionContext) error {
+		for attempt := 1; ; attempt++ {
+			err := runTransaction(ctx, fn)
+			if err == nil || attempt == maxTransactionAttempts || !hasErrorLabel(err, transientTransactionError) {
+				return err
+			}
+			log.Printf("Retrying transaction after transient error: %s", err.Error())
+		}
+	})
+}
+
+func runTransaction(ctx mongo.SessionContext, fn func(mongo.SessionContext) error) error {
+	if err := ctx.StartTransaction(); err != nil {
+		return err
+	}
+	if err := fn(ctx); err != nil {
+		ctx.AbortTransaction(ctx)
+		return err
+	}
+	for attempt := 1; ; attempt++ {
+		err := ctx.CommitTransaction(ctx)
+		if err == nil || attempt == maxTransactionAttempts || !hasErrorLabel(err, unknownTransactionCommitResult) {
+			return err
+		}
+		log.Printf("Retrying transaction commit with unknown result: %s", err.Error())
+	}
+}
+
+// Write the event of a change to the outbox. Its sequence comes from the
+// organisation counter, so concurrent writes of an organisation conflict and
+// sequences follow the order their transactions commit in.
+func (s PaymentStoreImpl) recordChange(ctx mongo.SessionContext, eventType string, payment *types.Payment) error {
+	event := newEvent(eventType, payment)
+	sequence, err := s.nextSequence(ctx, payment.OrganisationId)
+	if err != nil {
+		return err
+	}
+	event.Sequence = sequence
+	if _, err := s.outbox.InsertOne(ctx, eventToDoc(event)); err != nil {
+		return err
+	}
+	return s.postEntries(ctx, eventType, payment)
+}
+
+func (s PaymentStoreImpl) nextSequence(ctx mongo.SessionContext, organisationId string) (int64, error) {
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	elem := &bson.D{}
+	err := s.counters.FindOneAndUpdate(ctx, bson.M{"_id": organisationId}, bson.M{"$inc": bson.M{"Events": int64(1)}}, opts).Decode(elem)
+	if err != nil {
+		return 0, err
+	}
+	return docToInt64(elem.Map()["Events"]), nil
+}
+
+func hasErrorLabel(err error, label string) bool {
+	cmdErr, ok := err.(mongo.CommandError)
+	return ok && cmdErr.HasErrorLabel(label)
+}
+
 func isNoDocuments(message string) bool {
 	return message == "mongo: no documents in result"
 }
+
+func isDuplicateKey(err error) bool {
+	switch typed := err.(type) {
+	case mongo.WriteException:
+		for _, writeErr := range typed.WriteErrors {
+			if writeErr.Code == 11000 {
+				return true
+			}
+		}
+	case mongo.CommandError:
+		return typed.Code == 11000
+	}
+	return false
+}
+
+func isNamespaceExists(err error) bool {
+	cmdErr, ok := err.(mongo.CommandError)
+	return ok && cmdErr.Code == 48
+}
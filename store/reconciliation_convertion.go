@@ -0,0 +1,100 @@
+package store
+
+import (
+	"github.com/brunovale91/payment-api/types"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func reportToDoc(report *types.ReconciliationReport) bson.M {
+	matched := make(bson.A, 0, len(report.Matched))
+	for _, match := range report.Matched {
+		matched = append(matched, bson.M{
+			"Entry":     entryToDoc(match.Entry),
+			"PaymentId": match.PaymentId,
+		})
+	}
+	unmatched := make(bson.A, 0, len(report.Unmatched))
+	for _, entry := range report.Unmatched {
+		unmatched = append(unmatched, entryToDoc(entry))
+	}
+	ambiguous := make(bson.A, 0, len(report.Ambiguous))
+	for _, entry := range report.Ambiguous {
+		ambiguous = append(ambiguous, bson.M{
+			"Entry":      entryToDoc(entry.Entry),
+			"PaymentIds": entry.PaymentIds,
+		})
+	}
+	return bson.M{
+		"_id":            report.Id,
+		"OrganisationId": report.OrganisationId,
+		"StatementId":    report.StatementId,
+		"CreatedAt":      report.CreatedAt,
+		"Matched":        matched,
+		"Unmatched":      unmatched,
+		"Ambiguous":      ambiguous,
+	}
+}
+
+func docToReport(report bson.D) *types.ReconciliationReport {
+	reportBson := report.Map()
+	result := &types.ReconciliationReport{
+		Id:             reportBson["_id"].(string),
+		OrganisationId: docToString(reportBson["OrganisationId"]),
+		StatementId:    reportBson["StatementId"].(string),
+		CreatedAt:      docToTime(reportBson["CreatedAt"]),
+		Matched:        make([]*types.ReconciliationMatch, 0),
+		Unmatched:      make([]*types.StatementEntry, 0),
+		Ambiguous:      make([]*types.AmbiguousEntry, 0),
+	}
+	for _, match := range docToArray(reportBson["Matched"]) {
+		matchBson := match.(bson.D).Map()
+		result.Matched = append(result.Matched, &types.ReconciliationMatch{
+			Entry:     docToEntry(matchBson["Entry"]),
+			PaymentId: matchBson["PaymentId"].(string),
+		})
+	}
+	for _, entry := range docToArray(reportBson["Unmatched"]) {
+		result.Unmatched = append(result.Unmatched, docToEntry(entry))
+	}
+	for _, entry := range docToArray(reportBson["Ambiguous"]) {
+		entryBson := entry.(bson.D).Map()
+		result.Ambiguous = append(result.Ambiguous, &types.AmbiguousEntry{
+			Entry:      docToEntry(entryBson["Entry"]),
+			PaymentIds: docToStrings(entryBson["PaymentIds"]),
+		})
+	}
+	return result
+}
+
+func entryToDoc(entry *types.StatementEntry) bson.M {
+	return bson.M{
+		"StatementId":         entry.StatementId,
+		"Account":             entry.Account,
+		"AccountBankId":       entry.AccountBankId,
+		"EntryReference":      entry.EntryReference,
+		"EndToEndReference":   entry.EndToEndReference,
+		"Amount":              entry.Amount,
+		"Currency":            entry.Currency,
+		"CreditDebit":         entry.CreditDebit,
+		"BookingDate":         entry.BookingDate,
+		"CounterpartyName":    entry.CounterpartyName,
+		"CounterpartyAccount": entry.CounterpartyAccount,
+	}
+}
+
+func docToEntry(entry interface{}) *types.StatementEntry {
+	entryBson := entry.(bson.D).Map()
+	return &types.StatementEntry{
+		StatementId:         entryBson["StatementId"].(string),
+		Account:             docToString(entryBson["Account"]),
+		AccountBankId:       docToString(entryBson["AccountBankId"]),
+		EntryReference:      entryBson["EntryReference"].(string),
+		EndToEndReference:   entryBson["EndToEndReference"].(string),
+		Amount:              entryBson["Amount"].(float64),
+		Currency:            entryBson["Currency"].(string),
+		CreditDebit:         entryBson["CreditDebit"].(string),
+		BookingDate:         entryBson["BookingDate"].(string),
+		CounterpartyName:    entryBson["CounterpartyName"].(string),
+		CounterpartyAccount: entryBson["CounterpartyAccount"].(string),
+	}
+}
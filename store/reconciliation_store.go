@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+	"log"
+
+	"github.com/brunovale91/payment-api/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Reports are saved by PaymentStore.ReconcilePayments together with the
+// payments they reconcile
+type ReconciliationStore interface {
+
+	// Get reconciliation report
+	GetReport(string) (*types.ReconciliationReport, error)
+
+	// Get reconciliation reports, most recent first
+	GetReports() ([]*types.ReconciliationReport, error)
+}
+
+type ReconciliationStoreImpl struct {
+	collection *mongo.Collection
+}
+
+func NewReconciliationStore(config *PaymentStoreConfig) (ReconciliationStore, error) {
+	client, err := connect(config)
+	if err != nil {
+		return nil, err
+	}
+	return ReconciliationStoreImpl{
+		collection: client.Database(config.Database).Collection(config.ReconciliationCollection),
+	}, nil
+}
+
+func (s ReconciliationStoreImpl) GetReport(id string) (*types.ReconciliationReport, error) {
+	elem := &bson.D{}
+	err := s.collection.FindOne(context.Background(), bson.M{"_id": id}).Decode(elem)
+	if err != nil {
+		if isNoDocuments(err.Error()) {
+			return nil, nil
+		}
+		log.Printf("Error fetching reconciliation report with id %s: %s", id, err.Error())
+		return nil, err
+	}
+	return docToReport(*elem), nil
+}
+
+func (s ReconciliationStoreImpl) GetReports() ([]*types.ReconciliationReport, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "CreatedAt", Value: -1}})
+	cursor, err := s.collection.Find(context.Background(), bson.D{}, opts)
+	if err != nil {
+		log.Printf("Error fetching reconciliation reports: %s", err)
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	reports := make([]*types.ReconciliationReport, 0)
+	for cursor.Next(context.Background()) {
+		elem := &bson.D{}
+		if err := cursor.Decode(elem); err != nil {
+			log.Printf("Error parsing reconciliation report: %s", err)
+			return nil, err
+		}
+		reports = append(reports, docToReport(*elem))
+	}
+	if err := cursor.Err(); err != nil {
+		log.Printf("Error fetching reconciliation reports: %s", err)
+		return nil, err
+	}
+	return reports, nil
+}
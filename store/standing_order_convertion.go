@@ -0,0 +1,101 @@
+package store
+
+import (
+	"github.com/brunovale91/payment-api/types"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func standingOrderToDoc(order *types.StandingOrder) bson.M {
+	return bson.M{
+		"_id":               order.Id,
+		"Type":              order.Type,
+		"Version":           order.Version,
+		"OrganisationId":    order.OrganisationId,
+		"Status":            order.Status,
+		"CreatedBy":         order.CreatedBy,
+		"CreatedAt":         order.CreatedAt,
+		"Template":          attributesToDoc(order.Template),
+		"Recurrence":        recurrenceToDoc(order.Recurrence),
+		"Occurrences":       order.Occurrences,
+		"NextExecutionDate": order.NextExecutionDate,
+		"Generated":         generatedToDoc(order.Generated),
+	}
+}
+
+func docToStandingOrder(order bson.D) *types.StandingOrder {
+	orderBson := order.Map()
+	return &types.StandingOrder{
+		Id:                orderBson["_id"].(string),
+		Type:              docToString(orderBson["Type"]),
+		Version:           orderBson["Version"].(int64),
+		OrganisationId:    docToString(orderBson["OrganisationId"]),
+		Status:            docToString(orderBson["Status"]),
+		CreatedBy:         docToString(orderBson["CreatedBy"]),
+		CreatedAt:         docToTime(orderBson["CreatedAt"]),
+		Template:          docToAttributes(orderBson["Template"]),
+		Recurrence:        docToRecurrence(orderBson["Recurrence"]),
+		Occurrences:       docToInt(orderBson["Occurrences"]),
+		NextExecutionDate: docToTimePtr(orderBson["NextExecutionDate"]),
+		Generated:         docToGenerated(orderBson["Generated"]),
+	}
+}
+
+func recurrenceToDoc(recurrence *types.Recurrence) bson.M {
+	if recurrence != nil {
+		return bson.M{
+			"Frequency": recurrence.Frequency,
+			"Interval":  recurrence.Interval,
+			"StartDate": recurrence.StartDate,
+			"EndDate":   recurrence.EndDate,
+			"Count":     recurrence.Count,
+		}
+	}
+	return nil
+}
+
+func docToRecurrence(recurrence interface{}) *types.Recurrence {
+	if recurrence != nil {
+		recBson := recurrence.(bson.D).Map()
+		return &types.Recurrence{
+			Frequency: docToString(recBson["Frequency"]),
+			Interval:  docToInt(recBson["Interval"]),
+			StartDate: docToTimePtr(recBson["StartDate"]),
+			EndDate:   docToTimePtr(recBson["EndDate"]),
+			Count:     docToInt(recBson["Count"]),
+		}
+	}
+	return nil
+}
+
+func generatedToDoc(generated []*types.GeneratedPayment) bson.A {
+	array := bson.A{}
+	for _, payment := range generated {
+		array = append(array, bson.M{
+			"Occurrence":    payment.Occurrence,
+			"ExecutionDate": payment.ExecutionDate,
+			"PaymentId":     payment.PaymentId,
+			"Error":         payment.Error,
+			"GeneratedAt":   payment.GeneratedAt,
+		})
+	}
+	return array
+}
+
+func docToGenerated(value interface{}) []*types.GeneratedPayment {
+	array := docToArray(value)
+	if len(array) == 0 {
+		return nil
+	}
+	generated := make([]*types.GeneratedPayment, 0, len(array))
+	for _, item := range array {
+		paymentBson := item.(bson.D).Map()
+		generated = append(generated, &types.GeneratedPayment{
+			Occurrence:    docToInt(paymentBson["Occurrence"]),
+			ExecutionDate: docToTime(paymentBson["ExecutionDate"]),
+			PaymentId:     docToString(paymentBson["PaymentId"]),
+			Error:         docToString(paymentBson["Error"]),
+			GeneratedAt:   docToTime(paymentBson["GeneratedAt"]),
+		})
+	}
+	return generated
+}
@@ -0,0 +1,148 @@
+package store
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/brunovale91/payment-api/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type StandingOrderStore interface {
+
+	// Save standing order
+	CreateStandingOrder(*types.StandingOrder) (*types.StandingOrder, error)
+
+	// Update standing order status, occurrences and generated payments and
+	// return it. Fails with a VersionConflictError if its version changed
+	// since it was read.
+	UpdateStandingOrder(*types.StandingOrder) (*types.StandingOrder, error)
+
+	// Get standing order
+	GetStandingOrder(string) (*types.StandingOrder, error)
+
+	// Get standing orders, of one organisation when the id is not empty
+	GetStandingOrders(string) ([]*types.StandingOrder, error)
+
+	// Get up to limit active standing orders with an occurrence due at the
+	// given time, earliest first
+	GetDueStandingOrders(time.Time, int64) ([]*types.StandingOrder, error)
+}
+
+type StandingOrderStoreImpl struct {
+	collection *mongo.Collection
+}
+
+func NewStandingOrderStore(config *PaymentStoreConfig) (StandingOrderStore, error) {
+	client, err := connect(config)
+	if err != nil {
+		return nil, err
+	}
+	collection := client.Database(config.Database).Collection(config.StandingOrderCollection)
+	_, err = collection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{{Key: "Status", Value: 1}, {Key: "NextExecutionDate", Value: 1}},
+	})
+	if err != nil {
+		log.Printf("Error creating standing order index: %s", err.Error())
+		return nil, err
+	}
+	return StandingOrderStoreImpl{
+		collection: collection,
+	}, nil
+}
+
+func (s StandingOrderStoreImpl) CreateStandingOrder(order *types.StandingOrder) (*types.StandingOrder, error) {
+	_, err := s.collection.InsertOne(context.Background(), standingOrderToDoc(order))
+	if err != nil {
+		log.Printf("Error creating standing order with id %s: %s", order.Id, err.Error())
+		return nil, err
+	}
+	return order, nil
+}
+
+func (s StandingOrderStoreImpl) UpdateStandingOrder(order *types.StandingOrder) (*types.StandingOrder, error) {
+	updateDoc := bson.M{
+		"$inc": bson.M{
+			"Version": 1,
+		},
+		"$set": bson.M{
+			"Status":            order.Status,
+			"Occurrences":       order.Occurrences,
+			"NextExecutionDate": order.NextExecutionDate,
+			"Generated":         generatedToDoc(order.Generated),
+		},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	elem := &bson.D{}
+	filter := bson.M{"_id": order.Id, "Version": order.Version}
+	err := s.collection.FindOneAndUpdate(context.Background(), filter, updateDoc, opts).Decode(elem)
+	if err != nil {
+		if isNoDocuments(err.Error()) {
+			existing, err := s.GetStandingOrder(order.Id)
+			if err != nil || existing == nil {
+				return nil, err
+			}
+			return nil, VersionConflictError{Id: order.Id}
+		}
+		log.Printf("Error updating standing order with id %s: %s", order.Id, err.Error())
+		return nil, err
+	}
+	return docToStandingOrder(*elem), nil
+}
+
+func (s StandingOrderStoreImpl) GetStandingOrder(id string) (*types.StandingOrder, error) {
+	elem := &bson.D{}
+	err := s.collection.FindOne(context.Background(), bson.M{"_id": id}).Decode(elem)
+	if err != nil {
+		if isNoDocuments(err.Error()) {
+			return nil, nil
+		}
+		log.Printf("Error fetching standing order with id %s: %s", id, err.Error())
+		return nil, err
+	}
+	return docToStandingOrder(*elem), nil
+}
+
+func (s StandingOrderStoreImpl) GetStandingOrders(organisationId string) ([]*types.StandingOrder, error) {
+	filter := bson.M{}
+	if organisationId != "" {
+		filter["OrganisationId"] = organisationId
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "CreatedAt", Value: 1}})
+	return s.findStandingOrders(filter, opts)
+}
+
+func (s StandingOrderStoreImpl) GetDueStandingOrders(now time.Time, limit int64) ([]*types.StandingOrder, error) {
+	filter := bson.M{
+		"Status":            types.StandingOrderActive,
+		"NextExecutionDate": bson.M{"$lte": now},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "NextExecutionDate", Value: 1}}).SetLimit(limit)
+	return s.findStandingOrders(filter, opts)
+}
+
+func (s StandingOrderStoreImpl) findStandingOrders(filter bson.M, opts *options.FindOptions) ([]*types.StandingOrder, error) {
+	cursor, err := s.collection.Find(context.Background(), filter, opts)
+	if err != nil {
+		log.Printf("Error fetching standing orders: %s", err)
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	orders := make([]*types.StandingOrder, 0)
+	for cursor.Next(context.Background()) {
+		elem := &bson.D{}
+		if err := cursor.Decode(elem); err != nil {
+			log.Printf("Error parsing standing order: %s", err)
+			return nil, err
+		}
+		orders = append(orders, docToStandingOrder(*elem))
+	}
+	if err := cursor.Err(); err != nil {
+		log.Printf("Error fetching standing orders: %s", err)
+		return nil, err
+	}
+	return orders, nil
+}
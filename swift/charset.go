@@ -0,0 +1,43 @@
+package swift
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Characters of the SWIFT X character set besides letters, digits and space
+const swiftPunctuation = "/-?:().,'+"
+
+// Letters that do not decompose into a base letter and accents
+var letterTransliterations = map[rune]string{
+	'ß': "ss", 'Æ': "AE", 'æ': "ae", 'Ø': "O", 'ø': "o", 'Œ': "OE", 'œ': "oe",
+	'Ł': "L", 'ł': "l", 'Đ': "D", 'đ': "d", 'Þ': "TH", 'þ': "th", 'Ð': "D", 'ð': "d",
+	'&': "+", '"': "'", '_': "-", '\t': " ",
+}
+
+// Transliterate text to the SWIFT X character set: accents are removed,
+// letters without a base form are spelled out and any other character is
+// replaced by a full stop
+func Transliterate(value string) string {
+	var result strings.Builder
+	for _, char := range norm.NFD.String(value) {
+		switch {
+		case unicode.Is(unicode.Mn, char):
+			continue
+		case isSwiftCharacter(char):
+			result.WriteRune(char)
+		case letterTransliterations[char] != "":
+			result.WriteString(letterTransliterations[char])
+		default:
+			result.WriteRune('.')
+		}
+	}
+	return result.String()
+}
+
+func isSwiftCharacter(char rune) bool {
+	return char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z' || char >= '0' && char <= '9' ||
+		char == ' ' || strings.ContainsRune(swiftPunctuation, char)
+}
@@ -0,0 +1,347 @@
+package swift
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brunovale91/payment-api/types"
+)
+
+// Field 20, 50K and 59 line lengths and counts of the MT103 format
+const (
+	maxReferenceLength = 16
+	maxLineLength      = 35
+	maxPartyLines      = 4
+)
+
+// Details of charges are shared between debtor and beneficiary when not set
+const defaultCharges = "SHA"
+
+// Details of charges of ISO 20022 charge bearer codes
+var bearerCharges = map[string]string{
+	types.BearerDebtor:   "OUR",
+	types.BearerCreditor: "BEN",
+	types.BearerShared:   "SHA",
+}
+
+type MT103Config struct {
+	// BIC of the sending institution, written to the basic header block
+	SenderBIC string
+}
+
+// Payments cannot be expressed as MT103, or a message could not be read
+type FormatError struct {
+	Message string
+}
+
+func (e FormatError) Error() string {
+	return e.Message
+}
+
+// Single customer credit transfer, the fields this api can fill or read
+type MT103 struct {
+	SenderBIC             string
+	ReceiverBIC           string
+	SenderReference       string
+	BankOperationCode     string
+	ValueDate             time.Time
+	Currency              string
+	Amount                float64
+	OrderingCustomer      *Customer
+	BeneficiaryBank       string
+	Beneficiary           *Customer
+	RemittanceInformation []string
+	DetailsOfCharges      string
+
+	// Fields 71F, charges taken from the amount, and 71G, charges prepaid
+	// for the receiver
+	SenderCharges   []*types.Charge
+	ReceiverCharges *types.Charge
+}
+
+// Party of fields 50K and 59, the account line is optional
+type Customer struct {
+	Account string
+	Name    string
+	Address []string
+}
+
+var bicPattern = regexp.MustCompile(`^[A-Z]{6}[A-Z0-9]{2}([A-Z0-9]{3})?$`)
+var ibanPattern = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[A-Z0-9]+$`)
+
+// Convert payment to MT103, beneficiary banks must be identified by BIC
+func NewMT103(config *MT103Config, payment *types.Payment, now time.Time) (*MT103, error) {
+	attributes := payment.Attributes
+	if attributes == nil || attributes.DebtorParty == nil || attributes.BeneficiaryParty == nil {
+		return nil, FormatError{Message: fmt.Sprintf("Payment %s has no parties", payment.Id)}
+	}
+	if attributes.Currency == "" {
+		return nil, FormatError{Message: fmt.Sprintf("Payment %s has no currency", payment.Id)}
+	}
+	beneficiaryBank := partyBIC(attributes.BeneficiaryParty)
+	if beneficiaryBank == "" {
+		return nil, FormatError{Message: fmt.Sprintf("Payment %s beneficiary bank is not identified by BIC", payment.Id)}
+	}
+	valueDate := now
+	if attributes.ProcessingDate != "" {
+		date, err := time.Parse("2006-01-02", attributes.ProcessingDate)
+		if err != nil {
+			return nil, FormatError{Message: fmt.Sprintf("Payment %s has an invalid processing date", payment.Id)}
+		}
+		valueDate = date
+	}
+	senderBIC := config.SenderBIC
+	if senderBIC == "" {
+		senderBIC = partyBIC(attributes.DebtorParty)
+	}
+	message := &MT103{
+		SenderBIC:         senderBIC,
+		ReceiverBIC:       beneficiaryBank,
+		SenderReference:   senderReference(payment),
+		BankOperationCode: "CRED",
+		ValueDate:         valueDate,
+		Currency:          attributes.Currency,
+		Amount:            attributes.Amount,
+		OrderingCustomer:  toCustomer(attributes.DebtorParty),
+		BeneficiaryBank:   beneficiaryBank,
+		Beneficiary:       toCustomer(attributes.BeneficiaryParty),
+		DetailsOfCharges:  defaultCharges,
+	}
+	if charges := attributes.ChargesInformation; charges != nil {
+		if details, ok := bearerCharges[charges.BearerCode]; ok {
+			message.DetailsOfCharges = details
+		}
+		if err := setCharges(message, charges); err != nil {
+			return nil, FormatError{Message: fmt.Sprintf("Payment %s %s", payment.Id, err.Error())}
+		}
+	}
+	if attributes.Reference != "" {
+		message.RemittanceInformation = splitLines(Transliterate(attributes.Reference), maxLineLength, maxPartyLines)
+	}
+	return message, nil
+}
+
+// Render message with basic, application and text blocks
+func MarshalMT103(message *MT103) []byte {
+	var text strings.Builder
+	if bicPattern.MatchString(message.SenderBIC) {
+		fmt.Fprintf(&text, "{1:F01%s0000000000}", logicalTerminal(message.SenderBIC))
+	}
+	if bicPattern.MatchString(message.ReceiverBIC) {
+		fmt.Fprintf(&text, "{2:I103%sN}", logicalTerminal(message.ReceiverBIC))
+	}
+	text.WriteString("{4:\r\n")
+	writeField(&text, "20", message.SenderReference)
+	writeField(&text, "23B", message.BankOperationCode)
+	writeField(&text, "32A", message.ValueDate.Format("060102")+message.Currency+formatAmount(message.Amount))
+	writeField(&text, "50K", customerLines(message.OrderingCustomer)...)
+	writeField(&text, "57A", message.BeneficiaryBank)
+	writeField(&text, "59", customerLines(message.Beneficiary)...)
+	if len(message.RemittanceInformation) > 0 {
+		writeField(&text, "70", message.RemittanceInformation...)
+	}
+	writeField(&text, "71A", message.DetailsOfCharges)
+	for _, charge := range message.SenderCharges {
+		writeField(&text, "71F", formatCharge(charge))
+	}
+	if message.ReceiverCharges != nil {
+		writeField(&text, "71G", formatCharge(message.ReceiverCharges))
+	}
+	text.WriteString("-}")
+	return []byte(text.String())
+}
+
+// Payment of an MT103 message, the organisation is not part of the message
+func (m *MT103) ToPayment(organisationId string) *types.Payment {
+	attributes := &types.PaymentAttributes{
+		Amount:            m.Amount,
+		Currency:          m.Currency,
+		EndToEndReference: m.SenderReference,
+		PaymentScheme:     "SWIFT",
+		ProcessingDate:    m.ValueDate.Format("2006-01-02"),
+		Reference:         strings.Join(m.RemittanceInformation, " "),
+		DebtorParty:       fromCustomer(m.OrderingCustomer),
+		BeneficiaryParty:  fromCustomer(m.Beneficiary),
+	}
+	if m.SenderBIC != "" {
+		attributes.DebtorParty.BankId = m.SenderBIC
+		attributes.DebtorParty.BankIdCode = "SWBIC"
+	}
+	if m.BeneficiaryBank != "" {
+		attributes.BeneficiaryParty.BankId = m.BeneficiaryBank
+		attributes.BeneficiaryParty.BankIdCode = "SWBIC"
+	}
+	for bearer, charges := range bearerCharges {
+		if charges == m.DetailsOfCharges {
+			attributes.ChargesInformation = &types.ChargesInformation{BearerCode: bearer}
+		}
+	}
+	if charges := attributes.ChargesInformation; charges != nil {
+		switch m.DetailsOfCharges {
+		case "BEN":
+			for _, charge := range m.SenderCharges {
+				charges.ReceiverChargesAmount += charge.Amount
+				charges.ReceiverChargesCurrency = charge.Currency
+			}
+			if charges.ReceiverChargesAmount == 0 {
+				charges.ReceiverChargesCurrency = ""
+			}
+		case "SHA":
+			charges.SenderCharges = m.SenderCharges
+		case "OUR":
+			if m.ReceiverCharges != nil {
+				charges.SenderCharges = []*types.Charge{m.ReceiverCharges}
+			}
+		}
+	}
+	return &types.Payment{
+		Type:           "Payment",
+		OrganisationId: organisationId,
+		Attributes:     attributes,
+	}
+}
+
+// Charges of a payment as fields 71F and 71G of its details of charges.
+// Creditors bear charges taken from the amount, which BEN messages must
+// state even when there are none. Debtors prepay the receiver charges of OUR
+// messages, which have a single 71G field.
+func setCharges(message *MT103, charges *types.ChargesInformation) error {
+	switch message.DetailsOfCharges {
+	case "BEN":
+		currency := charges.ReceiverChargesCurrency
+		if currency == "" {
+			currency = message.Currency
+		}
+		message.SenderCharges = []*types.Charge{{Amount: charges.ReceiverChargesAmount, Currency: currency}}
+	case "SHA":
+		message.SenderCharges = charges.SenderCharges
+	case "OUR":
+		for _, charge := range charges.SenderCharges {
+			if message.ReceiverCharges == nil {
+				message.ReceiverCharges = &types.Charge{Currency: charge.Currency}
+			}
+			if charge.Currency != message.ReceiverCharges.Currency {
+				return fmt.Errorf("charges of bearer %s are in several currencies", charges.BearerCode)
+			}
+			message.ReceiverCharges.Amount += charge.Amount
+		}
+	}
+	return nil
+}
+
+func writeField(text *strings.Builder, tag string, lines ...string) {
+	fmt.Fprintf(text, ":%s:%s\r\n", tag, strings.Join(lines, "\r\n"))
+}
+
+// Sender reference must not start or end with a slash nor contain "//"
+func senderReference(payment *types.Payment) string {
+	reference := payment.Attributes.EndToEndReference
+	if reference == "" {
+		reference = strings.Replace(payment.Id, "-", "", -1)
+	}
+	reference = Transliterate(reference)
+	for strings.Contains(reference, "//") {
+		reference = strings.Replace(reference, "//", "/", -1)
+	}
+	reference = strings.Trim(reference, "/ ")
+	if len(reference) > maxReferenceLength {
+		reference = strings.TrimRight(reference[:maxReferenceLength], "/")
+	}
+	return reference
+}
+
+func toCustomer(party *types.PaymentParty) *Customer {
+	customer := &Customer{
+		Account: Transliterate(party.AccountNumber),
+		Name:    Transliterate(party.Name),
+	}
+	for _, line := range party.Address {
+		customer.Address = append(customer.Address, Transliterate(line))
+	}
+	return customer
+}
+
+func fromCustomer(customer *Customer) *types.PaymentParty {
+	party := &types.PaymentParty{}
+	if customer == nil {
+		return party
+	}
+	party.Name = customer.Name
+	party.Address = customer.Address
+	if customer.Account != "" {
+		party.AccountNumber = customer.Account
+		party.AccountNumberCode = "BBAN"
+		if ibanPattern.MatchString(customer.Account) {
+			party.AccountNumberCode = "IBAN"
+		}
+	}
+	return party
+}
+
+// Account line followed by name and address, within four lines of 35
+func customerLines(customer *Customer) []string {
+	lines := make([]string, 0, maxPartyLines+1)
+	if customer.Account != "" {
+		lines = append(lines, "/"+customer.Account)
+	}
+	details := splitLines(customer.Name, maxLineLength, maxPartyLines)
+	for _, line := range customer.Address {
+		details = append(details, splitLines(line, maxLineLength, maxPartyLines)...)
+	}
+	if len(details) > maxPartyLines {
+		details = details[:maxPartyLines]
+	}
+	return append(lines, details...)
+}
+
+func splitLines(value string, length int, count int) []string {
+	lines := make([]string, 0, count)
+	for len(value) > 0 && len(lines) < count {
+		if len(value) <= length {
+			lines = append(lines, value)
+			break
+		}
+		lines = append(lines, value[:length])
+		value = value[length:]
+	}
+	return lines
+}
+
+func partyBIC(party *types.PaymentParty) string {
+	if party.BankIdCode != "BIC" && party.BankIdCode != "SWBIC" {
+		return ""
+	}
+	return party.BankId
+}
+
+// Logical terminal address, BIC8 followed by a terminal code and the branch
+func logicalTerminal(bic string) string {
+	branch := "XXX"
+	if len(bic) == 11 {
+		branch = bic[8:]
+	}
+	return bic[:8] + "A" + branch
+}
+
+// SWIFT amounts use a decimal comma and always include it, e.g. 10,
+func formatAmount(amount float64) string {
+	minorUnits := int64(math.Round(amount * 100))
+	fraction := strings.TrimRight(fmt.Sprintf("%02d", minorUnits%100), "0")
+	return strconv.FormatInt(minorUnits/100, 10) + "," + fraction
+}
+
+// Fields 71F and 71G, currency and amount, e.g. EUR2,5
+func formatCharge(charge *types.Charge) string {
+	return charge.Currency + formatAmount(charge.Amount)
+}
+
+func parseAmount(value string) (float64, error) {
+	if !strings.Contains(value, ",") {
+		return 0, fmt.Errorf("amount %s has no decimal comma", value)
+	}
+	return strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+}
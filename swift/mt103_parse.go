@@ -0,0 +1,219 @@
+package swift
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/brunovale91/payment-api/types"
+)
+
+// Field tag at the start of a line, e.g. :32A:
+var fieldPattern = regexp.MustCompile(`^:([0-9]{2}[A-Z]?):(.*)$`)
+
+// Fields every MT103 carries
+var mandatoryFields = []string{"20", "23B", "32A", "50K", "59", "71A"}
+
+// Field 71F repeats once per bank that took charges
+const senderChargesTag = "71F"
+
+// Read one or more MT103 messages, either complete with their header blocks
+// or as bare text blocks
+func ParseMT103(data []byte) ([]*MT103, error) {
+	text := strings.Replace(string(data), "\r\n", "\n", -1)
+	messages := make([]*MT103, 0)
+	if !strings.Contains(text, "{4:") {
+		if strings.TrimSpace(text) == "" {
+			return nil, FormatError{Message: "No MT103 message found"}
+		}
+		message, err := parseTextBlock(text)
+		if err != nil {
+			return nil, err
+		}
+		return append(messages, message), nil
+	}
+	for strings.Contains(text, "{4:") {
+		start := strings.Index(text, "{4:")
+		end := strings.Index(text[start:], "\n-}")
+		if end < 0 {
+			return nil, FormatError{Message: "MT103 text block is not terminated"}
+		}
+		headers := text[:start]
+		message, err := parseTextBlock(text[start+len("{4:") : start+end])
+		if err != nil {
+			return nil, err
+		}
+		message.SenderBIC = headerSender(headers)
+		messages = append(messages, message)
+		text = text[start+end+len("\n-}"):]
+	}
+	return messages, nil
+}
+
+func parseTextBlock(text string) (*MT103, error) {
+	fields := make(map[string][]string)
+	tag := ""
+	for _, line := range strings.Split(strings.Trim(text, "\n"), "\n") {
+		line = strings.TrimRight(line, " ")
+		if line == "-" {
+			break
+		}
+		if match := fieldPattern.FindStringSubmatch(line); match != nil {
+			tag = match[1]
+			if tag == senderChargesTag {
+				fields[tag] = append(fields[tag], match[2])
+			} else {
+				fields[tag] = []string{match[2]}
+			}
+		} else if tag != "" {
+			fields[tag] = append(fields[tag], line)
+		} else if line != "" {
+			return nil, FormatError{Message: "Unexpected MT103 line " + line}
+		}
+	}
+	for _, tag := range mandatoryFields {
+		if _, ok := fields[tag]; !ok {
+			return nil, FormatError{Message: "MT103 field " + tag + " is missing"}
+		}
+	}
+	if fields["23B"][0] != "CRED" {
+		return nil, FormatError{Message: "MT103 bank operation code " + fields["23B"][0] + " is not supported"}
+	}
+	message := &MT103{
+		SenderReference:       fields["20"][0],
+		BankOperationCode:     fields["23B"][0],
+		OrderingCustomer:      parseCustomer(fields["50K"]),
+		Beneficiary:           parseCustomer(fields["59"]),
+		RemittanceInformation: fields["70"],
+		DetailsOfCharges:      fields["71A"][0],
+	}
+	if bank, ok := fields["57A"]; ok {
+		message.BeneficiaryBank = bankIdentifier(bank)
+	}
+	if err := parseValueDateAmount(fields["32A"][0], message); err != nil {
+		return nil, err
+	}
+	if err := parseCharges(fields, message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// Fields 71F and 71G, allowed by the details of charges: BEN messages state
+// the charges taken in one currency, only OUR messages prepay receiver
+// charges and they take none from the amount
+func parseCharges(fields map[string][]string, message *MT103) error {
+	for _, value := range fields[senderChargesTag] {
+		charge, err := parseCharge(value)
+		if err != nil {
+			return FormatError{Message: "MT103 field 71F is invalid"}
+		}
+		message.SenderCharges = append(message.SenderCharges, charge)
+	}
+	if value, ok := fields["71G"]; ok {
+		charge, err := parseCharge(value[0])
+		if err != nil {
+			return FormatError{Message: "MT103 field 71G is invalid"}
+		}
+		message.ReceiverCharges = charge
+	}
+	switch message.DetailsOfCharges {
+	case "BEN":
+		if len(message.SenderCharges) == 0 {
+			return FormatError{Message: "MT103 field 71F is missing for details of charges BEN"}
+		}
+		for _, charge := range message.SenderCharges {
+			if charge.Currency != message.SenderCharges[0].Currency {
+				return FormatError{Message: "MT103 fields 71F of details of charges BEN are in several currencies"}
+			}
+		}
+	case "OUR":
+		if len(message.SenderCharges) > 0 {
+			return FormatError{Message: "MT103 field 71F is not allowed for details of charges OUR"}
+		}
+	}
+	if message.ReceiverCharges != nil && message.DetailsOfCharges != "OUR" {
+		return FormatError{Message: "MT103 field 71G is only allowed for details of charges OUR"}
+	}
+	return nil
+}
+
+func parseCharge(value string) (*types.Charge, error) {
+	if len(value) < 4 {
+		return nil, FormatError{Message: "charge " + value + " has no amount"}
+	}
+	amount, err := parseAmount(value[3:])
+	if err != nil {
+		return nil, err
+	}
+	return &types.Charge{Amount: amount, Currency: value[:3]}, nil
+}
+
+// Field 32A, value date YYMMDD, currency and amount, e.g. 190501GBP12,34
+func parseValueDateAmount(value string, message *MT103) error {
+	if len(value) < 10 {
+		return FormatError{Message: "MT103 field 32A is invalid"}
+	}
+	date, err := time.Parse("060102", value[:6])
+	if err != nil {
+		return FormatError{Message: "MT103 field 32A has an invalid value date"}
+	}
+	amount, err := parseAmount(value[9:])
+	if err != nil {
+		return FormatError{Message: "MT103 field 32A has an invalid amount"}
+	}
+	message.ValueDate = date
+	message.Currency = value[6:9]
+	message.Amount = amount
+	return nil
+}
+
+func parseCustomer(lines []string) *Customer {
+	customer := &Customer{}
+	if len(lines) > 0 && strings.HasPrefix(lines[0], "/") {
+		customer.Account = strings.TrimPrefix(lines[0], "/")
+		lines = lines[1:]
+	}
+	if len(lines) > 0 {
+		customer.Name = lines[0]
+		customer.Address = lines[1:]
+	}
+	if len(customer.Address) == 0 {
+		customer.Address = nil
+	}
+	return customer
+}
+
+// Field 57A may start with a party identifier line before the BIC
+func bankIdentifier(lines []string) string {
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "/") {
+			return line
+		}
+	}
+	return ""
+}
+
+// Sender of the message: the basic header terminal of messages sent by us,
+// the application header terminal of messages received
+func headerSender(headers string) string {
+	if index := strings.Index(headers, "{2:O103"); index >= 0 && len(headers) >= index+len("{2:O103")+22 {
+		return terminalBIC(headers[index+len("{2:O103")+10:])
+	}
+	if index := strings.Index(headers, "{1:F01"); index >= 0 && len(headers) >= index+len("{1:F01")+12 {
+		return terminalBIC(headers[index+len("{1:F01"):])
+	}
+	return ""
+}
+
+// BIC of a logical terminal address, the branch is dropped when it is XXX
+func terminalBIC(terminal string) string {
+	bic := terminal[:8]
+	if branch := terminal[9:12]; branch != "XXX" {
+		bic += branch
+	}
+	if !bicPattern.MatchString(bic) {
+		return ""
+	}
+	return bic
+}
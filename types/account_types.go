@@ -0,0 +1,26 @@
+package types
+
+import "time"
+
+// Saved account of an organisation, payments reference it instead of
+// repeating the party details
+type Account struct {
+	Type              string    `json:"type,omitempty"`
+	Id                string    `json:"id,omitempty"`
+	Version           int64     `json:"version"`
+	OrganisationId    string    `json:"organisation_id,omitempty"`
+	BankId            string    `json:"bank_id,omitempty"`
+	BankIdCode        string    `json:"bank_id_code,omitempty"`
+	AccountNumber     string    `json:"account_number,omitempty"`
+	AccountNumberCode string    `json:"account_number_code,omitempty"`
+	Name              string    `json:"name,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+type Accounts struct {
+	Data []*Account `json:"data"`
+}
+
+type AccountDelete struct {
+	Deleted bool `json:"deleted,omitempty"`
+}
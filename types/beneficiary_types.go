@@ -0,0 +1,40 @@
+package types
+
+import "time"
+
+// Beneficiary statuses, beneficiaries are unverified when saved or edited
+const (
+	BeneficiaryUnverified = "unverified"
+	BeneficiaryVerified   = "verified"
+)
+
+// Saved beneficiary of an organisation, payments reference it by
+// beneficiary_id once it is verified
+type Beneficiary struct {
+	Type           string        `json:"type,omitempty"`
+	Id             string        `json:"id,omitempty"`
+	Version        int64         `json:"version"`
+	OrganisationId string        `json:"organisation_id,omitempty"`
+	Name           string        `json:"name,omitempty"`
+	Party          *PaymentParty `json:"party,omitempty"`
+	Status         string        `json:"status,omitempty"`
+
+	// Users that saved, last edited and verified the beneficiary
+	CreatedBy  string     `json:"created_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedBy  string     `json:"updated_by,omitempty"`
+	VerifiedBy string     `json:"verified_by,omitempty"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+
+	// Payments created to the beneficiary
+	UsageCount int        `json:"usage_count"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+type Beneficiaries struct {
+	Data []*Beneficiary `json:"data"`
+}
+
+type BeneficiaryDelete struct {
+	Deleted bool `json:"deleted,omitempty"`
+}
@@ -0,0 +1,8 @@
+package types
+
+// Next business day after a date in a calendar, dates formatted as 2006-01-02
+type BusinessDay struct {
+	CalendarId      string `json:"calendar_id"`
+	Date            string `json:"date"`
+	NextBusinessDay string `json:"next_business_day"`
+}
@@ -0,0 +1,31 @@
+package types
+
+import "time"
+
+const (
+	PaymentCreated      = "payment.created"
+	PaymentUpdated      = "payment.updated"
+	PaymentDeleted      = "payment.deleted"
+	PaymentReconciled   = "payment.reconciled"
+	PaymentApproved     = "payment.approved"
+	PaymentRescheduled  = "payment.rescheduled"
+	PaymentReleased     = "payment.released"
+	PaymentCancellation = "payment.cancelled"
+	PaymentRefunded     = "payment.refunded"
+)
+
+type PaymentEvent struct {
+	Id             string    `json:"id"`
+	Type           string    `json:"type"`
+	PaymentId      string    `json:"payment_id"`
+	OrganisationId string    `json:"organisation_id"`
+	Payment        *Payment  `json:"payment,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+
+	// Order of the event among the events of its organisation, assigned when
+	// the write that caused it commits
+	Sequence int64 `json:"sequence"`
+
+	// Position of the event in a stream, sent as the Server-Sent Events id
+	Cursor string `json:"-"`
+}
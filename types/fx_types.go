@@ -0,0 +1,48 @@
+package types
+
+import "time"
+
+// Exchange rate of one base currency unit in the quote currency, applied from
+// its effective time until a later rate of the pair takes effect
+type FxRate struct {
+	Id            string    `json:"id,omitempty"`
+	BaseCurrency  string    `json:"base_currency"`
+	QuoteCurrency string    `json:"quote_currency"`
+	Rate          float64   `json:"rate"`
+	EffectiveAt   time.Time `json:"effective_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type FxRates struct {
+	Data []*FxRate `json:"data"`
+}
+
+// Quote converting an instructed amount to the settlement currency
+type QuoteRequest struct {
+	InstructedCurrency string  `json:"instructed_currency"`
+	InstructedAmount   float64 `json:"instructed_amount"`
+	SettlementCurrency string  `json:"settlement_currency"`
+}
+
+// Rate guaranteed until the quote expires, payments reference it by id. A
+// quote is used by one payment only.
+type FxQuote struct {
+	Id                 string    `json:"id"`
+	InstructedCurrency string    `json:"instructed_currency"`
+	InstructedAmount   float64   `json:"instructed_amount"`
+	SettlementCurrency string    `json:"settlement_currency"`
+	SettlementAmount   float64   `json:"settlement_amount"`
+	Rate               float64   `json:"rate"`
+	RateId             string    `json:"rate_id"`
+	CreatedAt          time.Time `json:"created_at"`
+	ExpiresAt          time.Time `json:"expires_at"`
+	PaymentId          string    `json:"payment_id,omitempty"`
+}
+
+// Rate a payment was converted at when written, and the quote it came from
+type AppliedRate struct {
+	RateId    string    `json:"rate_id"`
+	Rate      float64   `json:"rate"`
+	QuoteId   string    `json:"quote_id,omitempty"`
+	AppliedAt time.Time `json:"applied_at"`
+}
@@ -0,0 +1,40 @@
+package types
+
+import "time"
+
+// Ledger entry directions, balances are credits less debits
+const (
+	Debit  = "debit"
+	Credit = "credit"
+)
+
+// Entry of a payment on an account. Entries posted together for a payment
+// event share a transaction id and sum to zero per currency.
+type LedgerEntry struct {
+	Id            string    `json:"id"`
+	TransactionId string    `json:"transaction_id"`
+	PaymentId     string    `json:"payment_id"`
+	EventType     string    `json:"event_type"`
+	AccountId     string    `json:"account_id"`
+	Currency      string    `json:"currency"`
+	Direction     string    `json:"direction"`
+	Amount        float64   `json:"amount"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type LedgerEntries struct {
+	Data []*LedgerEntry `json:"data"`
+}
+
+// Balances of an account, one per currency it has entries in
+type AccountBalance struct {
+	AccountId string             `json:"account_id"`
+	Balances  []*CurrencyBalance `json:"balances"`
+}
+
+type CurrencyBalance struct {
+	Currency string  `json:"currency"`
+	Debits   float64 `json:"debits"`
+	Credits  float64 `json:"credits"`
+	Balance  float64 `json:"balance"`
+}
@@ -0,0 +1,26 @@
+package types
+
+// Limits of an organisation in payment amount units, zero is unlimited.
+// Amount limits apply to the payments of each currency separately.
+type Limits struct {
+	MaxAmount     float64 `json:"max_amount,omitempty"`
+	DailyAmount   float64 `json:"daily_amount,omitempty"`
+	MonthlyAmount float64 `json:"monthly_amount,omitempty"`
+	HourlyCount   int     `json:"hourly_count,omitempty"`
+}
+
+// Limits of an organisation and how much of them its payments use. Days and
+// months are UTC calendar periods, the hourly count covers the last hour.
+type LimitUsage struct {
+	OrganisationId string           `json:"organisation_id"`
+	Limits         *Limits          `json:"limits"`
+	Currencies     []*CurrencyUsage `json:"currencies"`
+	HourlyCount    int              `json:"hourly_count"`
+}
+
+// Amounts of the payments in a currency this month and day
+type CurrencyUsage struct {
+	Currency      string  `json:"currency"`
+	DailyAmount   float64 `json:"daily_amount"`
+	MonthlyAmount float64 `json:"monthly_amount"`
+}
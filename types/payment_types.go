@@ -1,8 +1,22 @@
 package types
 
+import "time"
+
 type HttpError struct {
-	StatusText string   `json:"status"`
-	Messages   []string `json:"messages"`
+	StatusText string        `json:"status"`
+	Messages   []string      `json:"messages"`
+	Errors     []*FieldError `json:"errors,omitempty"`
+
+	// Id of the existing payment a request conflicts with
+	ExistingId string `json:"existing_id,omitempty"`
+
+	// Organisation limit a payment would exceed, e.g. daily_amount
+	Limit string `json:"limit,omitempty"`
+}
+
+type FieldError struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
 }
 
 type Payments struct {
@@ -18,23 +32,177 @@ type PaymentDelete struct {
 	Deleted bool `json:"deleted,omitempty"`
 }
 
+// Payment statuses, payments without one predate approvals and are accepted
+const (
+	PaymentPendingApproval = "pending_approval"
+	PaymentScheduled       = "scheduled"
+	PaymentAccepted        = "accepted"
+	PaymentCancelled       = "cancelled"
+)
+
 type Payment struct {
 	Type           string             `json:"type,omitempty"`
 	Id             string             `json:"id,omitempty"`
 	Version        int64              `json:"version"`
 	OrganisationId string             `json:"organisation_id,omitempty"`
 	Attributes     *PaymentAttributes `json:"attributes,omitempty"`
+	CreatedAt      time.Time          `json:"created_at"`
+	Status         string             `json:"status,omitempty"`
+
+	// Payments with an execution date stay scheduled until it is due
+	ExecutionDate *time.Time `json:"execution_date,omitempty"`
+
+	// Users that created and last updated the payment, and its approvals
+	CreatedBy string             `json:"created_by,omitempty"`
+	UpdatedBy string             `json:"updated_by,omitempty"`
+	Approvals []*PaymentApproval `json:"approvals,omitempty"`
+
+	// Set when a similar payment was created shortly before this one
+	PossibleDuplicate bool `json:"possible_duplicate,omitempty"`
+
+	// Standing order the payment was generated for
+	StandingOrderId string `json:"standing_order_id,omitempty"`
+
+	// Refunds return money of the related payment for an ISO return reason,
+	// the related payment lists them
+	RelatedPaymentId string           `json:"related_payment_id,omitempty"`
+	ReturnReason     string           `json:"return_reason,omitempty"`
+	Refunds          []*PaymentRefund `json:"refunds,omitempty"`
+
+	Cancellation *Cancellation `json:"cancellation,omitempty"`
+
+	// Rate the settlement amount was converted at
+	Fx *AppliedRate `json:"fx,omitempty"`
+
+	Reconciliation *PaymentReconciliation `json:"reconciliation,omitempty"`
+}
+
+// New execution date of a scheduled payment
+type PaymentSchedule struct {
+	ExecutionDate *time.Time `json:"execution_date"`
+}
+
+// Refund of a payment, for the remaining refundable amount when Amount is
+// zero. The end to end reference defaults to the payment one followed by the
+// refund number.
+type RefundRequest struct {
+	Amount            float64 `json:"amount,omitempty"`
+	ReasonCode        string  `json:"reason_code"`
+	EndToEndReference string  `json:"end_to_end_reference,omitempty"`
+}
+
+type PaymentRefund struct {
+	PaymentId    string    `json:"payment_id"`
+	Amount       float64   `json:"amount"`
+	ReturnReason string    `json:"return_reason"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type CancellationRequest struct {
+	ReasonCode string `json:"reason_code"`
+}
+
+// Cancellation of a payment for an ISO cancellation reason
+type Cancellation struct {
+	ReasonCode  string    `json:"reason_code"`
+	CancelledBy string    `json:"cancelled_by,omitempty"`
+	CancelledAt time.Time `json:"cancelled_at"`
+}
+
+type PaymentApproval struct {
+	ApprovedBy string    `json:"approved_by"`
+	ApprovedAt time.Time `json:"approved_at"`
 }
 
 type PaymentAttributes struct {
 	Amount            float64       `json:"amount,omitempty"`
+	Currency          string        `json:"currency,omitempty"`
 	BeneficiaryParty  *PaymentParty `json:"beneficiary_party,omitempty"`
 	DebtorParty       *PaymentParty `json:"debtor_party,omitempty"`
 	EndToEndReference string        `json:"end_to_end_reference,omitempty"`
+	PaymentScheme     string        `json:"payment_scheme,omitempty"`
+	SchemePaymentType string        `json:"scheme_payment_type,omitempty"`
+	ProcessingDate    string        `json:"processing_date,omitempty"`
+	PaymentPurpose    string        `json:"payment_purpose,omitempty"`
+	Reference         string        `json:"reference,omitempty"`
+	NumericReference  string        `json:"numeric_reference,omitempty"`
+
+	// Saved accounts of the parties, resolved into the parties when the
+	// payment is created and kept as they were then
+	DebtorAccountId      string `json:"debtor_account_id,omitempty"`
+	BeneficiaryAccountId string `json:"beneficiary_account_id,omitempty"`
+
+	// Saved beneficiary of the organisation, resolved into the beneficiary
+	// party like accounts
+	BeneficiaryId string `json:"beneficiary_id,omitempty"`
+
+	// Payments settled in another currency convert the instructed amount,
+	// the amount in the currency of the payment, at the rate of the quote
+	// or else the current rate
+	InstructedAmount   float64 `json:"instructed_amount,omitempty"`
+	InstructedCurrency string  `json:"instructed_currency,omitempty"`
+	SettlementAmount   float64 `json:"settlement_amount,omitempty"`
+	SettlementCurrency string  `json:"settlement_currency,omitempty"`
+	QuoteId            string  `json:"quote_id,omitempty"`
+
+	// Who bears the charges, calculated by the organisation pricing rules
+	ChargesInformation *ChargesInformation `json:"charges_information,omitempty"`
+}
+
+// ISO 20022 charge bearer codes
+const (
+	BearerDebtor    = "DEBT"
+	BearerCreditor  = "CRED"
+	BearerShared    = "SHAR"
+	BearerFollowing = "SLEV"
+)
+
+// Charges of the sending institution, paid by the debtor on top of the amount
+// or deducted from the amount the beneficiary receives
+type ChargesInformation struct {
+	BearerCode              string    `json:"bearer_code,omitempty"`
+	SenderCharges           []*Charge `json:"sender_charges,omitempty"`
+	ReceiverChargesAmount   float64   `json:"receiver_charges_amount,omitempty"`
+	ReceiverChargesCurrency string    `json:"receiver_charges_currency,omitempty"`
+}
+
+type Charge struct {
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
 }
 
 type PaymentParty struct {
-	BankId     string `json:"bank_id,omitempty"`
-	BankIdCode string `json:"bank_id_code,omitempty"`
-	Name       string `json:"name,omitempty"`
+	BankId            string   `json:"bank_id,omitempty"`
+	BankIdCode        string   `json:"bank_id_code,omitempty"`
+	Name              string   `json:"name,omitempty"`
+	AccountNumber     string   `json:"account_number,omitempty"`
+	AccountNumberCode string   `json:"account_number_code,omitempty"`
+	AccountName       string   `json:"account_name,omitempty"`
+	AccountType       *int     `json:"account_type,omitempty"`
+	Address           []string `json:"address,omitempty"`
+	Country           string   `json:"country,omitempty"`
+	BankAddress       []string `json:"bank_address,omitempty"`
+}
+
+// Payments of an organisation sharing an end to end reference
+type DuplicateReference struct {
+	OrganisationId    string   `json:"organisation_id"`
+	EndToEndReference string   `json:"end_to_end_reference"`
+	PaymentIds        []string `json:"payment_ids"`
+}
+
+// Listing filter, empty fields match any payment
+type PaymentFilter struct {
+	Ids               []string
+	OrganisationId    string
+	EndToEndReference string
+	DebtorParty       *PartyFilter
+	BeneficiaryParty  *PartyFilter
+}
+
+type PartyFilter struct {
+	BankId        string
+	AccountNumber string
+	AccountName   string
+	Country       string
 }
@@ -0,0 +1,51 @@
+package types
+
+import "time"
+
+type PaymentReconciliation struct {
+	StatementReference string    `json:"statement_reference"`
+	EntryReference     string    `json:"entry_reference,omitempty"`
+	ReconciledAt       time.Time `json:"reconciled_at"`
+}
+
+type ReconciliationReport struct {
+	Id             string                 `json:"id"`
+	OrganisationId string                 `json:"organisation_id"`
+	StatementId    string                 `json:"statement_id"`
+	CreatedAt      time.Time              `json:"created_at"`
+	Matched        []*ReconciliationMatch `json:"matched"`
+	Unmatched      []*StatementEntry      `json:"unmatched"`
+	Ambiguous      []*AmbiguousEntry      `json:"ambiguous"`
+}
+
+type ReconciliationMatch struct {
+	Entry     *StatementEntry `json:"entry"`
+	PaymentId string          `json:"payment_id"`
+}
+
+type AmbiguousEntry struct {
+	Entry      *StatementEntry `json:"entry"`
+	PaymentIds []string        `json:"payment_ids"`
+}
+
+type StatementEntry struct {
+	StatementId string `json:"statement_id"`
+
+	// Account the statement is for, an IBAN or other account id, and the BIC
+	// of the bank servicing it when the statement gives one
+	Account       string `json:"account,omitempty"`
+	AccountBankId string `json:"account_bank_id,omitempty"`
+
+	EntryReference      string  `json:"entry_reference,omitempty"`
+	EndToEndReference   string  `json:"end_to_end_reference,omitempty"`
+	Amount              float64 `json:"amount"`
+	Currency            string  `json:"currency,omitempty"`
+	CreditDebit         string  `json:"credit_debit"`
+	BookingDate         string  `json:"booking_date,omitempty"`
+	CounterpartyName    string  `json:"counterparty_name,omitempty"`
+	CounterpartyAccount string  `json:"counterparty_account,omitempty"`
+}
+
+type ReconciliationReports struct {
+	Data []*ReconciliationReport `json:"data"`
+}
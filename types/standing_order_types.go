@@ -0,0 +1,60 @@
+package types
+
+import "time"
+
+// Recurrence frequencies
+const (
+	Daily   = "daily"
+	Weekly  = "weekly"
+	Monthly = "monthly"
+)
+
+// Standing order statuses
+const (
+	StandingOrderActive    = "active"
+	StandingOrderPaused    = "paused"
+	StandingOrderCompleted = "completed"
+)
+
+// Template of payments generated on a recurrence
+type StandingOrder struct {
+	Type           string             `json:"type,omitempty"`
+	Id             string             `json:"id,omitempty"`
+	Version        int64              `json:"version"`
+	OrganisationId string             `json:"organisation_id,omitempty"`
+	Status         string             `json:"status,omitempty"`
+	CreatedBy      string             `json:"created_by,omitempty"`
+	CreatedAt      time.Time          `json:"created_at"`
+	Template       *PaymentAttributes `json:"template,omitempty"`
+	Recurrence     *Recurrence        `json:"recurrence,omitempty"`
+
+	// Occurrences elapsed, generated or skipped while paused, and the
+	// execution date of the next one, nil once completed
+	Occurrences       int        `json:"occurrences"`
+	NextExecutionDate *time.Time `json:"next_execution_date,omitempty"`
+
+	Generated []*GeneratedPayment `json:"generated,omitempty"`
+}
+
+// Occurrences every Interval days, weeks or months from StartDate, ending
+// after EndDate or Count occurrences when set
+type Recurrence struct {
+	Frequency string     `json:"frequency,omitempty"`
+	Interval  int        `json:"interval,omitempty"`
+	StartDate *time.Time `json:"start_date,omitempty"`
+	EndDate   *time.Time `json:"end_date,omitempty"`
+	Count     int        `json:"count,omitempty"`
+}
+
+// Payment generated for an occurrence, or the reason it could not be
+type GeneratedPayment struct {
+	Occurrence    int       `json:"occurrence"`
+	ExecutionDate time.Time `json:"execution_date"`
+	PaymentId     string    `json:"payment_id,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	GeneratedAt   time.Time `json:"generated_at"`
+}
+
+type StandingOrders struct {
+	Data []*StandingOrder `json:"data"`
+}